@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/types"
+	"lynx/std"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	register(&Command{
+		Name:    "check",
+		Args:    "<file>",
+		Summary: "type check a program without running it",
+		Run: func(args []string) {
+			if len(args) != 1 {
+				usage("check")
+			}
+			loadProject(filepath.Dir(args[0]))
+			checkFile(args[0])
+		},
+	})
+	register(&Command{
+		Name:    "std",
+		Args:    "list",
+		Summary: "list the embedded standard library modules",
+		Run: func(args []string) {
+			if len(args) != 1 || args[0] != "list" {
+				usage("std")
+			}
+			listStd()
+		},
+	})
+}
+
+// checkFile type checks a program without running it
+func checkFile(filename string) {
+	checker := types.New()
+	checker.Resolve = evaluator.FindModule
+	checker.Read = evaluator.ReadModule
+	checker.Globals = evaluator.BuiltinNames()
+
+	// Problems inside the embedded standard library are not the user's to fix
+	errs := []types.Error{}
+	for _, err := range checker.CheckFile(filename) {
+		if !strings.HasPrefix(err.File, evaluator.StdRoot) {
+			errs = append(errs, err)
+		}
+	}
+	for _, err := range errs {
+		fmt.Println(err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
+
+// listStd prints the embedded standard library modules, noting any that a
+// file in the current directory or search path overrides
+func listStd() {
+	dir, _ := os.Getwd()
+	for _, name := range std.Modules() {
+		path, err := evaluator.FindModule(name, dir)
+		if err == nil && !strings.HasPrefix(path, evaluator.StdRoot) {
+			fmt.Printf("%s (overridden by %s)\n", name, path)
+			continue
+		}
+		fmt.Println(name)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"lynx/pkg/cover"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// covering holds the run command's coverage options
+var covering struct {
+	report bool
+	html   string
+	lcov   string
+}
+
+func coverageRequested() bool {
+	return covering.report || covering.html != "" || covering.lcov != ""
+}
+
+// startCoverage records coverage, returning the function that writes the
+// reports when the program ends
+func startCoverage() func() {
+	c := cover.New()
+	c.Start()
+	return func() {
+		c.Stop()
+		if covering.report {
+			c.WriteText(os.Stderr, relativePath)
+		}
+		writeOutput(covering.html, func(w io.Writer) error { return c.WriteHTML(w, relativePath) })
+		writeOutput(covering.lcov, c.WriteLCOV)
+	}
+}
+
+// relativePath shortens paths under the working directory
+func relativePath(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
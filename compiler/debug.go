@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"lynx/pkg/debug"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	register(&Command{
+		Name:    "debug",
+		Args:    "[-b [file:]line]... <file> [args...] | --dap",
+		Summary: "run a program in the step debugger, or serve DAP to an editor",
+		Run:     debugCommand,
+	})
+}
+
+// breakpointFlags collects repeated -b options
+type breakpointFlags []string
+
+func (b *breakpointFlags) String() string { return fmt.Sprint(*b) }
+func (b *breakpointFlags) Set(value string) error {
+	*b = append(*b, value)
+	return nil
+}
+
+// debugCommand runs a file under the console debugger, stopped before its
+// first statement, or with --dap speaks the Debug Adapter Protocol on
+// stdin and stdout and runs the program the editor launches.
+func debugCommand(args []string) {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	dap := flags.Bool("dap", false, "serve the Debug Adapter Protocol on stdin and stdout")
+	var breakpoints breakpointFlags
+	flags.Var(&breakpoints, "b", "set a breakpoint at [file:]line (repeatable)")
+	flags.Usage = func() { usage("debug") }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	if *dap {
+		serveDAP()
+		return
+	}
+	if flags.NArg() == 0 {
+		usage("debug")
+	}
+
+	file := flags.Arg(0)
+	path, err := filepath.Abs(file)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, err := range p.Errors() {
+			fmt.Printf("Parser error: %s\n", err)
+		}
+		os.Exit(1)
+	}
+
+	loadProject(filepath.Dir(path))
+	evaluator.ScriptArgs = scriptArgs(flags.Args()[1:])
+	evaluator.RegisterBuiltins()
+	env := object.New(filepath.Dir(path))
+	env.File = path
+
+	d := debug.New(debug.NewConsole(os.Stdin, os.Stdout))
+	for _, b := range breakpoints {
+		if err := setBreakpoint(d, b, path); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Println("Type help for the debugger's commands.")
+	result := d.Run(program, env, true)
+	if result == nil {
+		os.Exit(1)
+	}
+	exitWith(result)
+}
+
+// setBreakpoint sets a -b breakpoint, in file unless one is named
+func setBreakpoint(d *debug.Debugger, spec, file string) error {
+	lineText := spec
+	if i := strings.LastIndex(spec, ":"); i >= 0 {
+		file, lineText = spec[:i], spec[i+1:]
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		return fmt.Errorf("expected [file:]line, got %q", spec)
+	}
+	_, err = d.SetBreakpoint(file, line, "")
+	return err
+}
+
+// serveDAP runs a debug adapter session on stdin and stdout
+func serveDAP() {
+	if err := debug.NewDAP(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "debug: %v\n", err)
+		os.Exit(1)
+	}
+}
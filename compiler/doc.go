@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lynx/pkg/doc"
+	"lynx/pkg/evaluator"
+	"lynx/std"
+	"os"
+	"strings"
+)
+
+func init() {
+	register(&Command{
+		Name:    "doc",
+		Args:    "[--format markdown|json|html] [-o file] [<file|dir|module|std>...]",
+		Summary: "generate documentation from /// doc comments",
+		Run:     docCommand,
+	})
+}
+
+// docCommand documents files, the .lynx files under directories, modules
+// found by import name, or with std the whole standard library. Without
+// arguments it documents the current directory.
+func docCommand(args []string) {
+	flags := flag.NewFlagSet("doc", flag.ContinueOnError)
+	format := flags.String("format", "markdown", "write markdown, json or html")
+	output := flags.String("o", "", "write to `file` instead of standard output")
+	flags.Usage = func() { usage("doc") }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	var write func(w io.Writer, modules []*doc.Module) error
+	switch *format {
+	case "markdown", "md":
+		write = doc.WriteMarkdown
+	case "json":
+		write = doc.WriteJSON
+	case "html":
+		write = doc.WriteHTML
+	default:
+		fmt.Printf("Error: unknown format %q, expected markdown, json or html\n", *format)
+		os.Exit(1)
+	}
+
+	targets := flags.Args()
+	if len(targets) == 0 {
+		targets = []string{"."}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	loadProject(cwd)
+	paths, err := docPaths(targets, cwd)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	modules := []*doc.Module{}
+	failed := false
+	for _, path := range paths {
+		source, err := evaluator.ReadModule(path)
+		if err != nil {
+			fmt.Printf("Error reading file: %v\n", err)
+			failed = true
+			continue
+		}
+		m, errs := doc.Source(string(source), relativePath(path))
+		if len(errs) > 0 {
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", relativePath(path), e)
+			}
+			failed = true
+			continue
+		}
+		modules = append(modules, m)
+	}
+
+	// Modules that failed to parse are reported and left out
+	if *output != "" {
+		writeOutput(*output, func(w io.Writer) error { return write(w, modules) })
+	} else if err := write(os.Stdout, modules); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// docPaths resolves the targets of lynx doc to module paths. Test files
+// found in directories are left out.
+func docPaths(targets []string, dir string) ([]string, error) {
+	paths := []string{}
+	for _, target := range targets {
+		if target == "std" {
+			for _, name := range std.Modules() {
+				paths = append(paths, evaluator.StdRoot+"/"+name+".lynx")
+			}
+			continue
+		}
+		if info, err := os.Stat(target); err == nil {
+			files, err := lynxFiles([]string{target})
+			if err != nil {
+				return nil, err
+			}
+			for _, file := range files {
+				if !info.IsDir() || !strings.HasSuffix(file, "_test.lynx") {
+					paths = append(paths, file)
+				}
+			}
+			continue
+		}
+		path, err := evaluator.FindModule(target, dir)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
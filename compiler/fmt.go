@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"lynx/pkg/format"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	register(&Command{
+		Name:    "fmt",
+		Args:    "[-w] [--check] <file|dir>...",
+		Summary: "format programs in the canonical layout",
+		Run:     fmtCommand,
+	})
+}
+
+// fmtCommand prints each file formatted, rewrites it with -w, or with
+// --check lists the files that are not formatted and exits with status 1
+func fmtCommand(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result back to each file")
+	check := flags.Bool("check", false, "list files whose formatting differs and exit 1 if any")
+	flags.Usage = func() { usage("fmt") }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if flags.NArg() == 0 {
+		usage("fmt")
+	}
+
+	files, err := lynxFiles(flags.Args())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Error reading file: %v\n", err)
+			failed = true
+			continue
+		}
+		formatted, err := format.Source(string(source))
+		if err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Printf("%s: %s\n", file, line)
+			}
+			failed = true
+			continue
+		}
+
+		switch {
+		case *check:
+			if formatted != string(source) {
+				fmt.Println(file)
+				failed = true
+			}
+		case *write:
+			if formatted == string(source) {
+				continue
+			}
+			if err := os.WriteFile(file, []byte(formatted), 0644); err != nil {
+				fmt.Printf("Error writing file: %v\n", err)
+				failed = true
+			}
+		default:
+			fmt.Print(formatted)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// lynxFiles expands directories among paths into the .lynx files below them
+func lynxFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && filepath.Ext(file) == ".lynx" {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
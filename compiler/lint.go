@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"lynx/pkg/lint"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	register(&Command{
+		Name:    "lint",
+		Args:    "[--rules] <file|dir>...",
+		Summary: "report likely mistakes such as unused variables",
+		Run:     lintCommand,
+	})
+}
+
+// lintCommand reports problems in each file and exits with status 1 if any
+// has error severity. Rule severities come from the [lint] table of the
+// project's lynx.toml.
+func lintCommand(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	listRules := flags.Bool("rules", false, "list the rules and their severities")
+	flags.Usage = func() { usage("lint") }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+	}
+	config := lintConfig(dir)
+
+	if *listRules {
+		for _, rule := range lint.Rules {
+			severity := rule.Severity
+			if s, ok := config[rule.ID]; ok {
+				severity = s
+			}
+			fmt.Printf("%-22s %-8s %s\n", rule.ID, severity, rule.Summary)
+		}
+		return
+	}
+	if flags.NArg() == 0 {
+		usage("lint")
+	}
+
+	files, err := lynxFiles(flags.Args())
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, file := range files {
+		source, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("Error reading file: %v\n", err)
+			failed = true
+			continue
+		}
+		diags, err := lint.Source(string(source), file, config)
+		if err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Printf("%s: %s\n", file, line)
+			}
+			failed = true
+			continue
+		}
+		for _, d := range diags {
+			fmt.Println(d)
+			if d.Severity == lint.Error {
+				failed = true
+			}
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// lintConfig reads rule settings from the project governing dir, if any
+func lintConfig(dir string) lint.Config {
+	m := loadProject(dir)
+	if m == nil {
+		return lint.Config{}
+	}
+	config, err := lint.ParseConfig(m.Lint)
+	if err != nil {
+		fmt.Printf("Error loading project: %v\n", err)
+		os.Exit(1)
+	}
+	return config
+}
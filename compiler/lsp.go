@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"lynx/pkg/lsp"
+	"os"
+)
+
+func init() {
+	register(&Command{
+		Name:    "lsp",
+		Summary: "run a language server on stdin and stdout for editors",
+		Run:     lspCommand,
+	})
+}
+
+// lspCommand serves the Language Server Protocol until the editor exits.
+// Imports resolve with the roots of the project the server starts in.
+func lspCommand(args []string) {
+	if len(args) > 0 {
+		usage("lsp")
+	}
+	loadProject(".")
+	if err := lsp.New(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "lsp: %v\n", err)
+		os.Exit(1)
+	}
+}
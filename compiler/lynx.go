@@ -1,48 +1,250 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"lynx/pkg/ast"
 	"lynx/pkg/evaluator"
 	"lynx/pkg/lexer"
 	"lynx/pkg/object"
 	"lynx/pkg/parser"
+	"lynx/pkg/project"
+	"lynx/pkg/repl"
 	"os"
 	"path/filepath"
+	"sort"
 )
 
-// Lynx interpreter entry point - reads and executes .lynx source files
+const version = "0.1.0"
+
+// Command is a lynx subcommand. Commands register themselves from init so
+// that help and dispatch pick them up.
+type Command struct {
+	Name    string
+	Args    string // argument synopsis shown in usage lines
+	Summary string
+	Run     func(args []string)
+}
+
+var commands = map[string]*Command{}
+
+func register(cmd *Command) {
+	commands[cmd.Name] = cmd
+}
+
+func init() {
+	register(&Command{
+		Name:    "run",
+		Args:    "[--strict-types] [--profile | --cover] [-e code | <file> | -] [-- args...]",
+		Summary: "run a program, an expression or standard input",
+		Run:     runCommand,
+	})
+	register(&Command{
+		Name:    "repl",
+		Summary: "start an interactive session",
+		Run:     func(args []string) { startREPL() },
+	})
+	register(&Command{
+		Name:    "help",
+		Args:    "[command]",
+		Summary: "show help for lynx or a command",
+		Run:     helpCommand,
+	})
+	register(&Command{
+		Name:    "version",
+		Summary: "print the lynx version",
+		Run:     func(args []string) { fmt.Println("lynx", version) },
+	})
+}
+
+// Lynx interpreter entry point - dispatches to a subcommand, or runs a file
+// when the first argument is not one
 func main() {
 	args := os.Args[1:]
 
-	if len(args) == 0 {
-		fmt.Println("Usage: lynx <filename>")
+	if len(args) > 0 {
+		switch args[0] {
+		case "--version", "-v":
+			args[0] = "version"
+		case "--help", "-h":
+			args[0] = "help"
+		}
+		if cmd, ok := commands[args[0]]; ok {
+			cmd.Run(args[1:])
+			return
+		}
+	}
+	runCommand(args)
+}
+
+// usage prints a command's usage line and exits with status 1
+func usage(name string) {
+	cmd := commands[name]
+	fmt.Printf("Usage: lynx %s %s\n", cmd.Name, cmd.Args)
+	os.Exit(1)
+}
+
+func helpCommand(args []string) {
+	if len(args) > 0 {
+		cmd, ok := commands[args[0]]
+		if !ok {
+			fmt.Printf("Unknown command: %s\n", args[0])
+			os.Exit(1)
+		}
+		fmt.Printf("Usage: lynx %s %s\n\n%s\n", cmd.Name, cmd.Args, cmd.Summary)
+		return
+	}
+
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println("Usage: lynx [--strict-types] <file> [args...]")
+	fmt.Println("       lynx <command> [arguments]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, name := range names {
+		fmt.Printf("  %-10s %s\n", name, commands[name].Summary)
+	}
+	fmt.Println()
+	fmt.Println("Run lynx help <command> for details on a command.")
+}
+
+// runCommand runs a program given as a file, as -e code or on stdin (-).
+// Arguments after the program, optionally separated by --, are passed to
+// the script. With no program it runs the project entry, or starts the REPL
+// outside a project.
+func runCommand(args []string) {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.BoolVar(&evaluator.StrictTypes, "strict-types", false, "check annotated types at runtime")
+	code := flags.String("e", "", "program text to run")
+	flags.BoolVar(&profiling.report, "profile", false, "print a profile of the program's calls to stderr")
+	flags.StringVar(&profiling.folded, "profile-folded", "", "write the profile as folded stacks to `file`")
+	flags.StringVar(&profiling.pprof, "profile-pprof", "", "write the profile in pprof format to `file`")
+	flags.BoolVar(&covering.report, "cover", false, "print the statement and branch coverage to stderr")
+	flags.StringVar(&covering.html, "cover-html", "", "write the coverage as an annotated HTML page to `file`")
+	flags.StringVar(&covering.lcov, "cover-lcov", "", "write the coverage in LCOV format to `file`")
+	flags.Usage = func() { usage("run") }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	rest := flags.Args()
+	if profileRequested() && coverageRequested() {
+		fmt.Println("Error: profiling and coverage can't be used together")
+		os.Exit(1)
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *code != "" {
+		evaluator.ScriptArgs = scriptArgs(rest)
+		loadProject(cwd)
+		executeSource(*code, cwd, "")
+		return
+	}
+
+	if len(rest) == 0 {
+		if m := loadProject(cwd); m != nil {
+			runFile(m.EntryPath())
+			return
+		}
+		startREPL()
+		return
+	}
+
+	evaluator.ScriptArgs = scriptArgs(rest[1:])
+	if rest[0] == "-" {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+		loadProject(cwd)
+		executeSource(string(input), cwd, "")
+		return
+	}
+
+	loadProject(filepath.Dir(rest[0]))
+	runFile(rest[0])
+}
+
+func startREPL() {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	loadProject(cwd)
+	r := repl.New(os.Stdin, os.Stdout, cwd)
+	r.LoadHistory(repl.HistoryFile())
+	if err := r.Run(); err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
+}
+
+// scriptArgs drops the -- that separates lynx's arguments from the script's
+func scriptArgs(args []string) []string {
+	if len(args) > 0 && args[0] == "--" {
+		return args[1:]
+	}
+	return args
+}
 
-	filename := args[0]
+func runFile(filename string) {
 	absPath, err := filepath.Abs(filename)
 	if err != nil {
 		fmt.Printf("Error getting absolute path: %v\n", err)
 		os.Exit(1)
 	}
 
-	if len(args) > 1 {
-		fmt.Println("Too many arguments")
+	executeFile(absPath)
+}
+
+// loadProject finds the lynx.toml governing dir, if any, and adds its
+// module roots to module resolution
+func loadProject(dir string) *project.Manifest {
+	m, err := project.Find(dir)
+	if err != nil {
+		fmt.Printf("Error loading project: %v\n", err)
 		os.Exit(1)
 	}
+	if m == nil {
+		return nil
+	}
+
+	stale, err := m.StaleDependencies()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+	for _, name := range stale {
+		fmt.Fprintf(os.Stderr, "Warning: dependency %s changed since %s was written; run lynx vendor or lynx add to relock\n", name, project.LockFile)
+	}
 
-	dir := filepath.Dir(absPath)
-	executeFile(filename, dir)
+	evaluator.ModuleRoots = m.ModuleRoots()
+	return m
 }
 
-func executeFile(filename string, dir string) {
-	input, err := os.ReadFile(filename)
+func executeFile(path string) {
+	input, err := os.ReadFile(path)
 	if err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 		os.Exit(1)
 	}
+	executeSource(string(input), filepath.Dir(path), path)
+}
 
-	l := lexer.New(string(input))
+// executeSource runs a program from file, if it has one, whose imports
+// resolve relative to dir and exits with the program's status
+func executeSource(input string, dir string, file string) {
+	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
 
@@ -54,10 +256,42 @@ func executeFile(filename string, dir string) {
 	}
 
 	env := object.New(dir)
+	env.File = file
 	evaluator.RegisterBuiltins()
+	exitWith(evaluate(program, env))
+}
+
+// evaluate runs a program, under the profiler or recording coverage if
+// their output was asked for. It is written when the program ends,
+// including by os.exit().
+func evaluate(program *ast.Program, env *object.Env) object.Object {
+	var finish func()
+	switch {
+	case profileRequested():
+		finish = startProfile(env.File)
+	case coverageRequested():
+		finish = startCoverage()
+	default:
+		return evaluator.Eval(program, env)
+	}
+
+	evaluator.Exit = func(code int) {
+		finish()
+		os.Exit(code)
+	}
 	result := evaluator.Eval(program, env)
+	finish()
+	return result
+}
 
+// exitWith reports a program's result and exits with its status: an error
+// fails, and an integer result is printed and used as the status
+func exitWith(result object.Object) {
 	if errorObj, ok := result.(*object.Error); ok {
+		if errorObj.Internal {
+			fmt.Printf("Internal error (interpreter bug): %s\n", errorObj.Message)
+			os.Exit(1)
+		}
 		fmt.Printf("Error: %s\n", errorObj.Message)
 		os.Exit(1)
 	}
@@ -46,9 +46,12 @@ func (expr *ExpressionStatement) TokenLiteral() string {
 	return expr.Token.Literal
 }
 
-// Program is the root AST node containing all statements
+// Program is the root AST node containing all statements, along with the
+// comments and blank lines of its source
 type Program struct {
 	Statements []Statement
+	Comments   []token.Comment
+	BlankLines []int
 }
 
 func (p *Program) String() string {
@@ -69,10 +72,13 @@ func (p *Program) TokenLiteral() string {
 }
 
 type VarStatement struct {
-	Token   token.Token
-	Name    *Identifier
-	Value   Expression
-	IsConst bool
+	Token    token.Token
+	Name     *Identifier
+	Type     *TypeAnnotation
+	Value    Expression
+	IsConst  bool
+	IsFnDecl bool   // written as `fn name(...) { ... }`
+	Doc      string // the /// comment above the declaration
 }
 
 func (vr *VarStatement) statementNode() {}
@@ -85,6 +91,9 @@ func (vr *VarStatement) String() string {
 
 	out.WriteString(vr.TokenLiteral() + " ")
 	out.WriteString(vr.Name.Value)
+	if vr.Type != nil {
+		out.WriteString(": " + vr.Type.String())
+	}
 	out.WriteString(" = ")
 	if vr.Value != nil {
 		out.WriteString(vr.Value.String())
@@ -96,6 +105,7 @@ type Assignment struct {
 	Token token.Token
 	Name  Expression
 	Value Expression
+	Doc   string // the /// comment above a module member's definition
 }
 
 func (ls *Assignment) statementNode() {}
@@ -129,6 +139,40 @@ func (i *Identifier) String() string {
 	return i.Value
 }
 
+// TypeAnnotation is an optional declared type: a name such as `int` or a
+// class, with element types (`array[int]`, `hash[str, int]`), a trailing
+// `?` for nullable, or a union of alternatives (`int | str`)
+type TypeAnnotation struct {
+	Token    token.Token
+	Name     string
+	Params   []*TypeAnnotation
+	Nullable bool
+	Union    []*TypeAnnotation
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) String() string {
+	if len(ta.Union) > 0 {
+		alts := []string{}
+		for _, alt := range ta.Union {
+			alts = append(alts, alt.String())
+		}
+		return strings.Join(alts, " | ")
+	}
+	out := ta.Name
+	if len(ta.Params) > 0 {
+		params := []string{}
+		for _, p := range ta.Params {
+			params = append(params, p.String())
+		}
+		out += "[" + strings.Join(params, ", ") + "]"
+	}
+	if ta.Nullable {
+		out += "?"
+	}
+	return out
+}
+
 type ReturnStatement struct {
 	Token token.Token
 	Value Expression
@@ -252,6 +296,7 @@ func (ie *IfExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	End        token.Token // closing brace; zero for implicit blocks
 }
 
 func (bs *BlockStatement) expressionNode()      {}
@@ -267,7 +312,10 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token
 	Parameters []*Identifier
+	ParamTypes []*TypeAnnotation // parallel to Parameters, nil when unannotated
+	ReturnType *TypeAnnotation
 	Body       *BlockStatement
+	Doc        string // of the declaration the function is bound by
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
@@ -275,17 +323,55 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if i < len(fl.ParamTypes) && fl.ParamTypes[i] != nil {
+			params = append(params, p.String()+": "+fl.ParamTypes[i].String())
+			continue
+		}
 		params = append(params, p.String())
 	}
+	if fl.Token.Type == token.ARROW {
+		out.WriteString("(")
+		out.WriteString(strings.Join(params, ", "))
+		out.WriteString(") => ")
+		if len(fl.Body.Statements) == 1 {
+			if ret, ok := fl.Body.Statements[0].(*ReturnStatement); ok {
+				out.WriteString(ret.Value.String())
+				return out.String()
+			}
+		}
+		out.WriteString(fl.Body.String())
+		return out.String()
+	}
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 	return out.String()
 }
 
+// Signature formats a function's parameters and return type as they are
+// declared, as in (a: int, b) -> str
+func Signature(params []*Identifier, types []*TypeAnnotation, ret *TypeAnnotation) string {
+	list := []string{}
+	for i, p := range params {
+		if i < len(types) && types[i] != nil {
+			list = append(list, p.Value+": "+types[i].String())
+			continue
+		}
+		list = append(list, p.Value)
+	}
+	sig := "(" + strings.Join(list, ", ") + ")"
+	if ret != nil {
+		sig += " -> " + ret.String()
+	}
+	return sig
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression
@@ -356,6 +442,7 @@ func (ie *IndexExpression) String() string {
 type HashLiteral struct {
 	Token token.Token
 	Pairs map[Expression]Expression
+	Keys  []Expression // Pairs' keys in source order
 }
 
 func (hl *HashLiteral) expressionNode()      {}
@@ -481,8 +568,9 @@ func (c *Continue) String() string {
 
 type ModuleLoad struct {
 	Token   token.Token
-	Name    Expression
+	Name    Expression // identifier, dotted name or quoted relative path
 	Members []*Identifier
+	Alias   *Identifier
 }
 
 func (ml *ModuleLoad) statementNode()       {}
@@ -491,7 +579,18 @@ func (ml *ModuleLoad) TokenLiteral() string { return ml.Token.Literal }
 func (ml *ModuleLoad) String() string {
 	var out bytes.Buffer
 	out.WriteString("@")
-	out.WriteString(ml.Name.String())
+	if ml.IsRelative() {
+		out.WriteString("\"" + ml.Path() + "\"")
+	} else {
+		out.WriteString(ml.Name.String())
+	}
+	if ml.Alias != nil {
+		out.WriteString(" as ")
+		out.WriteString(ml.Alias.String())
+	}
+	if ml.Members == nil {
+		return out.String()
+	}
 	out.WriteString("(")
 	for i, member := range ml.Members {
 		if i > 0 {
@@ -503,6 +602,34 @@ func (ml *ModuleLoad) String() string {
 	return out.String()
 }
 
+// Path is the module as written, a dotted name or a file path
+func (ml *ModuleLoad) Path() string {
+	if str, ok := ml.Name.(*StringLiteral); ok {
+		return str.Value
+	}
+	return ml.Name.String()
+}
+
+// IsRelative reports whether the module was given as a quoted path
+func (ml *ModuleLoad) IsRelative() bool {
+	_, ok := ml.Name.(*StringLiteral)
+	return ok
+}
+
+// Binding is the name the module is bound to in the importing file: the
+// alias, or else the last segment of its path
+func (ml *ModuleLoad) Binding() string {
+	if ml.Alias != nil {
+		return ml.Alias.Value
+	}
+	path := ml.Path()
+	if ml.IsRelative() {
+		path = strings.TrimSuffix(path, ".lynx")
+		return path[strings.LastIndex(path, "/")+1:]
+	}
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
 type SwitchStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -554,6 +681,18 @@ func (c *Case) String() string {
 	return out.String()
 }
 
+// TryExpression is the postfix `?` operator on a result
+type TryExpression struct {
+	Token token.Token
+	Value Expression
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	return te.Value.String() + "?"
+}
+
 type PipeExpression struct {
 	Token token.Token
 	Left  Expression
@@ -607,28 +746,83 @@ func (es *ErrorStatement) String() string {
 	return out.String()
 }
 
+// DeferStatement schedules Value, or Body for `defer { ... }`, to run when
+// the enclosing function exits
+type DeferStatement struct {
+	Token token.Token
+	Value Expression
+	Body  *BlockStatement
+}
+
+func (ds *DeferStatement) statementNode()       {}
+func (ds *DeferStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DeferStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("defer ")
+	if ds.Body != nil {
+		out.WriteString(ds.Body.String())
+	} else {
+		out.WriteString(ds.Value.String())
+	}
+	return out.String()
+}
+
 type CatchStatement struct {
 	Token    token.Token
 	Body     *BlockStatement
-	ErrorVar *Identifier
-	OnBody   *BlockStatement
+	Handlers []*CatchHandler
+	Finally  *BlockStatement
 }
 
 func (cs *CatchStatement) statementNode()       {}
 func (cs *CatchStatement) TokenLiteral() string { return cs.Token.Literal }
 func (cs *CatchStatement) String() string {
 	var out bytes.Buffer
-	out.WriteString("catch ")
-	out.WriteString(cs.ErrorVar.String())
-	out.WriteString(" {\n")
+	out.WriteString("catch {\n")
 	for _, s := range cs.Body.Statements {
 		out.WriteString(s.String())
 		out.WriteString("\n")
 	}
 	out.WriteString("}")
-	out.WriteString("\n")
-	out.WriteString("on {\n")
-	for _, s := range cs.OnBody.Statements {
+	for _, h := range cs.Handlers {
+		out.WriteString(" ")
+		out.WriteString(h.String())
+	}
+	if cs.Finally != nil {
+		out.WriteString(" finally {\n")
+		for _, s := range cs.Finally.Statements {
+			out.WriteString(s.String())
+			out.WriteString("\n")
+		}
+		out.WriteString("}")
+	}
+	return out.String()
+}
+
+// CatchHandler is one `on e: Type { ... }` clause; with no types it
+// handles every catchable error
+type CatchHandler struct {
+	Token    token.Token
+	ErrorVar *Identifier
+	Types    []*Identifier
+	Body     *BlockStatement
+}
+
+func (ch *CatchHandler) TokenLiteral() string { return ch.Token.Literal }
+func (ch *CatchHandler) String() string {
+	var out bytes.Buffer
+	out.WriteString("on ")
+	out.WriteString(ch.ErrorVar.String())
+	if len(ch.Types) > 0 {
+		types := []string{}
+		for _, t := range ch.Types {
+			types = append(types, t.String())
+		}
+		out.WriteString(": ")
+		out.WriteString(strings.Join(types, ", "))
+	}
+	out.WriteString(" {\n")
+	for _, s := range ch.Body.Statements {
 		out.WriteString(s.String())
 		out.WriteString("\n")
 	}
@@ -652,7 +846,9 @@ type Class struct {
 	Token      token.Token
 	Name       *Identifier
 	SuperClass *Identifier
+	Traits     []*Identifier
 	Body       *BlockStatement
+	Doc        string
 }
 
 func (c *Class) statementNode()       {}
@@ -668,17 +864,24 @@ func (c *Class) String() string {
 		out.WriteString(")")
 	}
 
-	out.WriteString(":\n")
+	if len(c.Traits) > 0 {
+		traits := []string{}
+		for _, t := range c.Traits {
+			traits = append(traits, t.String())
+		}
+		out.WriteString(" impl ")
+		out.WriteString(strings.Join(traits, ", "))
+	}
 
+	out.WriteString(" {\n")
 	if c.Body != nil {
 		for _, s := range c.Body.Statements {
 			out.WriteString("    ")
 			out.WriteString(s.String())
 			out.WriteString("\n")
 		}
-	} else {
-		out.WriteString("    pass\n")
 	}
+	out.WriteString("}")
 
 	return out.String()
 }
@@ -690,3 +893,126 @@ type Self struct {
 func (s *Self) expressionNode()      {}
 func (s *Self) TokenLiteral() string { return s.Token.Literal }
 func (s *Self) String() string       { return "self" }
+
+type Super struct {
+	Token token.Token
+}
+
+func (s *Super) expressionNode()      {}
+func (s *Super) TokenLiteral() string { return s.Token.Literal }
+func (s *Super) String() string       { return "super" }
+
+// Accessor declares a computed property in a class body, for example
+// `get area = fn() { ... }` or `set area = fn(value) { ... }`
+type Accessor struct {
+	Token token.Token
+	Kind  string
+	Name  *Identifier
+	Value Expression
+	Doc   string
+}
+
+func (a *Accessor) statementNode()       {}
+func (a *Accessor) TokenLiteral() string { return a.Token.Literal }
+func (a *Accessor) String() string {
+	var out bytes.Buffer
+	out.WriteString(a.Kind + " ")
+	out.WriteString(a.Name.String())
+	out.WriteString(" = ")
+	if a.Value != nil {
+		out.WriteString(a.Value.String())
+	}
+	return out.String()
+}
+
+// ExportStatement makes a module's declaration visible to importers
+type ExportStatement struct {
+	Token     token.Token
+	Statement Statement
+}
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExportStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("export ")
+	out.WriteString(es.Statement.String())
+	return out.String()
+}
+
+type PrivateStatement struct {
+	Token     token.Token
+	Statement Statement
+}
+
+func (ps *PrivateStatement) statementNode()       {}
+func (ps *PrivateStatement) TokenLiteral() string { return ps.Token.Literal }
+func (ps *PrivateStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("private ")
+	out.WriteString(ps.Statement.String())
+	return out.String()
+}
+
+type StaticStatement struct {
+	Token     token.Token
+	Statement *VarStatement
+}
+
+func (ss *StaticStatement) statementNode()       {}
+func (ss *StaticStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StaticStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("static ")
+	out.WriteString(ss.Statement.String())
+	return out.String()
+}
+
+type Trait struct {
+	Token   token.Token
+	Name    *Identifier
+	Methods []*TraitMethod
+}
+
+func (t *Trait) statementNode()       {}
+func (t *Trait) TokenLiteral() string { return t.Token.Literal }
+func (t *Trait) String() string {
+	var out bytes.Buffer
+	out.WriteString("trait ")
+	out.WriteString(t.Name.String())
+	out.WriteString(" {\n")
+	for _, m := range t.Methods {
+		out.WriteString(m.String())
+		out.WriteString("\n")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+// TraitMethod is a method signature in a trait; Body is nil when the
+// method is required rather than provided as a default
+type TraitMethod struct {
+	Token      token.Token
+	Name       *Identifier
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (tm *TraitMethod) TokenLiteral() string { return tm.Token.Literal }
+func (tm *TraitMethod) String() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range tm.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString(tm.Name.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if tm.Body != nil {
+		out.WriteString(" { ")
+		out.WriteString(tm.Body.String())
+		out.WriteString(" }")
+	}
+	return out.String()
+}
@@ -0,0 +1,337 @@
+package ast
+
+import (
+	"reflect"
+
+	"lynx/pkg/token"
+)
+
+// Inspect traverses the tree rooted at node in source order, calling f for
+// each node. If f returns false, the node's children are skipped. Missing
+// parts of a tree that failed to parse are skipped too.
+func Inspect(node Node, f func(Node) bool) {
+	if isNil(node) || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *VarStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Type, f)
+		Inspect(n.Value, f)
+	case *Assignment:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *TypeAnnotation:
+		for _, p := range n.Params {
+			Inspect(p, f)
+		}
+		for _, u := range n.Union {
+			Inspect(u, f)
+		}
+	case *ReturnStatement:
+		Inspect(n.Value, f)
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Consequence, f)
+		Inspect(n.Alternative, f)
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Inspect(s, f)
+		}
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			Inspect(p, f)
+			if i < len(n.ParamTypes) {
+				Inspect(n.ParamTypes[i], f)
+			}
+		}
+		Inspect(n.ReturnType, f)
+		Inspect(n.Body, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, a := range n.Arguments {
+			Inspect(a, f)
+		}
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			Inspect(e, f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *HashLiteral:
+		for _, k := range n.Keys {
+			Inspect(k, f)
+			Inspect(n.Pairs[k], f)
+		}
+	case *MethodCall:
+		Inspect(n.Object, f)
+		Inspect(n.Method, f)
+		for _, a := range n.Arguments {
+			Inspect(a, f)
+		}
+	case *PropertyAccess:
+		Inspect(n.Object, f)
+		Inspect(n.Property, f)
+	case *ForRange:
+		Inspect(n.Index, f)
+		Inspect(n.Variable, f)
+		Inspect(n.Collection, f)
+		Inspect(n.Body, f)
+	case *While:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	case *ModuleLoad:
+		Inspect(n.Name, f)
+		for _, m := range n.Members {
+			Inspect(m, f)
+		}
+		Inspect(n.Alias, f)
+	case *SwitchStatement:
+		Inspect(n.Expression, f)
+		for _, c := range n.Cases {
+			Inspect(c, f)
+		}
+	case *Case:
+		Inspect(n.Value, f)
+		Inspect(n.Guard, f)
+		Inspect(n.Body, f)
+	case *TryExpression:
+		Inspect(n.Value, f)
+	case *PipeExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *Tuple:
+		for _, e := range n.Elements {
+			Inspect(e, f)
+		}
+	case *ErrorStatement:
+		Inspect(n.Value, f)
+	case *DeferStatement:
+		Inspect(n.Value, f)
+		Inspect(n.Body, f)
+	case *CatchStatement:
+		Inspect(n.Body, f)
+		for _, h := range n.Handlers {
+			Inspect(h, f)
+		}
+		Inspect(n.Finally, f)
+	case *CatchHandler:
+		Inspect(n.ErrorVar, f)
+		for _, t := range n.Types {
+			Inspect(t, f)
+		}
+		Inspect(n.Body, f)
+	case *Class:
+		Inspect(n.Name, f)
+		Inspect(n.SuperClass, f)
+		for _, t := range n.Traits {
+			Inspect(t, f)
+		}
+		Inspect(n.Body, f)
+	case *Accessor:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *ExportStatement:
+		Inspect(n.Statement, f)
+	case *PrivateStatement:
+		Inspect(n.Statement, f)
+	case *StaticStatement:
+		Inspect(n.Statement, f)
+	case *Trait:
+		Inspect(n.Name, f)
+		for _, m := range n.Methods {
+			Inspect(m, f)
+		}
+	case *TraitMethod:
+		Inspect(n.Name, f)
+		for _, p := range n.Parameters {
+			Inspect(p, f)
+		}
+		Inspect(n.Body, f)
+	}
+}
+
+// isNil reports whether node is nil, including a nil pointer of a node type
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// Hoist calls f for each statement that can bind a name in the scope stmts
+// run in: the statements themselves, those wrapped by export, private and
+// static, and those in the blocks of if, while, defer, catch and switch,
+// which share their enclosing scope at runtime. Class bodies declare methods
+// by assigning them, so in a class body an assignment to a bare name is
+// passed to f as well; elsewhere assignments never declare.
+func Hoist(stmts []Statement, inClass bool, f func(Statement)) {
+	for _, stmt := range stmts {
+		switch n := stmt.(type) {
+		case *VarStatement, *ModuleLoad, *Class, *Trait, *Accessor:
+			f(stmt)
+		case *Assignment:
+			if _, ok := n.Name.(*Identifier); ok && inClass {
+				f(stmt)
+			}
+		case *ExportStatement:
+			Hoist([]Statement{n.Statement}, inClass, f)
+		case *PrivateStatement:
+			Hoist([]Statement{n.Statement}, inClass, f)
+		case *StaticStatement:
+			Hoist([]Statement{n.Statement}, inClass, f)
+		case *ExpressionStatement:
+			if ifExpr, ok := n.Expression.(*IfExpression); ok {
+				hoistBlock(ifExpr.Consequence, inClass, f)
+				hoistBlock(ifExpr.Alternative, inClass, f)
+			}
+		case *While:
+			hoistBlock(n.Body, inClass, f)
+		case *DeferStatement:
+			hoistBlock(n.Body, inClass, f)
+		case *CatchStatement:
+			hoistBlock(n.Body, inClass, f)
+			hoistBlock(n.Finally, inClass, f)
+		case *SwitchStatement:
+			for _, c := range n.Cases {
+				// A case that binds its value runs in a scope of its own
+				if _, binds := c.Value.(*Identifier); !binds {
+					hoistBlock(c.Body, inClass, f)
+				}
+			}
+		}
+	}
+}
+
+func hoistBlock(block *BlockStatement, inClass bool, f func(Statement)) {
+	if block != nil {
+		Hoist(block.Statements, inClass, f)
+	}
+}
+
+// StartToken is the first token of a node, which gives its position. Nodes
+// that begin with an operand, such as calls, infix expressions and
+// assignments, start where that operand does.
+func StartToken(node Node) token.Token {
+	switch n := node.(type) {
+	case *Program:
+		if len(n.Statements) > 0 {
+			return StartToken(n.Statements[0])
+		}
+	case *ExpressionStatement:
+		if !isNil(n.Expression) {
+			return StartToken(n.Expression)
+		}
+		return n.Token
+	case *Assignment:
+		if tok := StartToken(n.Name); tok.Line > 0 {
+			return tok
+		}
+		return n.Token
+	case *CallExpression:
+		return StartToken(n.Function)
+	case *MethodCall:
+		return StartToken(n.Object)
+	case *PropertyAccess:
+		return StartToken(n.Object)
+	case *IndexExpression:
+		return StartToken(n.Left)
+	case *InfixExpression:
+		return StartToken(n.Left)
+	case *PipeExpression:
+		return StartToken(n.Left)
+	case *TryExpression:
+		return StartToken(n.Value)
+	case *FunctionLiteral:
+		// An arrow function's token is its arrow, after the parameters
+		if n.Token.Type == token.ARROW && len(n.Parameters) > 0 {
+			return n.Parameters[0].Token
+		}
+		return n.Token
+	case *VarStatement:
+		return n.Token
+	case *Identifier:
+		return n.Token
+	case *TypeAnnotation:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *FloatLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *Boolean:
+		return n.Token
+	case *Null:
+		return n.Token
+	case *Self:
+		return n.Token
+	case *Super:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *IfExpression:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *HashLiteral:
+		return n.Token
+	case *Tuple:
+		return n.Token
+	case *ForRange:
+		return n.Token
+	case *While:
+		return n.Token
+	case *Break:
+		return n.Token
+	case *Continue:
+		return n.Token
+	case *ModuleLoad:
+		return n.Token
+	case *SwitchStatement:
+		return n.Token
+	case *Case:
+		return n.Token
+	case *ErrorStatement:
+		return n.Token
+	case *DeferStatement:
+		return n.Token
+	case *CatchStatement:
+		return n.Token
+	case *CatchHandler:
+		return n.Token
+	case *Class:
+		return n.Token
+	case *Accessor:
+		return n.Token
+	case *ExportStatement:
+		return n.Token
+	case *PrivateStatement:
+		return n.Token
+	case *StaticStatement:
+		return n.Token
+	case *Trait:
+		return n.Token
+	case *TraitMethod:
+		return n.Token
+	}
+	return token.Token{}
+}
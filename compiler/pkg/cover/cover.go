@@ -0,0 +1,267 @@
+// Package cover records which statements and branches of a Lynx program
+// run. A Coverage installed as the evaluator's Tracer counts executions
+// by file and line, and reports them as a text summary, an annotated HTML
+// page or LCOV.
+package cover
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"sort"
+	"strings"
+)
+
+// Branch is an if or a switch and how often each of its arms ran: an if's
+// consequence and alternative, which is there even when not written, or
+// each case of a switch and, without a default, none matching
+type Branch struct {
+	Line   int
+	Column int
+	Kind   string // "if" or "switch"
+	Taken  []int
+}
+
+// File is the coverage of one source file
+type File struct {
+	Path     string
+	Source   []string
+	Lines    map[int]int // statement lines and how often they ran
+	Branches []*Branch   // in source order
+
+	branches  map[ast.Node]*Branch
+	positions map[[2]int]*Branch
+}
+
+// Coverage records statement and branch executions between Start and Stop
+type Coverage struct {
+	files map[string]*File
+}
+
+func New() *Coverage {
+	return &Coverage{files: make(map[string]*File)}
+}
+
+// Start installs the coverage recorder
+func (c *Coverage) Start() {
+	evaluator.Trace = c
+}
+
+// Stop uninstalls the coverage recorder
+func (c *Coverage) Stop() {
+	if evaluator.Trace == c {
+		evaluator.Trace = nil
+	}
+}
+
+// Files returns the covered files ordered by path. Programs without a file
+// and the standard library are not covered.
+func (c *Coverage) Files() []*File {
+	files := []*File{}
+	for _, f := range c.files {
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// file returns the coverage of path, finding its statements and branches
+// the first time it is seen, or nil if it is not covered
+func (c *Coverage) file(path string) *File {
+	if f, ok := c.files[path]; ok {
+		return f
+	}
+	c.files[path] = nil
+	if path == "" || strings.HasPrefix(path, evaluator.StdRoot) {
+		return nil
+	}
+	source, err := evaluator.ReadModule(path)
+	if err != nil {
+		return nil
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil
+	}
+
+	f := &File{
+		Path:      path,
+		Source:    strings.Split(strings.TrimSuffix(string(source), "\n"), "\n"),
+		Lines:     make(map[int]int),
+		branches:  make(map[ast.Node]*Branch),
+		positions: make(map[[2]int]*Branch),
+	}
+	f.collect(program)
+	c.files[path] = f
+	return f
+}
+
+// collect finds the statements the evaluator traces, those directly in a
+// program or block other than a class body, and the branches
+func (f *File) collect(program *ast.Program) {
+	classBodies := map[*ast.BlockStatement]bool{}
+	statements := func(stmts []ast.Statement) {
+		for _, stmt := range stmts {
+			if line := ast.StartToken(stmt).Line; line > 0 {
+				f.Lines[line] = 0
+			}
+		}
+	}
+	branch := func(line, column int, kind string, arms int) {
+		b := &Branch{Line: line, Column: column, Kind: kind, Taken: make([]int, arms)}
+		f.Branches = append(f.Branches, b)
+		f.positions[[2]int{line, column}] = b
+	}
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Program:
+			statements(n.Statements)
+		case *ast.BlockStatement:
+			if !classBodies[n] {
+				statements(n.Statements)
+			}
+		case *ast.Class:
+			classBodies[n.Body] = true
+		case *ast.IfExpression:
+			branch(n.Token.Line, n.Token.Column, "if", 2)
+		case *ast.SwitchStatement:
+			arms := len(n.Cases) + 1
+			for _, c := range n.Cases {
+				if c.Value == nil {
+					arms = len(n.Cases)
+				}
+			}
+			branch(n.Token.Line, n.Token.Column, "switch", arms)
+		}
+		return true
+	})
+}
+
+// Statement implements evaluator.Tracer
+func (c *Coverage) Statement(stmt ast.Statement, env *object.Env) {
+	if f := c.file(env.File); f != nil {
+		if line := ast.StartToken(stmt).Line; line > 0 {
+			f.Lines[line]++
+		}
+	}
+}
+
+// Branch implements evaluator.Tracer. The branch is found by position,
+// since the file's tree was parsed separately from the one running.
+func (c *Coverage) Branch(node ast.Node, taken int, env *object.Env) {
+	f := c.file(env.File)
+	if f == nil {
+		return
+	}
+	b, ok := f.branches[node]
+	if !ok {
+		tok := ast.StartToken(node)
+		b = f.positions[[2]int{tok.Line, tok.Column}]
+		f.branches[node] = b
+	}
+	if b != nil && taken < len(b.Taken) {
+		b.Taken[taken]++
+	}
+}
+
+// Call implements evaluator.Tracer
+func (c *Coverage) Call(fn *object.Function, env *object.Env) {}
+
+// Return implements evaluator.Tracer
+func (c *Coverage) Return(fn *object.Function, result object.Object) {}
+
+// Load implements evaluator.Tracer
+func (c *Coverage) Load(path string, env *object.Env) {}
+
+// Loaded implements evaluator.Tracer
+func (c *Coverage) Loaded(path string) {}
+
+// CallBuiltin implements evaluator.Tracer
+func (c *Coverage) CallBuiltin(name string) {}
+
+// ReturnBuiltin implements evaluator.Tracer
+func (c *Coverage) ReturnBuiltin(name string) {}
+
+// Summary counts what was covered
+type Summary struct {
+	Lines, LinesHit       int
+	Branches, BranchesHit int // arms of branches
+}
+
+func (s *Summary) add(o Summary) {
+	s.Lines += o.Lines
+	s.LinesHit += o.LinesHit
+	s.Branches += o.Branches
+	s.BranchesHit += o.BranchesHit
+}
+
+// Summary counts the file's statement lines and branch arms, and how many
+// of them ran
+func (f *File) Summary() Summary {
+	s := Summary{Lines: len(f.Lines)}
+	for _, count := range f.Lines {
+		if count > 0 {
+			s.LinesHit++
+		}
+	}
+	for _, b := range f.Branches {
+		s.Branches += len(b.Taken)
+		for _, count := range b.Taken {
+			if count > 0 {
+				s.BranchesHit++
+			}
+		}
+	}
+	return s
+}
+
+// Missed lists the statement lines that never ran, as ranges such as 4-7
+func (f *File) Missed() []string {
+	lines := []int{}
+	for line, count := range f.Lines {
+		if count == 0 {
+			lines = append(lines, line)
+		}
+	}
+	sort.Ints(lines)
+
+	// Consecutive statement lines, ignoring lines without statements, form
+	// one range
+	ranges := []string{}
+	for i := 0; i < len(lines); {
+		j := i
+		for j+1 < len(lines) && f.noneRanBetween(lines[j], lines[j+1]) {
+			j++
+		}
+		if i == j {
+			ranges = append(ranges, fmt.Sprint(lines[i]))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", lines[i], lines[j]))
+		}
+		i = j + 1
+	}
+	return ranges
+}
+
+func (f *File) noneRanBetween(from, to int) bool {
+	for line := from + 1; line < to; line++ {
+		if count, ok := f.Lines[line]; ok && count > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func percent(hit, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(hit) / float64(total)
+}
@@ -0,0 +1,169 @@
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteText writes each file's line and branch coverage, the lines that
+// never ran, and the totals. Paths are shown through display.
+func (c *Coverage) WriteText(w io.Writer, display func(path string) string) {
+	files := c.Files()
+	if len(files) == 0 {
+		fmt.Fprintln(w, "No files covered")
+		return
+	}
+
+	var total Summary
+	for _, f := range files {
+		s := f.Summary()
+		total.add(s)
+		fmt.Fprintf(w, "%-40s lines %5.1f%% (%d/%d)  branches %5.1f%% (%d/%d)\n",
+			display(f.Path), percent(s.LinesHit, s.Lines), s.LinesHit, s.Lines,
+			percent(s.BranchesHit, s.Branches), s.BranchesHit, s.Branches)
+		if missed := f.Missed(); len(missed) > 0 {
+			fmt.Fprintf(w, "    not run: %s\n", strings.Join(missed, ", "))
+		}
+	}
+	fmt.Fprintf(w, "%-40s lines %5.1f%% (%d/%d)  branches %5.1f%% (%d/%d)\n",
+		"total", percent(total.LinesHit, total.Lines), total.LinesHit, total.Lines,
+		percent(total.BranchesHit, total.Branches), total.BranchesHit, total.Branches)
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, the format read by
+// genhtml and coverage services
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "TN:")
+	for _, f := range c.Files() {
+		fmt.Fprintf(out, "SF:%s\n", f.Path)
+
+		s := f.Summary()
+		for block, b := range f.Branches {
+			ran := false
+			for _, count := range b.Taken {
+				ran = ran || count > 0
+			}
+			for arm, count := range b.Taken {
+				taken := "-" // the branch itself never ran
+				if ran {
+					taken = fmt.Sprint(count)
+				}
+				fmt.Fprintf(out, "BRDA:%d,%d,%d,%s\n", b.Line, block, arm, taken)
+			}
+		}
+		fmt.Fprintf(out, "BRF:%d\nBRH:%d\n", s.Branches, s.BranchesHit)
+
+		for _, line := range f.lineNumbers() {
+			fmt.Fprintf(out, "DA:%d,%d\n", line, f.Lines[line])
+		}
+		fmt.Fprintf(out, "LF:%d\nLH:%d\n", s.Lines, s.LinesHit)
+		fmt.Fprintln(out, "end_of_record")
+	}
+	return out.Flush()
+}
+
+func (f *File) lineNumbers() []int {
+	lines := make([]int, 0, len(f.Lines))
+	for line := range f.Lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// htmlLine is one line of source on the HTML page
+type htmlLine struct {
+	Number int
+	Text   string
+	Class  string // covered, missed, partial or empty for no statement
+	Count  string
+	Title  string // the branches on the line and how often each arm ran
+}
+
+type htmlFile struct {
+	ID, Name string
+	Summary  Summary
+	Lines    []htmlLine
+}
+
+var htmlPage = template.Must(template.New("coverage").Funcs(template.FuncMap{
+	"percent": percent,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lynx coverage</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table.summary td, table.summary th { padding: 0.2em 1em; text-align: left; }
+pre { font-family: monospace; line-height: 1.3; margin: 0; }
+.source { border: 1px solid #ccc; margin-bottom: 2em; }
+.line { display: flex; }
+.number, .count { color: #888; text-align: right; padding: 0 0.8em; user-select: none; }
+.number { width: 3em; }
+.count { width: 4em; }
+.covered { background: #dfd; }
+.missed { background: #fdd; }
+.partial { background: #ffc; }
+</style>
+</head>
+<body>
+<h1>Coverage</h1>
+<table class="summary">
+<tr><th>File</th><th>Lines</th><th>Branches</th></tr>
+{{range .}}<tr><td><a href="#{{.ID}}">{{.Name}}</a></td>
+<td>{{printf "%.1f" (percent .Summary.LinesHit .Summary.Lines)}}% ({{.Summary.LinesHit}}/{{.Summary.Lines}})</td>
+<td>{{printf "%.1f" (percent .Summary.BranchesHit .Summary.Branches)}}% ({{.Summary.BranchesHit}}/{{.Summary.Branches}})</td></tr>
+{{end}}</table>
+{{range .}}<h2 id="{{.ID}}">{{.Name}}</h2>
+<div class="source">
+{{range .Lines}}<div class="line {{.Class}}"{{if .Title}} title="{{.Title}}"{{end}}><span class="number">{{.Number}}</span><span class="count">{{.Count}}</span><pre>{{.Text}}</pre></div>
+{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes a page with each file's source, its lines colored by
+// whether they ran and marked with how often. Lines whose branches were
+// not all taken are marked partial. Paths are shown through display.
+func (c *Coverage) WriteHTML(w io.Writer, display func(path string) string) error {
+	files := []htmlFile{}
+	for i, f := range c.Files() {
+		branches := map[int][]*Branch{}
+		for _, b := range f.Branches {
+			branches[b.Line] = append(branches[b.Line], b)
+		}
+
+		hf := htmlFile{ID: fmt.Sprintf("file%d", i), Name: display(f.Path), Summary: f.Summary()}
+		for n, text := range f.Source {
+			line := htmlLine{Number: n + 1, Text: text}
+			if count, ok := f.Lines[n+1]; ok {
+				line.Count = fmt.Sprint(count)
+				line.Class = "missed"
+				if count > 0 {
+					line.Class = "covered"
+				}
+			}
+			titles := []string{}
+			for _, b := range branches[n+1] {
+				arms := []string{}
+				for _, taken := range b.Taken {
+					arms = append(arms, fmt.Sprint(taken))
+					if taken == 0 && line.Class == "covered" {
+						line.Class = "partial"
+					}
+				}
+				titles = append(titles, fmt.Sprintf("%s branches taken %s times", b.Kind, strings.Join(arms, "/")))
+			}
+			line.Title = strings.Join(titles, "; ")
+			hf.Lines = append(hf.Lines, line)
+		}
+		files = append(files, hf)
+	}
+	return htmlPage.Execute(w, files)
+}
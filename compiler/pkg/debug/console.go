@@ -0,0 +1,287 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"lynx/pkg/evaluator"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Console is a Frontend that takes gdb-style commands from a terminal
+// whenever the program stops
+type Console struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	debugger *Debugger
+	selected int // index into the frames, innermost first
+	last     string
+	sources  map[string][]string
+}
+
+type consoleCommand struct {
+	names   []string
+	args    string
+	summary string
+	// run returns the action to resume with, or false to keep reading
+	run func(c *Console, arg string) (Action, bool)
+}
+
+var consoleCommands []consoleCommand
+
+func init() {
+	consoleCommands = []consoleCommand{
+		{[]string{"continue", "c"}, "", "run to the next breakpoint", resume(Continue)},
+		{[]string{"next", "n"}, "", "run to the next line, stepping over calls", resume(StepOver)},
+		{[]string{"step", "s"}, "", "run to the next line, stepping into calls", resume(StepIn)},
+		{[]string{"finish", "out"}, "", "run until the current function returns", resume(StepOut)},
+		{[]string{"break", "b"}, "[[file:]line [if cond]]", "set a breakpoint, or list them", (*Console).setBreakpoint},
+		{[]string{"clear"}, "[file:]line", "remove a breakpoint", (*Console).clearBreakpoint},
+		{[]string{"backtrace", "bt", "where"}, "", "show the call stack", (*Console).backtrace},
+		{[]string{"up"}, "", "select the calling frame", (*Console).up},
+		{[]string{"down"}, "", "select the called frame", (*Console).down},
+		{[]string{"frame", "f"}, "<n>", "select a frame by its backtrace number", (*Console).frame},
+		{[]string{"vars", "v"}, "", "show the variables visible in the selected frame", (*Console).vars},
+		{[]string{"print", "p"}, "<expr>", "evaluate an expression in the selected frame", (*Console).print},
+		{[]string{"list", "l"}, "", "show the source around the current line", (*Console).list},
+		{[]string{"quit", "q"}, "", "end the program", resume(Stop)},
+		{[]string{"help", "h"}, "", "show this help", (*Console).help},
+	}
+}
+
+func resume(action Action) func(*Console, string) (Action, bool) {
+	return func(*Console, string) (Action, bool) { return action, true }
+}
+
+func NewConsole(in io.Reader, out io.Writer) *Console {
+	return &Console{in: bufio.NewScanner(in), out: out, sources: make(map[string][]string)}
+}
+
+// Stopped implements Frontend. The end of input resumes the program with
+// its breakpoints cleared, so it runs to completion.
+func (c *Console) Stopped(d *Debugger, reason string) Action {
+	c.debugger = d
+	c.selected = 0
+	frame := c.frame0()
+	fmt.Fprintf(c.out, "Stopped (%s) at %s:%d in %s\n", reason, displayPath(frame.File), frame.Line, frame.Name)
+	c.showLine(frame.File, frame.Line, true)
+
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			fmt.Fprintln(c.out)
+			for _, bp := range d.Breakpoints() {
+				d.ClearBreakpoint(bp.File, bp.Line)
+			}
+			return Continue
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.last // an empty line repeats the last command
+		}
+		if line == "" {
+			continue
+		}
+		c.last = line
+
+		name, arg, _ := strings.Cut(line, " ")
+		cmd, ok := lookupCommand(name)
+		if !ok {
+			fmt.Fprintf(c.out, "Unknown command %q; type help for a list\n", name)
+			continue
+		}
+		if action, done := cmd.run(c, strings.TrimSpace(arg)); done {
+			return action
+		}
+	}
+}
+
+func lookupCommand(name string) (consoleCommand, bool) {
+	for _, cmd := range consoleCommands {
+		for _, n := range cmd.names {
+			if n == name {
+				return cmd, true
+			}
+		}
+	}
+	return consoleCommand{}, false
+}
+
+func (c *Console) frame0() *Frame {
+	return c.debugger.Frames()[c.selected]
+}
+
+// location parses [file:]line, defaulting to the selected frame's file
+func (c *Console) location(arg string) (string, int, error) {
+	file := c.frame0().File
+	lineText := arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, lineText = arg[:i], arg[i+1:]
+	}
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		return "", 0, fmt.Errorf("expected [file:]line, got %q", arg)
+	}
+	return file, line, nil
+}
+
+func (c *Console) setBreakpoint(arg string) (Action, bool) {
+	if arg == "" {
+		bps := c.debugger.Breakpoints()
+		if len(bps) == 0 {
+			fmt.Fprintln(c.out, "No breakpoints")
+		}
+		for _, bp := range bps {
+			fmt.Fprintf(c.out, "%s:%d", displayPath(bp.File), bp.Line)
+			if bp.Condition != "" {
+				fmt.Fprintf(c.out, " if %s", bp.Condition)
+			}
+			fmt.Fprintln(c.out)
+		}
+		return 0, false
+	}
+
+	where, condition, _ := strings.Cut(arg, " if ")
+	file, line, err := c.location(strings.TrimSpace(where))
+	if err == nil {
+		_, err = c.debugger.SetBreakpoint(file, line, strings.TrimSpace(condition))
+	}
+	if err != nil {
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+		return 0, false
+	}
+	fmt.Fprintf(c.out, "Breakpoint at %s:%d\n", displayPath(file), line)
+	return 0, false
+}
+
+func (c *Console) clearBreakpoint(arg string) (Action, bool) {
+	file, line, err := c.location(arg)
+	switch {
+	case err != nil:
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+	case !c.debugger.ClearBreakpoint(file, line):
+		fmt.Fprintf(c.out, "No breakpoint at %s:%d\n", displayPath(file), line)
+	}
+	return 0, false
+}
+
+func (c *Console) backtrace(string) (Action, bool) {
+	for i, f := range c.debugger.Frames() {
+		marker := " "
+		if i == c.selected {
+			marker = "*"
+		}
+		fmt.Fprintf(c.out, "%s #%d %s at %s:%d\n", marker, i, f.Name, displayPath(f.File), f.Line)
+	}
+	return 0, false
+}
+
+func (c *Console) up(string) (Action, bool) {
+	return c.selectFrame(c.selected + 1)
+}
+
+func (c *Console) down(string) (Action, bool) {
+	return c.selectFrame(c.selected - 1)
+}
+
+func (c *Console) frame(arg string) (Action, bool) {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		fmt.Fprintf(c.out, "Error: expected a frame number, got %q\n", arg)
+		return 0, false
+	}
+	return c.selectFrame(n)
+}
+
+func (c *Console) selectFrame(n int) (Action, bool) {
+	if n < 0 || n >= len(c.debugger.Frames()) {
+		fmt.Fprintln(c.out, "No such frame")
+		return 0, false
+	}
+	c.selected = n
+	f := c.frame0()
+	fmt.Fprintf(c.out, "#%d %s at %s:%d\n", n, f.Name, displayPath(f.File), f.Line)
+	c.showLine(f.File, f.Line, true)
+	return 0, false
+}
+
+func (c *Console) vars(string) (Action, bool) {
+	for _, scope := range Scopes(c.frame0()) {
+		fmt.Fprintf(c.out, "%s:\n", scope.Name)
+		for _, name := range scope.Env.Names() {
+			val, _ := scope.Env.Get(name)
+			fmt.Fprintf(c.out, "  %s = %s\n", name, describe(val))
+		}
+	}
+	return 0, false
+}
+
+func (c *Console) print(arg string) (Action, bool) {
+	val, err := c.debugger.Evaluate(arg, c.frame0())
+	if err != nil {
+		fmt.Fprintf(c.out, "Error: %v\n", err)
+		return 0, false
+	}
+	fmt.Fprintln(c.out, describe(val))
+	return 0, false
+}
+
+func (c *Console) list(string) (Action, bool) {
+	f := c.frame0()
+	for line := max(f.Line-5, 1); line <= f.Line+5; line++ {
+		if !c.showLine(f.File, line, line == f.Line) {
+			break
+		}
+	}
+	return 0, false
+}
+
+func (c *Console) help(string) (Action, bool) {
+	for _, cmd := range consoleCommands {
+		usage := strings.Join(cmd.names, ", ")
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(c.out, "  %-38s %s\n", usage, cmd.summary)
+	}
+	return 0, false
+}
+
+// showLine prints a numbered source line, reporting whether it exists
+func (c *Console) showLine(file string, line int, current bool) bool {
+	lines, ok := c.sources[file]
+	if !ok {
+		if source, err := evaluator.ReadModule(file); err == nil {
+			lines = strings.Split(string(source), "\n")
+		}
+		c.sources[file] = lines
+	}
+	if line < 1 || line > len(lines) {
+		return false
+	}
+	marker := " "
+	if current {
+		marker = ">"
+	}
+	fmt.Fprintf(c.out, "%s %4d  %s\n", marker, line, lines[line-1])
+	return true
+}
+
+// displayPath shortens paths under the working directory
+func displayPath(file string) string {
+	if file == "" {
+		return "<input>"
+	}
+	wd, err := os.Getwd()
+	if err != nil || !filepath.IsAbs(file) {
+		return file
+	}
+	if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return file
+}
@@ -0,0 +1,554 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"lynx/pkg/project"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DAP serves the Debug Adapter Protocol to one editor session. The
+// program runs on its own goroutine; while it is stopped, the session
+// answers stack, scope and variable requests and waits for a step or
+// continue.
+type DAP struct {
+	in  *bufio.Reader
+	out io.Writer
+	wmu sync.Mutex // serializes writes from the session and the program
+	seq int
+
+	debugger *Debugger
+	launch   launchArgs
+	resume   chan Action
+
+	mu          sync.Mutex
+	stopped     bool
+	terminating bool
+	handles     []any // variable containers, valid until the program resumes
+}
+
+// dapMessage is a request from the client
+type dapMessage struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"`
+	RequestSeq int    `json:"request_seq"`
+	Success    bool   `json:"success"`
+	Command    string `json:"command"`
+	Message    string `json:"message,omitempty"`
+	Body       any    `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int    `json:"seq"`
+	Type  string `json:"type"`
+	Event string `json:"event"`
+	Body  any    `json:"body,omitempty"`
+}
+
+type launchArgs struct {
+	Program     string   `json:"program"`
+	Args        []string `json:"args"`
+	StopOnEntry bool     `json:"stopOnEntry"`
+}
+
+type dapSource struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// The program runs on a single thread as far as the client is concerned
+const threadID = 1
+
+func NewDAP(in io.Reader, out io.Writer) *DAP {
+	s := &DAP{in: bufio.NewReader(in), out: out, resume: make(chan Action)}
+	s.debugger = New(s)
+	return s
+}
+
+// Run serves requests until the client disconnects or closes the input
+func (s *DAP) Run() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req dapMessage
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("invalid message: %v", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if !s.handle(req) {
+			return nil
+		}
+	}
+}
+
+func (s *DAP) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	return body, err
+}
+
+func (s *DAP) write(message func(seq int) any) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	s.seq++
+	body, err := json.Marshal(message(s.seq))
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *DAP) respond(req dapMessage, body any) {
+	s.write(func(seq int) any {
+		return dapResponse{Seq: seq, Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body}
+	})
+}
+
+func (s *DAP) fail(req dapMessage, format string, a ...any) {
+	s.write(func(seq int) any {
+		return dapResponse{Seq: seq, Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: fmt.Sprintf(format, a...)}
+	})
+}
+
+func (s *DAP) event(name string, body any) {
+	s.write(func(seq int) any {
+		return dapEvent{Seq: seq, Type: "event", Event: name, Body: body}
+	})
+}
+
+// Output returns a writer whose writes reach the client as output events
+// of a category such as "stdout" or "stderr"
+func (s *DAP) Output(category string) io.Writer {
+	return outputWriter{s, category}
+}
+
+type outputWriter struct {
+	s        *DAP
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", map[string]string{"category": w.category, "output": string(p)})
+	return len(p), nil
+}
+
+// handle answers a request, returning false once the session is over
+func (s *DAP) handle(req dapMessage) bool {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		if err := json.Unmarshal(req.Arguments, &s.launch); err != nil || s.launch.Program == "" {
+			s.fail(req, "launch needs the path of a program")
+			return true
+		}
+		s.respond(req, nil)
+	case "setBreakpoints":
+		s.setBreakpoints(req)
+	case "setExceptionBreakpoints":
+		s.respond(req, map[string]any{"breakpoints": []any{}})
+	case "configurationDone":
+		s.respond(req, nil)
+		go s.runProgram()
+	case "threads":
+		s.respond(req, map[string]any{"threads": []map[string]any{{"id": threadID, "name": "main"}}})
+	case "stackTrace":
+		s.whileStopped(req, s.stackTrace)
+	case "scopes":
+		s.whileStopped(req, s.scopes)
+	case "variables":
+		s.whileStopped(req, s.variables)
+	case "evaluate":
+		s.whileStopped(req, s.evaluate)
+	case "continue":
+		s.step(req, Continue)
+	case "next":
+		s.step(req, StepOver)
+	case "stepIn":
+		s.step(req, StepIn)
+	case "stepOut":
+		s.step(req, StepOut)
+	case "pause":
+		s.debugger.Pause()
+		s.respond(req, nil)
+	case "terminate", "disconnect":
+		s.mu.Lock()
+		s.terminating = true
+		stopped := s.stopped
+		s.mu.Unlock()
+		if stopped {
+			s.resume <- Stop
+		} else {
+			s.debugger.Pause()
+		}
+		s.respond(req, nil)
+		return req.Command != "disconnect"
+	default:
+		s.fail(req, "unsupported request %s", req.Command)
+	}
+	return true
+}
+
+func (s *DAP) setBreakpoints(req dapMessage) {
+	var args struct {
+		Source      dapSource `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(req.Arguments, &args); err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+
+	s.debugger.ClearFile(args.Source.Path)
+	results := []map[string]any{}
+	for _, b := range args.Breakpoints {
+		result := map[string]any{"verified": true, "line": b.Line}
+		if _, err := s.debugger.SetBreakpoint(args.Source.Path, b.Line, b.Condition); err != nil {
+			result["verified"] = false
+			result["message"] = err.Error()
+		}
+		results = append(results, result)
+	}
+	s.respond(req, map[string]any{"breakpoints": results})
+}
+
+// Stopped implements Frontend by telling the client and waiting for it to
+// resume the program
+func (s *DAP) Stopped(d *Debugger, reason string) Action {
+	s.mu.Lock()
+	if s.terminating {
+		s.mu.Unlock()
+		return Stop
+	}
+	s.stopped = true
+	s.handles = nil
+	s.mu.Unlock()
+
+	s.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true})
+	action := <-s.resume
+
+	s.mu.Lock()
+	s.stopped = false
+	s.mu.Unlock()
+	return action
+}
+
+func (s *DAP) step(req dapMessage, action Action) {
+	s.mu.Lock()
+	stopped := s.stopped
+	s.mu.Unlock()
+	if !stopped {
+		s.fail(req, "the program is running")
+		return
+	}
+	if action == Continue {
+		s.respond(req, map[string]bool{"allThreadsContinued": true})
+	} else {
+		s.respond(req, nil)
+	}
+	s.resume <- action
+}
+
+// whileStopped answers a request about the program's state, which can only
+// be inspected while it is stopped
+func (s *DAP) whileStopped(req dapMessage, answer func(json.RawMessage) (any, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		s.fail(req, "the program is running")
+		return
+	}
+	body, err := answer(req.Arguments)
+	if err != nil {
+		s.fail(req, "%v", err)
+		return
+	}
+	s.respond(req, body)
+}
+
+func (s *DAP) runProgram() {
+	exitCode := s.execute()
+	s.event("exited", map[string]int{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+// execute runs the launched program and returns its exit status the way
+// lynx run would
+func (s *DAP) execute() int {
+	stderr := s.Output("stderr")
+	path, err := filepath.Abs(s.launch.Program)
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	var source []byte
+	if err == nil {
+		source, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Error reading file: %v\n", err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		for _, e := range p.Errors() {
+			fmt.Fprintf(stderr, "Parser error: %s\n", e)
+		}
+		return 1
+	}
+
+	// Output becomes output events, since stdout carries the protocol
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(s.Output("stdout"), r)
+		close(copied)
+	}()
+	defer func() {
+		os.Stdout = stdout
+		w.Close()
+		<-copied
+		r.Close()
+	}()
+
+	dir := filepath.Dir(path)
+	if m, err := project.Find(dir); err == nil && m != nil {
+		evaluator.ModuleRoots = m.ModuleRoots()
+	}
+	evaluator.ScriptArgs = s.launch.Args
+	evaluator.RegisterBuiltins()
+	env := object.New(dir)
+	env.File = path
+
+	switch result := s.debugger.Run(program, env, s.launch.StopOnEntry).(type) {
+	case nil:
+		return 1
+	case *object.Error:
+		fmt.Fprintf(stderr, "Error: %s\n", result.Message)
+		return 1
+	case *object.Integer:
+		return int(result.Value)
+	}
+	return 0
+}
+
+func (s *DAP) frameArg(args json.RawMessage) (*Frame, error) {
+	var a struct {
+		FrameID *int `json:"frameId"`
+	}
+	json.Unmarshal(args, &a)
+	frames := s.debugger.Frames()
+	id := 0
+	if a.FrameID != nil {
+		id = *a.FrameID
+	}
+	if id < 0 || id >= len(frames) {
+		return nil, fmt.Errorf("no frame %d", id)
+	}
+	return frames[id], nil
+}
+
+func (s *DAP) stackTrace(json.RawMessage) (any, error) {
+	frames := []map[string]any{}
+	for i, f := range s.debugger.Frames() {
+		frame := map[string]any{"id": i, "name": f.Name, "line": f.Line, "column": f.Column}
+		if f.File != "" {
+			frame["source"] = dapSource{Name: filepath.Base(f.File), Path: f.File}
+		}
+		frames = append(frames, frame)
+	}
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+func (s *DAP) scopes(args json.RawMessage) (any, error) {
+	frame, err := s.frameArg(args)
+	if err != nil {
+		return nil, err
+	}
+	scopes := []map[string]any{}
+	for _, scope := range Scopes(frame) {
+		scopes = append(scopes, map[string]any{
+			"name":               scope.Name,
+			"variablesReference": s.reference(scope.Env),
+			"expensive":          scope.Name == "Globals",
+		})
+	}
+	return map[string]any{"scopes": scopes}, nil
+}
+
+// reference registers a container whose children the client may ask for
+func (s *DAP) reference(container any) int {
+	s.handles = append(s.handles, container)
+	return len(s.handles)
+}
+
+func (s *DAP) variables(args json.RawMessage) (any, error) {
+	var a struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	json.Unmarshal(args, &a)
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.handles) {
+		return nil, fmt.Errorf("unknown variables reference %d", a.VariablesReference)
+	}
+
+	vars := []dapVariable{}
+	add := func(name string, val object.Object) {
+		vars = append(vars, s.variable(name, val))
+	}
+	switch c := s.handles[a.VariablesReference-1].(type) {
+	case *object.Env:
+		for _, name := range c.Names() {
+			val, _ := c.Get(name)
+			add(name, val)
+		}
+	case *object.Array:
+		for i, el := range c.Elements {
+			add(fmt.Sprintf("[%d]", i), el)
+		}
+	case *object.Tuple:
+		for i, el := range c.Elements {
+			add(fmt.Sprintf("[%d]", i), el)
+		}
+	case *object.Hash:
+		for _, pair := range c.Pairs {
+			add(object.Repr(pair.Key), pair.Value)
+		}
+		sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	case *object.Instance:
+		for _, name := range sortedKeys(c.Attributes) {
+			add(name, c.Attributes[name])
+		}
+	case *object.Module:
+		if c.Members != nil {
+			for _, name := range sortedKeys(c.Members) {
+				add(name, c.Members[name])
+			}
+		} else if c.Env != nil {
+			for _, name := range c.Env.Names() {
+				if c.Exports == nil || c.Exports[name] {
+					val, _ := c.Env.Get(name)
+					add(name, val)
+				}
+			}
+		}
+	}
+	return map[string]any{"variables": vars}, nil
+}
+
+// variable describes a value, registering it when it has children
+func (s *DAP) variable(name string, val object.Object) dapVariable {
+	v := dapVariable{Name: name, Value: describe(val), Type: strings.ToLower(string(val.Type()))}
+	switch c := val.(type) {
+	case *object.Array:
+		if len(c.Elements) > 0 {
+			v.VariablesReference = s.reference(c)
+		}
+	case *object.Tuple:
+		v.VariablesReference = s.reference(c)
+	case *object.Hash:
+		if len(c.Pairs) > 0 {
+			v.VariablesReference = s.reference(c)
+		}
+	case *object.Instance:
+		v.Type = c.Class.Name
+		if len(c.Attributes) > 0 {
+			v.VariablesReference = s.reference(c)
+		}
+	case *object.Module:
+		v.VariablesReference = s.reference(c)
+	}
+	return v
+}
+
+func sortedKeys(m map[string]object.Object) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *DAP) evaluate(args json.RawMessage) (any, error) {
+	var a struct {
+		Expression string `json:"expression"`
+	}
+	json.Unmarshal(args, &a)
+	frame, err := s.frameArg(args)
+	if err != nil {
+		return nil, err
+	}
+	val, err := s.debugger.Evaluate(a.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+	v := s.variable("", val)
+	return map[string]any{"result": v.Value, "type": v.Type, "variablesReference": v.VariablesReference}, nil
+}
@@ -0,0 +1,391 @@
+// Package debug implements a step debugger for Lynx programs on top of the
+// evaluator's Tracer hook. A Frontend decides what happens whenever the
+// program stops; the package provides an interactive console and a Debug
+// Adapter Protocol server for editors.
+package debug
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Action tells a stopped program how to go on
+type Action int
+
+const (
+	Continue Action = iota // run to the next breakpoint
+	StepIn                 // stop at the next line, entering calls
+	StepOver               // stop at the next line of this or a calling function
+	StepOut                // stop once the current function has returned
+	Stop                   // end the program
+)
+
+// stopped unwinds the evaluator when the program is ended from a stop
+type stopped struct{}
+
+// Frontend is told each time the program stops, with a reason such as
+// "breakpoint" or "step", and returns how to resume. The program stays
+// stopped, and its frames can be inspected, until Stopped returns.
+type Frontend interface {
+	Stopped(d *Debugger, reason string) Action
+}
+
+// Frame is a call in progress: the function and the statement it is at
+type Frame struct {
+	Name   string
+	File   string
+	Line   int
+	Column int
+	Env    *object.Env // innermost scope of the current statement
+
+	stmt ast.Statement
+}
+
+// Breakpoint stops the program on reaching Line of File, if Condition is
+// empty or evaluates to a truthy value there. A File without a directory
+// matches any file of that name.
+type Breakpoint struct {
+	File      string
+	Line      int
+	Condition string
+	cond      ast.Expression
+}
+
+// Debugger controls a running program. Breakpoints may be changed and a
+// pause requested from another goroutine while it runs.
+type Debugger struct {
+	frontend Frontend
+
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+
+	frames []*Frame
+	action Action
+	depth  int // frames when the action was chosen
+	entry  bool
+	pause  atomic.Bool
+}
+
+func New(frontend Frontend) *Debugger {
+	return &Debugger{frontend: frontend}
+}
+
+// Run evaluates program in env under the debugger and returns its result,
+// or nil if the frontend ended it. With stopOnEntry the program stops
+// before its first statement.
+func (d *Debugger) Run(program *ast.Program, env *object.Env, stopOnEntry bool) (result object.Object) {
+	d.frames = []*Frame{{Name: "main", File: env.File, Env: env}}
+	d.action = Continue
+	d.entry = stopOnEntry
+
+	evaluator.Trace = d
+	defer func() {
+		evaluator.Trace = nil
+		if r := recover(); r != nil {
+			if _, ok := r.(stopped); !ok {
+				panic(r)
+			}
+			evaluator.ResetModules()
+			result = nil
+		}
+	}()
+	return evaluator.Eval(program, env)
+}
+
+// SetBreakpoint adds a breakpoint, replacing any other on the same line
+func (d *Debugger) SetBreakpoint(file string, line int, condition string) (*Breakpoint, error) {
+	file = resolvePath(file)
+	bp := &Breakpoint{File: file, Line: line, Condition: condition}
+	if condition != "" {
+		cond, err := parseExpression(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.cond = cond
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.removeLocked(file, line)
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp, nil
+}
+
+// ClearBreakpoint removes the breakpoint on a line, reporting whether there
+// was one
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	file = resolvePath(file)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.removeLocked(file, line)
+}
+
+// ClearFile removes every breakpoint in file
+func (d *Debugger) ClearFile(file string) {
+	file = resolvePath(file)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.breakpoints[:0]
+	for _, bp := range d.breakpoints {
+		if bp.File != file {
+			kept = append(kept, bp)
+		}
+	}
+	d.breakpoints = kept
+}
+
+func (d *Debugger) removeLocked(file string, line int) bool {
+	for i, bp := range d.breakpoints {
+		if bp.File == file && bp.Line == line {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the breakpoints ordered by file and line
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bps := append([]*Breakpoint{}, d.breakpoints...)
+	sort.Slice(bps, func(i, j int) bool {
+		if bps[i].File != bps[j].File {
+			return bps[i].File < bps[j].File
+		}
+		return bps[i].Line < bps[j].Line
+	})
+	return bps
+}
+
+// Pause stops the program at the next statement
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// Frames returns the calls in progress, innermost first. It is only
+// meaningful while the program is stopped.
+func (d *Debugger) Frames() []*Frame {
+	frames := make([]*Frame, len(d.frames))
+	for i, f := range d.frames {
+		frames[len(frames)-1-i] = f
+	}
+	return frames
+}
+
+// Statement implements evaluator.Tracer. Execution stops only on arriving
+// at a line: from another line, or by a loop running a statement again.
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Env) {
+	tok := ast.StartToken(stmt)
+	top := d.frames[len(d.frames)-1]
+	arrived := tok.Line != top.Line || env.File != top.File || stmt == top.stmt
+	top.File, top.Line, top.Column, top.Env, top.stmt = env.File, tok.Line, tok.Column, env, stmt
+	if !arrived || tok.Line == 0 {
+		return
+	}
+
+	reason := ""
+	depth := len(d.frames)
+	switch {
+	case d.entry:
+		reason = "entry"
+		d.entry = false
+	case d.pause.Swap(false):
+		reason = "pause"
+	case d.hitBreakpoint(env.File, tok.Line, env):
+		reason = "breakpoint"
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		reason = "step"
+	default:
+		return
+	}
+
+	d.action = d.frontend.Stopped(d, reason)
+	d.depth = len(d.frames)
+	if d.action == Stop {
+		panic(stopped{})
+	}
+}
+
+// Call implements evaluator.Tracer
+func (d *Debugger) Call(fn *object.Function, env *object.Env) {
+	d.frames = append(d.frames, &Frame{Name: evaluator.CallName(fn), File: env.File, Env: env})
+}
+
+// Return implements evaluator.Tracer
+func (d *Debugger) Return(fn *object.Function, result object.Object) {
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// Load implements evaluator.Tracer. A module's top level gets a frame of
+// its own, so stepping over an import skips it.
+func (d *Debugger) Load(path string, env *object.Env) {
+	name := "<module " + strings.TrimSuffix(filepath.Base(path), ".lynx") + ">"
+	d.frames = append(d.frames, &Frame{Name: name, File: path, Env: env})
+}
+
+// Loaded implements evaluator.Tracer
+func (d *Debugger) Loaded(path string) {
+	d.frames = d.frames[:len(d.frames)-1]
+}
+
+// CallBuiltin implements evaluator.Tracer; builtins have no statements to
+// stop at
+func (d *Debugger) CallBuiltin(name string) {}
+
+// ReturnBuiltin implements evaluator.Tracer
+func (d *Debugger) ReturnBuiltin(name string) {}
+
+// Branch implements evaluator.Tracer
+func (d *Debugger) Branch(node ast.Node, taken int, env *object.Env) {}
+
+func (d *Debugger) hitBreakpoint(file string, line int, env *object.Env) bool {
+	d.mu.Lock()
+	var hit *Breakpoint
+	for _, bp := range d.breakpoints {
+		if bp.Line == line && matchesFile(bp.File, file) {
+			hit = bp
+			break
+		}
+	}
+	d.mu.Unlock()
+
+	if hit == nil {
+		return false
+	}
+	if hit.cond == nil {
+		return true
+	}
+	// A condition that fails to evaluate stops the program, so the mistake
+	// is noticed
+	val := d.eval(hit.cond, env)
+	return isError(val) || isTruthy(val)
+}
+
+// resolvePath follows symlinks in absolute paths, as the evaluator does
+// for the files it loads
+func resolvePath(file string) string {
+	if filepath.IsAbs(file) {
+		if resolved, err := filepath.EvalSymlinks(file); err == nil {
+			return resolved
+		}
+	}
+	return file
+}
+
+func matchesFile(pattern, file string) bool {
+	if pattern == file {
+		return true
+	}
+	if pattern == "" || file == "" {
+		return false
+	}
+	if !strings.ContainsAny(pattern, `/\`) {
+		return filepath.Base(file) == pattern
+	}
+	return strings.HasSuffix(filepath.ToSlash(file), "/"+strings.TrimPrefix(filepath.ToSlash(pattern), "./"))
+}
+
+// Evaluate evaluates an expression in the scope of a stopped frame
+func (d *Debugger) Evaluate(expr string, frame *Frame) (object.Object, error) {
+	e, err := parseExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	val := d.eval(e, frame.Env)
+	if errObj, ok := val.(*object.Error); ok {
+		return nil, fmt.Errorf("%s", errObj.Message)
+	}
+	return val, nil
+}
+
+// eval runs code for the debugger itself, which must not stop
+func (d *Debugger) eval(e ast.Expression, env *object.Env) object.Object {
+	evaluator.Trace = nil
+	defer func() { evaluator.Trace = d }()
+	return evaluator.Eval(e, env)
+}
+
+func parseExpression(src string) (ast.Expression, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("%s", p.ErrorStrings()[0])
+	}
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("expected a single expression")
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression")
+	}
+	return stmt.Expression, nil
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Null:
+		return false
+	case *object.Boolean:
+		return obj.Value
+	}
+	return true
+}
+
+// describe renders a value for display: source form for data, and a short
+// summary for functions, classes and modules
+func describe(val object.Object) string {
+	switch v := val.(type) {
+	case *object.Function:
+		params := []string{}
+		for _, p := range v.Parameters {
+			params = append(params, p.Value)
+		}
+		return "fn " + v.Name + "(" + strings.Join(params, ", ") + ")"
+	case *object.Class:
+		return "class " + v.Name
+	case *object.Module:
+		return "module " + v.Name
+	case *object.Builtin:
+		return "builtin"
+	}
+	return object.Repr(val)
+}
+
+// Scope is one environment in the chain a frame can see
+type Scope struct {
+	Name string
+	Env  *object.Env
+}
+
+// Scopes lists the environments visible from frame, innermost first
+func Scopes(frame *Frame) []Scope {
+	scopes := []Scope{}
+	for env := frame.Env; env != nil; env = env.Outer() {
+		name := "Enclosing"
+		switch {
+		case env.Outer() == nil:
+			name = "Globals"
+		case len(scopes) == 0:
+			name = "Locals"
+		}
+		scopes = append(scopes, Scope{name, env})
+	}
+	return scopes
+}
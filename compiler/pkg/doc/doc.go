@@ -0,0 +1,214 @@
+// Package doc extracts the documentation of Lynx modules: the members an
+// importer can use, their parameter lists and the /// doc comments written
+// above them. It renders it as JSON, Markdown or an HTML page.
+package doc
+
+import (
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"path/filepath"
+	"strings"
+)
+
+// Member kinds
+const (
+	Function = "function"
+	Class    = "class"
+	Method   = "method"
+	Getter   = "getter"
+	Setter   = "setter"
+	Value    = "value"
+)
+
+// Module is the documentation of one source file
+type Module struct {
+	Name    string    `json:"name"`
+	Path    string    `json:"path"`
+	Doc     string    `json:"doc,omitempty"`
+	Members []*Member `json:"members"`
+}
+
+// Member is a function, class or value of a module, or a method of a class
+type Member struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Signature string    `json:"signature"`
+	Params    []Param   `json:"params,omitempty"`
+	Returns   string    `json:"returns,omitempty"`
+	Doc       string    `json:"doc,omitempty"`
+	Line      int       `json:"line"`
+	Members   []*Member `json:"members,omitempty"` // a class's methods
+}
+
+// Param is a function parameter and its annotated type, if any
+type Param struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// Source parses a module's source and extracts its documentation. The
+// module is named after its file, as importers refer to it.
+func Source(source, path string) (*Module, []parser.ParseError) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, p.Errors()
+	}
+	return Extract(program, path), nil
+}
+
+// Extract documents a parsed module. A module built with @module() and
+// bound to the file's name documents the members assigned to it; any other
+// file its top-level declarations, only the exported ones if it exports
+// any. Names starting with an underscore are private and left out.
+func Extract(program *ast.Program, path string) *Module {
+	name := strings.TrimSuffix(filepath.Base(path), ".lynx")
+	m := &Module{Name: name, Path: path, Doc: fileDoc(program), Members: []*Member{}}
+
+	for _, stmt := range program.Statements {
+		if vs, ok := stmt.(*ast.VarStatement); ok && vs.Name.Value == name && isModuleObject(vs.Value) {
+			if vs.Doc != "" {
+				m.Doc = vs.Doc
+			}
+			m.Members = objectMembers(program, name)
+			return m
+		}
+	}
+
+	exports := false
+	for _, stmt := range program.Statements {
+		_, ok := stmt.(*ast.ExportStatement)
+		exports = exports || ok
+	}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		} else if exports {
+			continue
+		}
+		switch s := stmt.(type) {
+		case *ast.VarStatement:
+			m.add(declaration(name, s.Name.Value, s.Value, s.Doc, s.Token.Line))
+		case *ast.Class:
+			m.add(class(name, s))
+		}
+	}
+	return m
+}
+
+func (m *Module) add(member *Member) {
+	if member != nil && !strings.HasPrefix(member.Name, "_") {
+		m.Members = append(m.Members, member)
+	}
+}
+
+// fileDoc is a /// comment at the top of the file that is not attached to
+// a declaration, being followed by a blank line
+func fileDoc(program *ast.Program) string {
+	lines := []string{}
+	for _, c := range program.Comments {
+		if c.Line != len(lines)+1 || !strings.HasPrefix(c.Text, "///") {
+			break
+		}
+		lines = append(lines, strings.TrimPrefix(strings.TrimPrefix(c.Text, "///"), " "))
+	}
+	if len(lines) == 0 || len(program.Statements) > 0 && ast.StartToken(program.Statements[0]).Line == len(lines)+1 {
+		return ""
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isModuleObject(value ast.Expression) bool {
+	load, ok := value.(*ast.ModuleLoad)
+	return ok && load.Path() == "module"
+}
+
+// objectMembers documents the top-level assignments to a module object's
+// members, such as math.abs = fn(x) { ... }
+func objectMembers(program *ast.Program, module string) []*Member {
+	members := []*Member{}
+	seen := map[string]bool{}
+	for _, stmt := range program.Statements {
+		a, ok := stmt.(*ast.Assignment)
+		if !ok {
+			continue
+		}
+		target, ok := a.Name.(*ast.PropertyAccess)
+		if !ok {
+			continue
+		}
+		if obj, ok := target.Object.(*ast.Identifier); !ok || obj.Value != module {
+			continue
+		}
+		name := target.Property.Value
+		if strings.HasPrefix(name, "_") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		members = append(members, declaration(module, name, a.Value, a.Doc, ast.StartToken(a).Line))
+	}
+	return members
+}
+
+// declaration documents a binding of name to value in module
+func declaration(module, name string, value ast.Expression, doc string, line int) *Member {
+	m := &Member{Name: name, Kind: Value, Signature: module + "." + name, Doc: doc, Line: line}
+	if fn, ok := value.(*ast.FunctionLiteral); ok {
+		m.Kind = Function
+		m.function(fn)
+	}
+	return m
+}
+
+func (m *Member) function(fn *ast.FunctionLiteral) {
+	for i, p := range fn.Parameters {
+		param := Param{Name: p.Value}
+		if i < len(fn.ParamTypes) && fn.ParamTypes[i] != nil {
+			param.Type = fn.ParamTypes[i].String()
+		}
+		m.Params = append(m.Params, param)
+	}
+	if fn.ReturnType != nil {
+		m.Returns = fn.ReturnType.String()
+	}
+	m.Signature += ast.Signature(fn.Parameters, fn.ParamTypes, fn.ReturnType)
+}
+
+// class documents a class and its public methods and accessors
+func class(module string, c *ast.Class) *Member {
+	m := &Member{
+		Name:      c.Name.Value,
+		Kind:      Class,
+		Signature: "class " + module + "." + c.Name.Value,
+		Doc:       c.Doc,
+		Line:      c.Token.Line,
+	}
+	if c.SuperClass != nil {
+		m.Signature += "(" + c.SuperClass.Value + ")"
+	}
+	if c.Body == nil {
+		return m
+	}
+
+	for _, stmt := range c.Body.Statements {
+		var method *Member
+		switch s := stmt.(type) {
+		case *ast.VarStatement:
+			if fn, ok := s.Value.(*ast.FunctionLiteral); ok && !s.IsConst {
+				method = &Member{Name: s.Name.Value, Kind: Method, Signature: c.Name.Value + "." + s.Name.Value, Doc: s.Doc, Line: s.Token.Line}
+				method.function(fn)
+			}
+		case *ast.Accessor:
+			kind := Getter
+			if s.Kind == "set" {
+				kind = Setter
+			}
+			method = &Member{Name: s.Name.Value, Kind: kind, Signature: s.Kind + " " + c.Name.Value + "." + s.Name.Value, Doc: s.Doc, Line: s.Token.Line}
+		}
+		if method != nil && !strings.HasPrefix(method.Name, "_") {
+			m.Members = append(m.Members, method)
+		}
+	}
+	return m
+}
@@ -0,0 +1,95 @@
+package doc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes the modules as an indented JSON array
+func WriteJSON(w io.Writer, modules []*Module) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(modules)
+}
+
+// WriteMarkdown writes a section for each module with a heading for each
+// member, a class's methods nested below it
+func WriteMarkdown(w io.Writer, modules []*Module) error {
+	out := bufio.NewWriter(w)
+	for i, m := range modules {
+		if i > 0 {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "# %s\n", m.Name)
+		writeMarkdownDoc(out, m.Doc)
+		for _, member := range m.Members {
+			fmt.Fprintf(out, "\n## `%s`\n", member.Signature)
+			writeMarkdownDoc(out, member.Doc)
+			for _, method := range member.Members {
+				fmt.Fprintf(out, "\n### `%s`\n", method.Signature)
+				writeMarkdownDoc(out, method.Doc)
+			}
+		}
+	}
+	return out.Flush()
+}
+
+func writeMarkdownDoc(out *bufio.Writer, doc string) {
+	if doc != "" {
+		fmt.Fprintf(out, "\n%s\n", doc)
+	}
+}
+
+var htmlPage = template.Must(template.New("doc").Funcs(template.FuncMap{
+	"paragraphs": paragraphs,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Lynx documentation</title>
+<style>
+body { font-family: sans-serif; margin: 2em; max-width: 60em; }
+code { font-family: monospace; }
+h2 code, h3 code { font-size: 1.1em; }
+.member { margin-left: 1em; }
+.method { margin-left: 2em; }
+nav a { margin-right: 1em; }
+</style>
+</head>
+<body>
+<nav>{{range .}}<a href="#{{.Name}}">{{.Name}}</a>{{end}}</nav>
+{{range .}}<section id="{{.Name}}">
+<h1>{{.Name}}</h1>
+{{range paragraphs .Doc}}<p>{{.}}</p>
+{{end}}{{$module := .Name}}{{range .Members}}<div class="member" id="{{$module}}.{{.Name}}">
+<h2><code>{{.Signature}}</code></h2>
+{{range paragraphs .Doc}}<p>{{.}}</p>
+{{end}}{{range .Members}}<div class="method">
+<h3><code>{{.Signature}}</code></h3>
+{{range paragraphs .Doc}}<p>{{.}}</p>
+{{end}}</div>
+{{end}}</div>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+// WriteHTML writes a page documenting the modules, with links to each
+func WriteHTML(w io.Writer, modules []*Module) error {
+	return htmlPage.Execute(w, modules)
+}
+
+// paragraphs splits a doc comment at its blank lines
+func paragraphs(doc string) []string {
+	result := []string{}
+	for _, p := range strings.Split(doc, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			result = append(result, p)
+		}
+	}
+	return result
+}
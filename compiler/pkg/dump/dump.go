@@ -0,0 +1,211 @@
+// Package dump shows how the lexer and parser read a program: its token
+// stream, and its syntax tree with the span of every node. Both are written
+// as text for people or as JSON for tools such as the web IDE.
+package dump
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"lynx/pkg/token"
+	"reflect"
+	"unicode"
+)
+
+// Version is the version of the JSON format. Fields and node types may be
+// added without changing it; it is raised when a change could break readers.
+const Version = 1
+
+// Position is a line and column in the source, both counted from 1
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Span is the source a token or node covers. End is just past its last
+// character.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (s Span) String() string {
+	return s.Start.String() + "-" + s.End.String()
+}
+
+// Error is a lexer or parser error
+type Error struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%s at line %d, column %d: %s", e.Type, e.Line, e.Column, e.Message)
+}
+
+// Token is a token of the source
+type Token struct {
+	Type    string `json:"type"`
+	Literal string `json:"literal"`
+	Span
+}
+
+// Tokens lexes source into its tokens, ending with EOF
+func Tokens(source string) ([]Token, []Error) {
+	l := lexer.New(source)
+	tokens := []Token{}
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, Token{Type: string(tok.Type), Literal: tok.Literal, Span: tokenSpan(tok)})
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	return tokens, lexErrors(l)
+}
+
+// Node is a syntax tree node: its type, as named in package ast, its span
+// and its fields in declaration order. A field's value is a string, number
+// or bool, a *Node, a []*Node or, for a hash literal's pairs, a []Pair.
+type Node struct {
+	Type string
+	Span
+	Fields []Field
+}
+
+// Field is a named part of a node
+type Field struct {
+	Name  string
+	Value any
+}
+
+// Pair is a key and value of a hash literal
+type Pair struct {
+	Key   *Node `json:"key"`
+	Value *Node `json:"value"`
+}
+
+// Parse parses source into a tree. A program that fails to parse still
+// gives the tree of what could be parsed.
+func Parse(source string) (*Node, []Error) {
+	l := lexer.New(source)
+	p := parser.New(l)
+	p.RecordEnds()
+	program := p.ParseProgram()
+	errs := lexErrors(l)
+	for _, e := range p.Errors() {
+		errs = append(errs, Error{Type: e.Type, Message: e.Message, Line: e.Line, Column: e.Column})
+	}
+	return Tree(program, p.End), errs
+}
+
+// Tree converts an AST to a tree of Nodes. end gives the last token of the
+// nodes the parser recorded one for, as Parser.End does.
+func Tree(node ast.Node, end func(ast.Node) (token.Token, bool)) *Node {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return nil
+	}
+	v := reflect.ValueOf(node).Elem()
+	n := &Node{Type: v.Type().Name(), Fields: []Field{}}
+	spans := []Span{}
+	if tok, ok := end(node); ok {
+		spans = append(spans, Span{End: tokenSpan(tok).End})
+	}
+
+	addChild := func(child *Node) *Node {
+		if child != nil {
+			spans = append(spans, child.Span)
+		}
+		return child
+	}
+	for i := 0; i < v.NumField(); i++ {
+		name := fieldName(v.Type().Field(i).Name)
+		f := v.Field(i)
+		if _, ok := node.(*ast.HashLiteral); ok && name == "keys" {
+			continue // given by the order of pairs
+		}
+		switch {
+		case f.Type() == reflect.TypeOf(token.Token{}):
+			if tok := f.Interface().(token.Token); tok.Line > 0 {
+				spans = append(spans, tokenSpan(tok))
+			}
+		case isNode(f.Type()):
+			child, _ := f.Interface().(ast.Node)
+			n.Fields = append(n.Fields, Field{name, addChild(Tree(child, end))})
+		case f.Kind() == reflect.Slice && isNode(f.Type().Elem()):
+			children := []*Node{}
+			for j := 0; j < f.Len(); j++ {
+				child, _ := f.Index(j).Interface().(ast.Node)
+				children = append(children, addChild(Tree(child, end)))
+			}
+			n.Fields = append(n.Fields, Field{name, children})
+		case f.Kind() == reflect.Map:
+			// A hash literal's pairs, in the order of its keys
+			hash := node.(*ast.HashLiteral)
+			pairs := []Pair{}
+			for _, k := range hash.Keys {
+				pairs = append(pairs, Pair{addChild(Tree(k, end)), addChild(Tree(hash.Pairs[k], end))})
+			}
+			n.Fields = append(n.Fields, Field{name, pairs})
+		case f.Kind() == reflect.String, f.Kind() == reflect.Bool, f.Kind() == reflect.Int64, f.Kind() == reflect.Float64:
+			n.Fields = append(n.Fields, Field{name, f.Interface()})
+		}
+	}
+	n.Span = cover(spans)
+	return n
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+func isNode(t reflect.Type) bool {
+	return t.Implements(nodeType)
+}
+
+// fieldName is the JSON name of an ast field, as isConst for IsConst
+func fieldName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// cover is the smallest span covering spans, ignoring missing starts
+func cover(spans []Span) Span {
+	var s Span
+	for _, span := range spans {
+		if span.Start.Line > 0 && (s.Start.Line == 0 || before(span.Start, s.Start)) {
+			s.Start = span.Start
+		}
+		if before(s.End, span.End) {
+			s.End = span.End
+		}
+	}
+	return s
+}
+
+func before(a, b Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+func tokenSpan(tok token.Token) Span {
+	s := Span{Start: Position{tok.Line, tok.Column}, End: Position{tok.EndLine, tok.EndColumn}}
+	if tok.EndLine == 0 {
+		// Made up by the parser rather than read from the source
+		s.End = s.Start
+	}
+	return s
+}
+
+func lexErrors(l *lexer.Lexer) []Error {
+	errs := []Error{}
+	for _, e := range l.Errors() {
+		errs = append(errs, Error{Type: "LexicalError", Message: e.Message, Line: e.Position.Line, Column: e.Position.Column})
+	}
+	return errs
+}
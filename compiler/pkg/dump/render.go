@@ -0,0 +1,129 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTokens writes a line for each token: its span, type and literal
+func WriteTokens(w io.Writer, tokens []Token) error {
+	out := bufio.NewWriter(w)
+	for _, tok := range tokens {
+		fmt.Fprintf(out, "%-12s %-10s %q\n", tok.Span, tok.Type, tok.Literal)
+	}
+	return out.Flush()
+}
+
+// WriteTokensJSON writes the tokens and lexer errors as a JSON object
+func WriteTokensJSON(w io.Writer, tokens []Token, errs []Error) error {
+	return writeJSON(w, struct {
+		Version int     `json:"version"`
+		Tokens  []Token `json:"tokens"`
+		Errors  []Error `json:"errors"`
+	}{Version, tokens, errs})
+}
+
+// WriteTree writes a line for each node, indented below its parent and
+// labelled with the field holding it. Fields that are not nodes follow the
+// node's span, leaving out false and empty ones other than a literal's value.
+func WriteTree(w io.Writer, tree *Node) error {
+	out := bufio.NewWriter(w)
+	writeNode(out, tree, "", 0)
+	return out.Flush()
+}
+
+func writeNode(out *bufio.Writer, n *Node, label string, depth int) {
+	if n == nil {
+		return
+	}
+	fmt.Fprintf(out, "%s%s%s %s", strings.Repeat("  ", depth), label, n.Type, n.Span)
+	for _, f := range n.Fields {
+		switch v := f.Value.(type) {
+		case string:
+			if v != "" || f.Name == "value" {
+				fmt.Fprintf(out, " %s=%q", f.Name, v)
+			}
+		case bool:
+			// A literal's value is always shown, other flags only when set
+			if f.Name == "value" {
+				fmt.Fprintf(out, " %s=%v", f.Name, v)
+			} else if v {
+				fmt.Fprintf(out, " %s", f.Name)
+			}
+		case int64, float64:
+			fmt.Fprintf(out, " %s=%v", f.Name, v)
+		}
+	}
+	fmt.Fprintln(out)
+
+	for _, f := range n.Fields {
+		switch v := f.Value.(type) {
+		case *Node:
+			writeNode(out, v, f.Name+": ", depth+1)
+		case []*Node:
+			for i, child := range v {
+				writeNode(out, child, fmt.Sprintf("%s[%d]: ", f.Name, i), depth+1)
+			}
+		case []Pair:
+			for i, pair := range v {
+				writeNode(out, pair.Key, fmt.Sprintf("%s[%d].key: ", f.Name, i), depth+1)
+				writeNode(out, pair.Value, fmt.Sprintf("%s[%d].value: ", f.Name, i), depth+1)
+			}
+		}
+	}
+}
+
+// WriteTreeJSON writes the tree and the lexer and parser errors as a JSON
+// object. Each node is an object of its type, under "node" as some nodes
+// have a type field, its start, end and fields, a missing child being null.
+func WriteTreeJSON(w io.Writer, tree *Node, errs []Error) error {
+	return writeJSON(w, struct {
+		Version int     `json:"version"`
+		Program *Node   `json:"program"`
+		Errors  []Error `json:"errors"`
+	}{Version, tree, errs})
+}
+
+// MarshalJSON writes the node's fields after its type and span, in the
+// order of the ast struct rather than sorted
+func (n *Node) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(`{"node":`)
+	typ, _ := marshal(n.Type)
+	b.Write(typ)
+	span, _ := marshal(n.Span)
+	b.WriteString(",")
+	b.Write(span[1 : len(span)-1])
+	for _, f := range n.Fields {
+		value, err := marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, ",%q:", f.Name)
+		b.Write(value)
+	}
+	b.WriteString("}")
+	return b.Bytes(), nil
+}
+
+// marshal is json.Marshal leaving operators such as < unescaped
+func marshal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -40,7 +40,14 @@ func RegisterBuiltins() {
 	builtins["float"] = &object.Builtin{Fn: builtinFloat}
 	builtins["str"] = &object.Builtin{Fn: builtinStr}
 	builtins["type"] = &object.Builtin{Fn: builtinType}
+	builtins["isinstance"] = &object.Builtin{Fn: builtinIsInstance}
+	builtins["implements"] = &object.Builtin{Fn: builtinImplements}
+	builtins["exception"] = &object.Builtin{Fn: builtinException}
+	builtins["Ok"] = &object.Builtin{Fn: builtinOk}
+	builtins["Err"] = &object.Builtin{Fn: builtinErr}
+	builtins["attempt"] = &object.Builtin{Fn: builtinAttempt}
 	builtins["copy"] = &object.Builtin{Fn: builtinCopy}
+	builtins["help"] = &object.Builtin{Fn: builtinHelp}
 	builtins["_formatPrint"] = &object.Builtin{Fn: builtinFormatPrint}
 	builtins["_readFile"] = &object.Builtin{Fn: builtinReadFile}
 	builtins["_writeFile"] = &object.Builtin{Fn: builtinWriteFile}
@@ -78,6 +85,22 @@ func RegisterBuiltins() {
 	builtins["_sha512"] = &object.Builtin{Fn: builtinSha512}
 	builtins["_jsonParse"] = &object.Builtin{Fn: builtinJsonParse}
 	builtins["_jsonStringify"] = &object.Builtin{Fn: builtinJsonStringify}
+	builtins["_deepEqual"] = &object.Builtin{Fn: builtinDeepEqual}
+	builtins["_repr"] = &object.Builtin{Fn: builtinRepr}
+
+	for name, builtin := range builtins {
+		builtin.Name = name
+	}
+}
+
+// BuiltinNames lists the registered built-in functions
+func BuiltinNames() []string {
+	RegisterBuiltins()
+	names := []string{}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	return names
 }
 
 func builtinType(args ...object.Object) object.Object {
@@ -108,17 +131,101 @@ func builtinType(args ...object.Object) object.Object {
 		return &object.String{Value: "null"}
 	case *object.Class:
 		return &object.String{Value: "class"}
+	case *object.Trait:
+		return &object.String{Value: "trait"}
 	case *object.Instance:
 		return &object.String{Value: obj.Class.Name}
 	case *object.Module:
 		return &object.String{Value: "module"}
 	case *object.Error:
 		return &object.String{Value: "error"}
+	case *object.Exception:
+		return &object.String{Value: "exception"}
+	case *object.Result:
+		return &object.String{Value: "result"}
 	default:
 		return &object.String{Value: "unknown"}
 	}
 }
 
+// builtinException builds a typed exception for `error`:
+// exception(type, message, fields?, cause?)
+func builtinException(args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 4 {
+		return newError("wrong number of arguments. got %d, expected 2 to 4", len(args))
+	}
+	kind, ok := args[0].(*object.String)
+	if !ok {
+		return newError("exception type must be a string, got %s", args[0].Type())
+	}
+	message, ok := args[1].(*object.String)
+	if !ok {
+		return newError("exception message must be a string, got %s", args[1].Type())
+	}
+
+	exc := &object.Exception{
+		Kind:    kind.Value,
+		Message: message.Value,
+		Fields:  make(map[string]object.Object),
+	}
+	if len(args) > 2 && args[2] != NULL {
+		fields, ok := args[2].(*object.Hash)
+		if !ok {
+			return newError("exception fields must be a hash, got %s", args[2].Type())
+		}
+		for _, pair := range fields.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError("exception field names must be strings, got %s", pair.Key.Type())
+			}
+			exc.Fields[key.Value] = pair.Value
+		}
+	}
+	if len(args) > 3 && args[3] != NULL {
+		cause, ok := args[3].(*object.Exception)
+		if !ok {
+			return newError("exception cause must be an exception, got %s", args[3].Type())
+		}
+		exc.Cause = cause
+	}
+	return exc
+}
+
+func builtinIsInstance(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got %d, expected 2", len(args))
+	}
+	return evalInstanceOf(args[0], args[1])
+}
+
+func builtinImplements(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got %d, expected 2", len(args))
+	}
+	trait, ok := args[1].(*object.Trait)
+	if !ok {
+		return newError("second argument to `implements` must be TRAIT, got %s", args[1].Type())
+	}
+	return evalImplements(args[0], trait)
+}
+
+// builtinDeepEqual compares values structurally, hashes included, as
+// switch cases are matched
+func builtinDeepEqual(args ...object.Object) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got %d, expected 2", len(args))
+	}
+	return nativeBoolToBooleanObject(objectsEqual(args[0], args[1]))
+}
+
+// builtinRepr renders a value as it would be written in source
+func builtinRepr(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got %d, expected 1", len(args))
+	}
+	return &object.String{Value: object.Repr(args[0])}
+}
+
 func builtinCopy(args ...object.Object) object.Object {
 	if len(args) != 2 {
 		return newError("wrong number of arguments. got %d, expected 2", len(args))
@@ -160,6 +267,17 @@ func builtinStr(args ...object.Object) object.Object {
 			return &object.String{Value: "true"}
 		}
 		return &object.String{Value: "false"}
+	case *object.Instance:
+		if result, ok := callSpecialMethod(arg, "str", []object.Object{}); ok {
+			if isError(result) {
+				return result
+			}
+			if str, ok := result.(*object.String); ok {
+				return str
+			}
+			return newError("%s.str must return STRING, got %s", arg.Class.Name, result.Type())
+		}
+		return &object.String{Value: arg.Inspect()}
 	default:
 		return newError("argument to `str` must be STRING, INTEGER, FLOAT, or BOOLEAN. got=%s", arg.Type())
 	}
@@ -290,6 +408,11 @@ func builtinLen(args ...object.Object) object.Object {
 		return &object.Integer{Value: int64(len(arg.Value))}
 	case *object.Hash:
 		return &object.Integer{Value: int64(len(arg.Pairs))}
+	case *object.Instance:
+		if result, ok := callSpecialMethod(arg, "len", []object.Object{}); ok {
+			return result
+		}
+		return newError("argument to `len` not supported, %s does not define len", arg.Class.Name)
 	default:
 		return newError("argument to `len` not supported, got %T", arg)
 	}
@@ -569,10 +692,14 @@ func builtinExit(args ...object.Object) object.Object {
 			code = int(c.Value)
 		}
 	}
-	os.Exit(code)
+	Exit(code)
 	return NULL
 }
 
+// Exit ends the process for os.exit(); tools that must write their results
+// first, such as the profiler, replace it
+var Exit = os.Exit
+
 func builtinCwd(args ...object.Object) object.Object {
 	dir, _ := os.Getwd()
 	return &object.String{Value: dir}
@@ -612,8 +739,15 @@ func builtinUser(args ...object.Object) object.Object {
 	return &object.String{Value: usr.Name}
 }
 
+// ScriptArgs holds the arguments passed to the running script after its name
+var ScriptArgs []string
+
 func builtinArgs(args ...object.Object) object.Object {
-	return &object.Array{Elements: []object.Object{}}
+	elements := make([]object.Object, len(ScriptArgs))
+	for i, arg := range ScriptArgs {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
 }
 
 func builtinMkdir(args ...object.Object) object.Object {
@@ -2,14 +2,17 @@ package evaluator
 
 import (
 	"fmt"
+	"io/fs"
 	"lynx/pkg/ast"
 	"lynx/pkg/lexer"
 	"lynx/pkg/object"
 	"lynx/pkg/parser"
+	"lynx/std"
 	"maps"
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -21,9 +24,19 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
-// Module cache for imports
+// Module cache for imports, keyed by canonical file path
 var moduleCache = make(map[string]object.Object)
 
+// Paths of the modules currently being initialized, outermost first
+var moduleStack []string
+
+// ResetModules forgets every loaded module, so the next import evaluates
+// it again
+func ResetModules() {
+	moduleCache = make(map[string]object.Object)
+	moduleStack = nil
+}
+
 // Eval evaluates an AST node in the given environment
 func Eval(node ast.Node, env *object.Env) object.Object {
 	switch node := node.(type) {
@@ -68,6 +81,12 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value
+		}
+		if mod, ok := val.(*object.Module); ok && mod.Doc == "" {
+			mod.Doc = node.Doc
+		}
 		env.Set(node.Name.Value, val, node.IsConst)
 		return val
 	case *ast.Identifier:
@@ -75,7 +94,8 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Body: body, Env: env,
+			ParamTypes: node.ParamTypes, ReturnType: node.ReturnType, Doc: node.Doc}
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
 		if isError(function) {
@@ -108,6 +128,9 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 		if isError(obj) {
 			return obj
 		}
+		if err := checkMemberAccess(obj, node.Method.Value, env); err != nil {
+			return err
+		}
 		args := evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
@@ -143,9 +166,12 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 			if isError(object) {
 				return object
 			}
-			return evalPropertyAssignment(object, target.Property.Value, val)
+			if err := checkMemberAccess(object, target.Property.Value, env); err != nil {
+				return err
+			}
+			return evalPropertyAssignment(object, target.Property.Value, val, node.Doc)
 		default:
-			return newError("invalid assignment target: %T", node.Name)
+			return newInternalError("invalid assignment target: %T", node.Name)
 		}
 
 	case *ast.PropertyAccess:
@@ -154,6 +180,9 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 			return obj
 		}
 		prop := &object.String{Value: node.Property.Value}
+		if err := checkMemberAccess(obj, prop.Value, env); err != nil {
+			return err
+		}
 		return evalPropertyAccess(obj, prop.Value)
 	case *ast.ForRange:
 		return evalForRange(node, env)
@@ -173,14 +202,34 @@ func Eval(node ast.Node, env *object.Env) object.Object {
 		return evalErrorStatement(node, env)
 	case *ast.CatchStatement:
 		return evalCatchStatement(node, env)
+	case *ast.TryExpression:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return evalTryExpression(val)
+	case *ast.DeferStatement:
+		return evalDeferStatement(node, env)
 	case *ast.Null:
 		return &object.Null{}
 	case *ast.Class:
 		return evalClassStatement(node, env)
+	case *ast.Trait:
+		return evalTraitStatement(node, env)
 	case *ast.Self:
 		return evalSelf(env)
+	case *ast.Super:
+		return evalSuper(env)
+	case *ast.StaticStatement:
+		return newError("'static' can only be used inside a class body")
+	case *ast.Accessor:
+		return newError("'%s' can only be used inside a class body", node.Kind)
+	case *ast.ExportStatement:
+		return evalExportStatement(node, env)
+	case *ast.PrivateStatement:
+		return newError("'private' can only be used inside a class body")
 	default:
-		return newError("unknown node type: %T", node)
+		return newInternalError("unknown node type: %T", node)
 	}
 }
 
@@ -198,32 +247,61 @@ func evalIndexAssignment(left, index, value object.Object) object.Object {
 		arr.Elements[idx.Value] = value
 		return value
 	case *object.Hash:
-		key, ok := index.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", index.Type())
+		slot, pair, ok, errObj := findPair(arr, index)
+		if errObj != nil {
+			return errObj
 		}
-		pair, ok := arr.Pairs[key.HashKey()]
 		if !ok {
 			return newError("key not found: %s", index.Type())
 		}
-		pair.Value = value
-		return pair.Value
+		arr.Pairs[slot] = object.HashPair{Key: pair.Key, Value: value}
+		return value
+	case *object.Instance:
+		result, ok := callSpecialMethod(arr, "setindex", []object.Object{index, value})
+		if !ok {
+			return newError("%s does not support index assignment", arr.Class.Name)
+		}
+		if isError(result) {
+			return result
+		}
+		return value
 	default:
 		return newError("cannot assign to: %s", left.Type())
 	}
 }
 
-func evalPropertyAssignment(obj object.Object, prop string, val object.Object) object.Object {
+func evalPropertyAssignment(obj object.Object, prop string, val object.Object, doc string) object.Object {
 	switch obj := obj.(type) {
 	case *object.Hash:
 		key := &object.String{Value: prop}
 		obj.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
 		return val
 	case *object.Instance:
+		if setter, ok := obj.Class.Setters[prop]; ok {
+			result := callMethod(obj, setter, []object.Object{val})
+			if isError(result) {
+				return result
+			}
+			return val
+		}
+		if _, ok := obj.Class.Getters[prop]; ok {
+			return newError("property %s of %s is read-only", prop, obj.Class.Name)
+		}
 		obj.Attributes[prop] = val
 		return val
 	case *object.Module:
 		obj.Env.Set(prop, val, false)
+		if doc != "" {
+			documentMember(obj, prop, doc)
+		}
+		return val
+	case *object.Class:
+		if _, ok := obj.Statics.Get(prop); !ok {
+			return newError("class %s has no static member: %s", obj.Name, prop)
+		}
+		if result := obj.Statics.Assign(prop, val); isError(result) {
+			return result
+		}
 		return val
 	default:
 		return newError("property assignment only supported on objects and instances, got %T", obj)
@@ -237,10 +315,19 @@ func nativeBoolToBooleanObject(value bool) *object.Boolean {
 	return FALSE
 }
 
+// evalProgram runs a program's statements, then any actions deferred at
+// the top level
 func evalProgram(stmts []ast.Statement, env *object.Env) object.Object {
+	return runDeferred(env, evalProgramStatements(stmts, env))
+}
+
+func evalProgramStatements(stmts []ast.Statement, env *object.Env) object.Object {
 	var result object.Object
 
 	for i, statement := range stmts {
+		if Trace != nil {
+			Trace.Statement(statement, env)
+		}
 		result = Eval(statement, env)
 
 		switch result := result.(type) {
@@ -295,6 +382,11 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	if inst, ok := right.(*object.Instance); ok {
+		if result, ok := callSpecialMethod(inst, "neg", []object.Object{}); ok {
+			return result
+		}
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return newError("unknown operator: -%s", right.Type())
 	}
@@ -410,6 +502,10 @@ var operatorMap = map[TypePair]map[string]OperatorHandler{
 		"==": evalArrayEqual,
 		"!=": evalArrayNotEqual,
 	},
+	{object.RESULT_OBJ, object.RESULT_OBJ}: {
+		"==": evalResultEqual,
+		"!=": evalResultNotEqual,
+	},
 	{object.INTEGER_OBJ, object.BOOLEAN_OBJ}: {
 		"and": evalIntegerBooleanAnd,
 		"or":  evalIntegerBooleanOr,
@@ -423,6 +519,10 @@ var operatorMap = map[TypePair]map[string]OperatorHandler{
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	if result, ok := evalInstanceInfixExpression(operator, left, right); ok {
+		return result
+	}
+
 	if operator == "++" {
 		return evalConcatExpression(left, right)
 	}
@@ -431,6 +531,10 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 		return evalInOperator(left, right)
 	}
 
+	if operator == "instanceof" {
+		return evalInstanceOf(left, right)
+	}
+
 	typePair := TypePair{left.Type(), right.Type()}
 	if handlers, exists := operatorMap[typePair]; exists {
 		if handler, exists := handlers[operator]; exists {
@@ -461,12 +565,10 @@ func evalInOperator(left, right object.Object) object.Object {
 		return FALSE
 
 	case *object.Hash:
-		hashKey, ok := left.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", left.Type())
+		_, _, exists, errObj := findPair(container, left)
+		if errObj != nil {
+			return errObj
 		}
-
-		_, exists := container.Pairs[hashKey.HashKey()]
 		return nativeBoolToBooleanObject(exists)
 
 	case *object.String:
@@ -1102,12 +1204,14 @@ func evalIfExpression(ie *ast.IfExpression, env *object.Env) object.Object {
 		return condition
 	}
 	if isTruthy(condition) {
+		traceBranch(ie, 0, env)
 		return Eval(ie.Consequence, env)
-	} else if ie.Alternative != nil {
+	}
+	traceBranch(ie, 1, env)
+	if ie.Alternative != nil {
 		return Eval(ie.Alternative, env)
-	} else {
-		return NULL
 	}
+	return NULL
 }
 
 func isTruthy(obj object.Object) bool {
@@ -1133,6 +1237,9 @@ func isError(obj object.Object) bool {
 func evalBlockStatement(block *ast.BlockStatement, env *object.Env) object.Object {
 	var result object.Object
 	for _, stmt := range block.Statements {
+		if Trace != nil {
+			Trace.Statement(stmt, env)
+		}
 		result = Eval(stmt, env)
 		if result != nil {
 			rt := result.Type()
@@ -1148,6 +1255,11 @@ func newError(format string, a ...any) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// newInternalError reports an interpreter bug; catch blocks let it through
+func newInternalError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Internal: true}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Env) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
@@ -1179,13 +1291,23 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 			return newError("wrong number of arguments: want=%d, got=%d",
 				len(fn.Parameters), len(args))
 		}
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		return checkReturnType(fn, evalFunctionBody(fn, extendedEnv))
 	case *object.Builtin:
+		if Trace != nil {
+			return traceBuiltin(fn.Name, func() object.Object { return fn.Fn(args...) })
+		}
 		return fn.Fn(args...)
 	case *object.Class:
 		return evalClassCall(fn, args)
+	case *object.Instance:
+		if result, ok := callSpecialMethod(fn, "call", args); ok {
+			return result
+		}
+		return newError("%s instance is not callable", fn.Class.Name)
 	default:
 		return newError("not a function: %T", fn)
 	}
@@ -1197,11 +1319,12 @@ func evalClassCall(class *object.Class, args []object.Object) object.Object {
 		Attributes: make(map[string]object.Object),
 	}
 
-	if initMethod, ok := class.Methods["init"]; ok {
-		methodEnv := extendFunctionEnv(initMethod, args)
-		methodEnv.Set("self", instance, false)
+	if err := initFields(class, instance); err != nil {
+		return err
+	}
 
-		result := Eval(initMethod.Body, methodEnv)
+	if initMethod, ok := class.Methods["init"]; ok {
+		result := callMethod(instance, initMethod, args)
 		if isError(result) {
 			return result
 		}
@@ -1210,12 +1333,113 @@ func evalClassCall(class *object.Class, args []object.Object) object.Object {
 	return instance
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Env {
-	env := fn.Env.NewEnclosedEnv()
+// initFields evaluates declared field defaults, superclass fields first, so
+// every instance starts with its own copy of each value
+func initFields(class *object.Class, instance *object.Instance) object.Object {
+	if class.SuperClass != nil {
+		if err := initFields(class.SuperClass, instance); err != nil {
+			return err
+		}
+	}
+
+	for _, field := range class.Fields {
+		fieldEnv := class.Env.NewEnclosedEnv()
+		fieldEnv.Set("self", instance, false)
+		val := Eval(field.Value, fieldEnv)
+		if isError(val) {
+			return val
+		}
+		instance.Attributes[field.Name.Value] = val
+	}
+	return nil
+}
+
+// extendFunctionEnv binds args in a new call frame; in strict mode an
+// argument that does not match its parameter's annotation is a TypeError
+func extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Env, *object.Error) {
+	if StrictTypes {
+		if err := checkArgumentTypes(fn, args); err != nil {
+			return nil, err
+		}
+	}
+	env := fn.Env.NewFrame()
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx], false)
 	}
-	return env
+	return env, nil
+}
+
+// evalFunctionBody runs a function body in its call frame, then the actions
+// the body deferred, whether it returned normally or with an error
+func evalFunctionBody(fn *object.Function, env *object.Env) object.Object {
+	if Trace != nil {
+		Trace.Call(fn, env)
+	}
+	result := unwrapReturnValue(Eval(fn.Body, env))
+	if err, ok := result.(*object.Error); ok && err.Result != nil {
+		result = err.Result
+	}
+	result = runDeferred(env, result)
+	if Trace != nil {
+		Trace.Return(fn, result)
+	}
+	return result
+}
+
+// evalDeferStatement registers a deferred action. As in Go, a deferred
+// call's function and arguments are evaluated now and only the call waits
+// for the exit.
+func evalDeferStatement(node *ast.DeferStatement, env *object.Env) object.Object {
+	switch call := node.Value.(type) {
+	case *ast.CallExpression:
+		function := Eval(call.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(call.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		env.DeferCall(func() object.Object { return applyFunction(function, args) })
+	case *ast.MethodCall:
+		obj := Eval(call.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		if err := checkMemberAccess(obj, call.Method.Value, env); err != nil {
+			return err
+		}
+		args := evalExpressions(call.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		env.DeferCall(func() object.Object { return applyMethod(obj, call.Method.Value, args) })
+	default:
+		if node.Body != nil {
+			env.Defer(node.Body)
+		} else {
+			env.Defer(node.Value)
+		}
+	}
+	return NULL
+}
+
+// runDeferred evaluates env's deferred actions, last registered first. An
+// error from an action replaces a successful result but never hides an
+// error that is already propagating.
+func runDeferred(env *object.Env, result object.Object) object.Object {
+	for _, deferred := range env.TakeDeferred() {
+		var val object.Object
+		if deferred.Call != nil {
+			val = deferred.Call()
+		} else {
+			val = Eval(deferred.Action, deferred.Env)
+		}
+		if isError(val) && !isError(result) {
+			result = val
+		}
+	}
+	return result
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
@@ -1226,6 +1450,14 @@ func unwrapReturnValue(obj object.Object) object.Object {
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
+	if inst, ok := left.(*object.Instance); ok {
+		result, ok := callSpecialMethod(inst, "index", []object.Object{index})
+		if !ok {
+			return newError("index operator not supported: %s", inst.Class.Name)
+		}
+		return result
+	}
+
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
@@ -1287,11 +1519,10 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObj := hash.(*object.Hash)
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+	_, pair, ok, errObj := findPair(hashObj, index)
+	if errObj != nil {
+		return errObj
 	}
-	pair, ok := hashObj.Pairs[key.HashKey()]
 	if !ok {
 		return newError("key not found: %s", index.Type())
 	}
@@ -1299,7 +1530,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Env) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
 	for keyNode, valueNode := range node.Pairs {
 		var keyObj object.Object
 
@@ -1313,9 +1544,9 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Env) object.Object {
 			}
 		}
 
-		hashKey, ok := keyObj.(object.Hashable)
-		if !ok {
-			return newError("unusable as hash key: %s", keyObj.Type())
+		slot, _, _, errObj := findPair(hash, keyObj)
+		if errObj != nil {
+			return errObj
 		}
 
 		value := Eval(valueNode, env)
@@ -1323,61 +1554,64 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Env) object.Object {
 			return value
 		}
 
-		pairs[hashKey.HashKey()] = object.HashPair{Key: keyObj, Value: value}
+		hash.Pairs[slot] = object.HashPair{Key: keyObj, Value: value}
 	}
-	return &object.Hash{Pairs: pairs}
+	return hash
 }
 
 func applyMethod(obj object.Object, method string, args []object.Object) object.Object {
 	switch obj := obj.(type) {
 	case *object.String:
+		if Trace != nil {
+			return traceBuiltin("str."+method, func() object.Object { return evalStringMethod(obj, method, args) })
+		}
 		return evalStringMethod(obj, method, args)
 	case *object.Array:
+		if Trace != nil {
+			return traceBuiltin("array."+method, func() object.Object { return evalArrayMethod(obj, method, args) })
+		}
 		return evalArrayMethod(obj, method, args)
 	case *object.Hash:
 		return evalHashMethod(obj, method, args)
 	case *object.Module:
 		return evalModuleMethod(obj, method, args)
+	case *object.Result:
+		if Trace != nil {
+			return traceBuiltin("result."+method, func() object.Object { return evalResultMethod(obj, method, args) })
+		}
+		return evalResultMethod(obj, method, args)
 	case *object.Instance:
 		if methodFn, ok := obj.Class.Methods[method]; ok {
-			methodEnv := extendFunctionEnv(methodFn, args)
-			methodEnv.Set("self", obj, false)
-
-			evaluated := Eval(methodFn.Body, methodEnv)
-			return unwrapReturnValue(evaluated)
+			return callMethod(obj, methodFn, args)
 		}
 		return newError("undefined method: %s", method)
+	case *object.BoundSuper:
+		if methodFn, ok := obj.Class.Methods[method]; ok {
+			return callMethod(obj.Instance, methodFn, args)
+		}
+		return newError("undefined method in superclass %s: %s", obj.Class.Name, method)
+	case *object.Class:
+		static, ok := obj.Statics.Get(method)
+		if !ok {
+			return newError("class %s has no static method: %s", obj.Name, method)
+		}
+		return applyFunction(static, args)
 	default:
 		return newError("method calls not supported on: %s", obj.Type())
 	}
 }
 
 func evalModuleMethod(obj *object.Module, method string, args []object.Object) object.Object {
-	var fn *object.Function
-	var ok bool
-
-	if obj.Members != nil {
-		val, hasMember := obj.Members[method]
-		if hasMember {
-			fn, ok = val.(*object.Function)
-			if !ok {
-				return newError("%s is not callable", method)
-			}
-		}
+	val, ok := obj.Get(method)
+	if !ok {
+		return newError("module has no method: %s", method)
 	}
-
-	if fn == nil {
-		val, hasMember := obj.Env.Get(method)
-		if !hasMember {
-			return newError("module has no method: %s", method)
-		}
-		fn, ok = val.(*object.Function)
-		if !ok {
-			return newError("%s is not callable", method)
-		}
+	switch val.(type) {
+	case *object.Function, *object.Builtin, *object.Class:
+		return applyFunction(val, args)
+	default:
+		return newError("%s is not callable", method)
 	}
-
-	return applyFunction(fn, args)
 }
 
 func evalHashMethod(obj *object.Hash, method string, args []object.Object) object.Object {
@@ -1396,6 +1630,18 @@ func evalHashMethod(obj *object.Hash, method string, args []object.Object) objec
 	return applyFunction(fn, args)
 }
 
+// StringMethods and ArrayMethods are the signatures of the methods that
+// evalStringMethod and evalArrayMethod implement, for editor tooling
+var (
+	StringMethods = []string{
+		"append(other)", "contains(substr)", "len()", "lower()",
+		"split(delimiter)", "substr(start, length)", "trim()", "upper()",
+	}
+	ArrayMethods = []string{
+		"filter(predicate)", "join(delimiter)", "len()", "pop()", "push(value)", "rest()",
+	}
+)
+
 func evalStringMethod(obj *object.String, method string, args []object.Object) object.Object {
 	switch method {
 	case "append":
@@ -1544,6 +1790,9 @@ func evalPropertyAccess(obj object.Object, property string) object.Object {
 	case *object.Module:
 		return evalModulePropertyAccess(obj, property)
 	case *object.Instance:
+		if getter, ok := obj.Class.Getters[property]; ok {
+			return callMethod(obj, getter, []object.Object{})
+		}
 		if attr, ok := obj.Attributes[property]; ok {
 			return attr
 		}
@@ -1551,20 +1800,31 @@ func evalPropertyAccess(obj object.Object, property string) object.Object {
 			return method
 		}
 		return newError("undefined property: %s", property)
+	case *object.Class:
+		if static, ok := obj.Statics.Get(property); ok {
+			return static
+		}
+		if method, ok := obj.Methods[property]; ok {
+			return method
+		}
+		return newError("class %s has no member: %s", obj.Name, property)
+	case *object.Exception:
+		return evalExceptionPropertyAccess(obj, property)
+	case *object.BoundSuper:
+		if getter, ok := obj.Class.Getters[property]; ok {
+			return callMethod(obj.Instance, getter, []object.Object{})
+		}
+		if method, ok := obj.Class.Methods[property]; ok {
+			return method
+		}
+		return newError("undefined property in superclass %s: %s", obj.Class.Name, property)
 	default:
 		return newError("property access not supported on: %s", obj.Type())
 	}
 }
 
 func evalModulePropertyAccess(obj *object.Module, property string) object.Object {
-	if obj.Members != nil {
-		member, ok := obj.Members[property]
-		if !ok {
-			return newError("module does not have member: %s", property)
-		}
-		return member
-	}
-	val, ok := obj.Env.Get(property)
+	val, ok := obj.Get(property)
 	if !ok {
 		return newError("module has no member: %s", property)
 	}
@@ -1598,7 +1858,7 @@ func evalForRange(node *ast.ForRange, env *object.Env) object.Object {
 		return collection
 	}
 
-	loopEnv := env.NewEnclosedEnv()
+	loopEnv := env.NewLoopEnv()
 
 	switch coll := collection.(type) {
 	case *object.Array:
@@ -1726,7 +1986,7 @@ func evalWhile(node *ast.While, env *object.Env) object.Object {
 }
 
 func evalModuleLoad(node *ast.ModuleLoad, env *object.Env) object.Object {
-	name := node.Name.String()
+	name := node.Path()
 
 	if name == "" || name == "module" {
 		newEnv := env.NewEnclosedEnv()
@@ -1734,26 +1994,49 @@ func evalModuleLoad(node *ast.ModuleLoad, env *object.Env) object.Object {
 		return setModuleInEnv(modObj, name, node.Members, env)
 	}
 
-	if mod, ok := moduleCache[name]; ok {
-		return setModuleInEnv(mod, name, node.Members, env)
+	path, err := FindModule(name, env.Dir)
+	if err != nil {
+		return newError("%s", err.Error())
 	}
 
-	modEnv := env.NewEnclosedEnv()
-	if err := loadModule(name, modEnv); err != nil {
-		return newError("%s", err.Error())
+	if mod, ok := moduleCache[path]; ok {
+		return setModuleInEnv(mod, node.Binding(), node.Members, env)
 	}
 
-	if storedMod, ok := modEnv.Get(name); ok {
-		if moduleObj, ok := storedMod.(*object.Module); ok {
-			moduleCache[name] = moduleObj
-			return setModuleInEnv(moduleObj, name, node.Members, env)
+	for i, loading := range moduleStack {
+		if loading == path {
+			chain := append(append([]string{}, moduleStack[i:]...), path)
+			return newError("import cycle: %s", strings.Join(chain, " -> "))
 		}
 	}
 
-	modObj := &object.Module{Name: name, Env: modEnv}
-	moduleCache[name] = modObj
+	moduleStack = append(moduleStack, path)
+	mod, loadErr := loadModule(path)
+	moduleStack = moduleStack[:len(moduleStack)-1]
+	if loadErr != nil {
+		return loadErr
+	}
+	moduleCache[path] = mod
 
-	return setModuleInEnv(modObj, name, node.Members, env)
+	return setModuleInEnv(mod, node.Binding(), node.Members, env)
+}
+
+// evalExportStatement evaluates a declaration and exports the name it binds
+func evalExportStatement(node *ast.ExportStatement, env *object.Env) object.Object {
+	var name string
+	switch stmt := node.Statement.(type) {
+	case *ast.VarStatement:
+		name = stmt.Name.Value
+	case *ast.Class:
+		name = stmt.Name.Value
+	case *ast.Trait:
+		name = stmt.Name.Value
+	}
+
+	if !env.Export(name) {
+		return newError("'export' can only be used at the top level of a module")
+	}
+	return Eval(node.Statement, env)
 }
 
 func setModuleInEnv(mod object.Object, name string, members []*ast.Identifier, env *object.Env) object.Object {
@@ -1768,7 +2051,7 @@ func setModuleInEnv(mod object.Object, name string, members []*ast.Identifier, e
 	}
 
 	for _, member := range members {
-		val, ok := module.Env.Get(member.Value)
+		val, ok := module.Get(member.Value)
 		if !ok {
 			return newError("module %s does not have member: %s", name, member.Value)
 		}
@@ -1778,58 +2061,168 @@ func setModuleInEnv(mod object.Object, name string, members []*ast.Identifier, e
 	return mod
 }
 
-func loadModule(name string, env *object.Env) error {
-	source, err := loadModuleSource(name, env)
+// loadModule evaluates the module file at path in an environment of its
+// own, so it sees only its own bindings and resolves imports from its
+// directory. A module object bound to the file's name becomes the module;
+// otherwise the file's top-level bindings do, limited to its exports.
+// Errors raised while initializing the module are prefixed with its path.
+func loadModule(path string) (*object.Module, *object.Error) {
+	source, err := ReadModule(path)
 	if err != nil {
-		return err
+		return nil, newError("%s", err.Error())
 	}
 
+	name := strings.TrimSuffix(filepath.Base(path), ".lynx")
 	lexer := lexer.New(string(source))
 	parser := parser.New(lexer)
 	program := parser.ParseProgram()
 
 	if len(parser.Errors()) != 0 {
-		return fmt.Errorf("parse errors in %s: %v", name, parser.Errors())
+		return nil, newError("parse errors in %s: %v", path, parser.Errors())
 	}
 
-	Eval(program, env)
-	return nil
+	modEnv := object.New(filepath.Dir(path))
+	modEnv.File = path
+	if Trace != nil {
+		Trace.Load(path, modEnv)
+	}
+	result := Eval(program, modEnv)
+	if Trace != nil {
+		Trace.Loaded(path)
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		wrapped := *errObj
+		wrapped.Message = fmt.Sprintf("in module %s: %s", path, errObj.Message)
+		return nil, &wrapped
+	}
+
+	if storedMod, ok := modEnv.Get(name); ok {
+		if moduleObj, ok := storedMod.(*object.Module); ok {
+			nameModule(moduleObj, name)
+			return moduleObj, nil
+		}
+	}
+	mod := &object.Module{Name: name, Env: modEnv, Exports: modEnv.Exports()}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		if decl, ok := stmt.(*ast.VarStatement); ok && decl.Doc != "" {
+			documentMember(mod, decl.Name.Value, decl.Doc)
+		}
+	}
+	return mod, nil
 }
 
-func loadModuleSource(name string, env *object.Env) ([]byte, error) {
-	possiblePaths := []string{
-		filepath.Join("/usr/local/lib/lynx/std", name+".lynx"),
-		filepath.Join(env.Dir, name+".lynx"),
-		filepath.Join("./modules", name+".lynx"),
-		filepath.Join(os.Getenv("HOME"), "modules", name+".lynx"),
-		filepath.Join("./std", name+".lynx"),
+// documentMember keeps the doc comment of a module member for help
+func documentMember(mod *object.Module, name, doc string) {
+	if mod.Docs == nil {
+		mod.Docs = make(map[string]string)
 	}
+	mod.Docs[name] = doc
+}
 
-	var existingPaths []string
-	var triedPaths []string
-	var lastErr error
-	for _, path := range possiblePaths {
-		_, err := os.ReadFile(path)
-		if err == nil {
-			existingPaths = append(existingPaths, path)
-		} else {
-			triedPaths = append(triedPaths, path)
-			lastErr = err
+// nameModule names a module object built with @module() after its file,
+// and its functions after the members they are bound to
+func nameModule(mod *object.Module, name string) {
+	if mod.Name != "anonymous" {
+		return
+	}
+	mod.Name = name
+	for _, member := range mod.Env.Names() {
+		val, _ := mod.Env.Get(member)
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = name + "." + member
 		}
 	}
+}
 
-	if len(existingPaths) > 1 {
-		return nil, fmt.Errorf("module %q conflicts: found in multiple paths: %v", name, existingPaths)
+// StdRoot prefixes the paths of modules embedded from the standard library
+const StdRoot = "<std>"
+
+// ModuleRoots are searched for imports before LYNX_PATH; the CLI fills it
+// with the roots and dependencies declared in the project's lynx.toml
+var ModuleRoots []string
+
+// SearchPath lists the directories searched for imported modules after the
+// importing file's own directory and before the embedded standard library:
+// ModuleRoots, then the LYNX_PATH entries when it is set, otherwise
+// ./modules and ~/modules
+func SearchPath() []string {
+	path := append([]string{}, ModuleRoots...)
+	if lynxPath := os.Getenv("LYNX_PATH"); lynxPath != "" {
+		return append(path, filepath.SplitList(lynxPath)...)
 	}
-	if len(existingPaths) == 1 {
-		data, err := os.ReadFile(existingPaths[0])
-		if err != nil {
-			return nil, err
+	return append(path,
+		"./modules",
+		filepath.Join(os.Getenv("HOME"), "modules"),
+	)
+}
+
+// FindModule returns the canonical path of the module imported as name from
+// a file in dir, with symlinks resolved so each file is loaded once. Paths
+// starting with ./ or ../ are relative to dir; dotted names such as
+// http.client map to http/client.lynx and resolve to the first match in dir,
+// each SearchPath directory and finally the embedded standard library, so a
+// file of the same name deliberately overrides a std module.
+func FindModule(name string, dir string) (string, error) {
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") || filepath.IsAbs(name) {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, name)
 		}
-		return data, nil
+		if !strings.HasSuffix(path, ".lynx") {
+			path += ".lynx"
+		}
+		if !isFile(path) {
+			return "", fmt.Errorf("could not find module %q at %s", name, path)
+		}
+		return canonicalPath(path)
+	}
+
+	file := filepath.Join(strings.Split(name, ".")...) + ".lynx"
+	searched := append([]string{dir}, SearchPath()...)
+	for _, searchDir := range append(searched, StdRoot) {
+		path := filepath.Join(searchDir, file)
+		if isFile(path) {
+			return canonicalPath(path)
+		}
+	}
+
+	return "", fmt.Errorf("could not find module %q in: %s or the standard library", name, strings.Join(searched, ", "))
+}
+
+// ReadModule returns the source of a module path found by FindModule
+func ReadModule(path string) ([]byte, error) {
+	if embedded, ok := stdFile(path); ok {
+		return std.Files.ReadFile(embedded)
+	}
+	return os.ReadFile(path)
+}
+
+// stdFile maps a path under StdRoot to its name in the embedded library
+func stdFile(path string) (string, bool) {
+	return strings.CutPrefix(filepath.ToSlash(path), StdRoot+"/")
+}
+
+func canonicalPath(path string) (string, error) {
+	if _, ok := stdFile(path); ok {
+		return path, nil
 	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
 
-	return nil, fmt.Errorf("could not find module %q in the following paths: %v (last error: %v)", name, triedPaths, lastErr)
+func isFile(path string) bool {
+	if embedded, ok := stdFile(path); ok {
+		_, err := fs.Stat(std.Files, embedded)
+		return err == nil
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
 }
 
 func evalSwitchStatement(node *ast.SwitchStatement, env *object.Env) object.Object {
@@ -1837,8 +2230,9 @@ func evalSwitchStatement(node *ast.SwitchStatement, env *object.Env) object.Obje
 	if isError(val) {
 		return val
 	}
-	for _, caseStmt := range node.Cases {
+	for i, caseStmt := range node.Cases {
 		if caseStmt.Value == nil {
+			traceBranch(node, i, env)
 			return Eval(caseStmt.Body, env)
 		}
 		if ident, ok := caseStmt.Value.(*ast.Identifier); ok {
@@ -1853,6 +2247,7 @@ func evalSwitchStatement(node *ast.SwitchStatement, env *object.Env) object.Obje
 					continue
 				}
 			}
+			traceBranch(node, i, env)
 			return Eval(caseStmt.Body, caseEnv)
 		}
 		caseValue := Eval(caseStmt.Value, env)
@@ -1869,13 +2264,20 @@ func evalSwitchStatement(node *ast.SwitchStatement, env *object.Env) object.Obje
 					continue
 				}
 			}
+			traceBranch(node, i, env)
 			return Eval(caseStmt.Body, env)
 		}
 	}
+	traceBranch(node, len(node.Cases), env)
 	return NULL
 }
 
 func objectsEqual(a, b object.Object) bool {
+	if inst, ok := a.(*object.Instance); ok {
+		if result, ok := object.MethodInvoker(inst, "eq", []object.Object{b}); ok {
+			return !isError(result) && isTruthy(result)
+		}
+	}
 	if a.Type() != b.Type() {
 		return false
 	}
@@ -1888,6 +2290,9 @@ func objectsEqual(a, b object.Object) bool {
 		return a.Value == b.(*object.Boolean).Value
 	case *object.Float:
 		return a.Value == b.(*object.Float).Value
+	case *object.Result:
+		bResult := b.(*object.Result)
+		return a.Ok == bResult.Ok && objectsEqual(a.Value, bResult.Value)
 	case *object.Array:
 		bArray := b.(*object.Array)
 		if len(a.Elements) != len(bArray.Elements) {
@@ -1925,16 +2330,49 @@ func evalPipeExpression(pe *ast.PipeExpression, env *object.Env) object.Object {
 }
 
 func evalPipeRight(right ast.Node, left object.Object, env *object.Env) object.Object {
+	if isError(left) {
+		return left
+	}
+
 	switch rightExpr := right.(type) {
 	case *ast.CallExpression:
 		fn := Eval(rightExpr.Function, env)
-		args := []object.Object{left}
-		for _, arg := range rightExpr.Arguments {
-			args = append(args, Eval(arg, env))
+		if isError(fn) {
+			return fn
+		}
+		args := evalPipeArguments(rightExpr.Arguments, left, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
 		}
 		return applyFunction(fn, args)
+	case *ast.MethodCall:
+		var obj object.Object
+		if isPlaceholder(rightExpr.Object) {
+			obj = left
+		} else {
+			obj = Eval(rightExpr.Object, env)
+			if isError(obj) {
+				return obj
+			}
+		}
+		var args []object.Object
+		if isPlaceholder(rightExpr.Object) && !hasPlaceholder(rightExpr.Arguments) {
+			args = evalExpressions(rightExpr.Arguments, env)
+		} else {
+			args = evalPipeArguments(rightExpr.Arguments, left, env)
+		}
+		if err := checkMemberAccess(obj, rightExpr.Method.Value, env); err != nil {
+			return err
+		}
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyMethod(obj, rightExpr.Method.Value, args)
 	case *ast.Identifier:
 		fn := Eval(rightExpr, env)
+		if isError(fn) {
+			return fn
+		}
 		return applyFunction(fn, []object.Object{left})
 	case *ast.FunctionLiteral:
 		fn := Eval(rightExpr, env)
@@ -1947,32 +2385,170 @@ func evalPipeRight(right ast.Node, left object.Object, env *object.Env) object.O
 	}
 }
 
+// evalPipeArguments evaluates the arguments of a pipe target. The piped
+// value replaces every `_` placeholder, or is passed first when none is used.
+func evalPipeArguments(exps []ast.Expression, piped object.Object, env *object.Env) []object.Object {
+	if !hasPlaceholder(exps) {
+		rest := evalExpressions(exps, env)
+		if len(rest) == 1 && isError(rest[0]) {
+			return rest
+		}
+		return append([]object.Object{piped}, rest...)
+	}
+
+	args := []object.Object{}
+	for _, e := range exps {
+		if isPlaceholder(e) {
+			args = append(args, piped)
+			continue
+		}
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		args = append(args, evaluated)
+	}
+	return args
+}
+
+func hasPlaceholder(exps []ast.Expression) bool {
+	for _, e := range exps {
+		if isPlaceholder(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlaceholder(exp ast.Expression) bool {
+	ident, ok := exp.(*ast.Identifier)
+	return ok && ident.Value == "_"
+}
+
 func evalErrorStatement(node *ast.ErrorStatement, env *object.Env) object.Object {
 	val := Eval(node.Value, env)
 	if isError(val) {
 		return val
 	}
-	return &object.Error{Message: val.Inspect()}
+
+	switch val := val.(type) {
+	case *object.Exception:
+		// Rethrow a caught exception unchanged
+		return raise(val)
+	case *object.Instance:
+		return raise(exceptionFromInstance(val))
+	default:
+		return raise(&object.Exception{Kind: "Error", Message: val.Inspect()})
+	}
+}
+
+func raise(exc *object.Exception) *object.Error {
+	message := exc.Message
+	if exc.Kind != "Error" {
+		message = exc.Inspect()
+	}
+	return &object.Error{Message: message, Exception: exc}
+}
+
+// exceptionFromInstance types an exception by the raised instance's class;
+// its attributes become the exception's fields
+func exceptionFromInstance(inst *object.Instance) *object.Exception {
+	exc := &object.Exception{
+		Kind:   inst.Class.Name,
+		Fields: inst.Attributes,
+		Class:  inst.Class,
+	}
+	if msg, ok := inst.Attributes["message"].(*object.String); ok {
+		exc.Message = msg.Value
+	} else {
+		exc.Message = inst.Inspect()
+	}
+	if cause, ok := inst.Attributes["cause"].(*object.Exception); ok {
+		exc.Cause = cause
+	}
+	return exc
+}
+
+// exceptionFromError returns the exception carried by err, wrapping runtime
+// errors raised by the interpreter itself as RuntimeError
+func exceptionFromError(err *object.Error) *object.Exception {
+	if err.Exception != nil {
+		return err.Exception
+	}
+	return &object.Exception{Kind: "RuntimeError", Message: err.Message}
+}
+
+func evalExceptionPropertyAccess(exc *object.Exception, property string) object.Object {
+	switch property {
+	case "type":
+		return &object.String{Value: exc.Kind}
+	case "message":
+		return &object.String{Value: exc.Message}
+	case "cause":
+		if exc.Cause == nil {
+			return NULL
+		}
+		return exc.Cause
+	}
+	if field, ok := exc.Fields[property]; ok {
+		return field
+	}
+	return newError("%s has no field: %s", exc.Kind, property)
 }
 
 func evalCatchStatement(catchStmt *ast.CatchStatement, env *object.Env) object.Object {
 	result := Eval(catchStmt.Body, env)
 
-	if errObj, ok := result.(*object.Error); ok && catchStmt.ErrorVar != nil {
-		catchEnv := env.NewEnclosedEnv()
-		catchEnv.Set(catchStmt.ErrorVar.Value, errObj, false)
-		return Eval(catchStmt.OnBody, catchEnv)
+	if errObj, ok := result.(*object.Error); ok && !errObj.Internal && errObj.Result == nil {
+		exc := exceptionFromError(errObj)
+		for _, handler := range catchStmt.Handlers {
+			if !handlerMatches(handler, exc) {
+				continue
+			}
+			catchEnv := env.NewEnclosedEnv()
+			catchEnv.Set(handler.ErrorVar.Value, exc, false)
+			result = Eval(handler.Body, catchEnv)
+			break
+		}
+	}
+
+	if catchStmt.Finally != nil {
+		// An error or jump out of finally replaces the pending result
+		final := Eval(catchStmt.Finally, env)
+		switch final.(type) {
+		case *object.Error, *object.Return, *object.Break, *object.Continue:
+			return final
+		}
 	}
 
 	return result
 }
 
+func handlerMatches(handler *ast.CatchHandler, exc *object.Exception) bool {
+	if len(handler.Types) == 0 {
+		return true
+	}
+	for _, kind := range handler.Types {
+		if exc.Is(kind.Value) {
+			return true
+		}
+	}
+	return false
+}
+
 func evalClassStatement(node *ast.Class, env *object.Env) object.Object {
+	classEnv := env.NewEnclosedEnv()
 	class := &object.Class{
 		Name:    node.Name.Value,
 		Methods: make(map[string]*object.Function),
-		Env:     env,
+		Statics: object.New(env.Dir),
+		Getters: make(map[string]*object.Function),
+		Setters: make(map[string]*object.Function),
+		Private: make(map[string]bool),
+		Env:     classEnv,
+		Doc:     node.Doc,
 	}
+	classEnv.Owner = class
 
 	if node.SuperClass != nil {
 		superClassObj, ok := env.Get(node.SuperClass.Value)
@@ -1984,30 +2560,205 @@ func evalClassStatement(node *ast.Class, env *object.Env) object.Object {
 			return newError("%s is not a class", node.SuperClass.Value)
 		}
 		class.SuperClass = superClass
+		class.Statics = superClass.Statics.NewEnclosedEnv()
+		classEnv.Set("super", superClass, true)
 
 		maps.Copy(class.Methods, superClass.Methods)
+		maps.Copy(class.Getters, superClass.Getters)
+		maps.Copy(class.Setters, superClass.Setters)
+		maps.Copy(class.Private, superClass.Private)
 	}
 
+	// Bind the class name before evaluating members so static initializers
+	// and methods can refer to it
+	env.Set(node.Name.Value, class, false)
+
+	ownMethods := make(map[string]bool)
 	if node.Body != nil {
 		for _, stmt := range node.Body.Statements {
-			if letStmt, ok := stmt.(*ast.VarStatement); ok {
-				if fnLit, ok := letStmt.Value.(*ast.FunctionLiteral); ok {
-					method := &object.Function{
-						Parameters: fnLit.Parameters,
-						Body:       fnLit.Body,
-						Env:        env,
-					}
-					class.Methods[letStmt.Name.Value] = method
-				}
+			if err := evalClassMember(class, stmt, ownMethods); err != nil {
+				return err
 			}
 		}
 	}
 
-	env.Set(node.Name.Value, class, false)
+	for _, traitName := range node.Traits {
+		if err := applyTrait(class, traitName, ownMethods, env); err != nil {
+			return err
+		}
+	}
 
 	return class
 }
 
+// evalClassMember adds one class body declaration to class
+func evalClassMember(class *object.Class, stmt ast.Statement, ownMethods map[string]bool) object.Object {
+	switch stmt := stmt.(type) {
+	case *ast.VarStatement:
+		if fnLit, ok := stmt.Value.(*ast.FunctionLiteral); ok && !stmt.IsConst {
+			class.Methods[stmt.Name.Value] = &object.Function{
+				Parameters: fnLit.Parameters,
+				Body:       fnLit.Body,
+				Env:        class.Env,
+				Name:       class.Name + "." + stmt.Name.Value,
+				ParamTypes: fnLit.ParamTypes,
+				ReturnType: fnLit.ReturnType,
+				Doc:        fnLit.Doc,
+			}
+			ownMethods[stmt.Name.Value] = true
+			return nil
+		}
+		if stmt.IsConst {
+			return evalStaticMember(class, stmt)
+		}
+		class.Fields = append(class.Fields, stmt)
+	case *ast.StaticStatement:
+		return evalStaticMember(class, stmt.Statement)
+	case *ast.Accessor:
+		fnLit := stmt.Value.(*ast.FunctionLiteral)
+		fn := &object.Function{
+			Parameters: fnLit.Parameters,
+			Body:       fnLit.Body,
+			Env:        class.Env,
+			Name:       class.Name + "." + stmt.Name.Value,
+			ParamTypes: fnLit.ParamTypes,
+			ReturnType: fnLit.ReturnType,
+			Doc:        fnLit.Doc,
+		}
+		if stmt.Kind == "get" {
+			if len(fn.Parameters) != 0 {
+				return newError("getter %s.%s must take no parameters", class.Name, stmt.Name.Value)
+			}
+			class.Getters[stmt.Name.Value] = fn
+		} else {
+			if len(fn.Parameters) != 1 {
+				return newError("setter %s.%s must take exactly one parameter", class.Name, stmt.Name.Value)
+			}
+			class.Setters[stmt.Name.Value] = fn
+		}
+	case *ast.PrivateStatement:
+		switch member := stmt.Statement.(type) {
+		case *ast.VarStatement:
+			class.Private[member.Name.Value] = true
+		case *ast.StaticStatement:
+			class.Private[member.Statement.Name.Value] = true
+		case *ast.Accessor:
+			class.Private[member.Name.Value] = true
+		}
+		return evalClassMember(class, stmt.Statement, ownMethods)
+	default:
+		return newError("unexpected %s in body of class %s", stmt.TokenLiteral(), class.Name)
+	}
+	return nil
+}
+
+// checkMemberAccess rejects reads and writes of private members from code
+// outside the methods of the owning class and its subclasses
+func checkMemberAccess(obj object.Object, member string, env *object.Env) object.Object {
+	var class *object.Class
+	switch obj := obj.(type) {
+	case *object.Instance:
+		class = obj.Class
+	case *object.BoundSuper:
+		class = obj.Class
+	case *object.Class:
+		class = obj
+	default:
+		return nil
+	}
+	if !class.IsPrivate(member) {
+		return nil
+	}
+
+	owner := env.EnclosingClass()
+	if owner != nil && (owner.IsSubclassOf(class) || class.IsSubclassOf(owner)) {
+		return nil
+	}
+	return newError("cannot access private member %s of %s outside its class", member, class.Name)
+}
+
+// applyTrait mixes a trait's default methods into class, without replacing
+// methods the class defines itself, then verifies the required methods
+func applyTrait(class *object.Class, name *ast.Identifier, ownMethods map[string]bool, env *object.Env) object.Object {
+	traitObj, ok := env.Get(name.Value)
+	if !ok {
+		return newError("Undefined trait: %s", name.Value)
+	}
+	trait, ok := traitObj.(*object.Trait)
+	if !ok {
+		return newError("%s is not a trait", name.Value)
+	}
+
+	for methodName, fn := range trait.Defaults {
+		if !ownMethods[methodName] {
+			// Rebind so the default method sees the class's private members
+			methodEnv := fn.Env.NewEnclosedEnv()
+			methodEnv.Owner = class
+			class.Methods[methodName] = &object.Function{
+				Parameters: fn.Parameters,
+				Body:       fn.Body,
+				Env:        methodEnv,
+				Name:       class.Name + "." + methodName,
+				Doc:        fn.Doc,
+			}
+		}
+	}
+
+	missing := []string{}
+	for methodName, arity := range trait.Required {
+		fn, ok := class.Methods[methodName]
+		if !ok {
+			missing = append(missing, methodName)
+			continue
+		}
+		if len(fn.Parameters) != arity {
+			return newError("class %s does not implement %s: method %s takes %d parameters, want %d",
+				class.Name, trait.Name, methodName, len(fn.Parameters), arity)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+		return newError("class %s does not implement %s: missing method(s) %s",
+			class.Name, trait.Name, strings.Join(missing, ", "))
+	}
+
+	class.Traits = append(class.Traits, trait)
+	return nil
+}
+
+func evalTraitStatement(node *ast.Trait, env *object.Env) object.Object {
+	trait := &object.Trait{
+		Name:     node.Name.Value,
+		Required: make(map[string]int),
+		Defaults: make(map[string]*object.Function),
+	}
+
+	for _, method := range node.Methods {
+		if method.Body == nil {
+			trait.Required[method.Name.Value] = len(method.Parameters)
+			continue
+		}
+		trait.Defaults[method.Name.Value] = &object.Function{
+			Parameters: method.Parameters,
+			Body:       method.Body,
+			Env:        env,
+		}
+	}
+
+	env.Set(node.Name.Value, trait, false)
+	return trait
+}
+
+// evalStaticMember evaluates a class-level binding into the class's statics
+func evalStaticMember(class *object.Class, stmt *ast.VarStatement) object.Object {
+	val := Eval(stmt.Value, class.Env)
+	if isError(val) {
+		return val
+	}
+	class.Statics.Set(stmt.Name.Value, val, stmt.IsConst)
+	return nil
+}
+
 func evalSelf(env *object.Env) object.Object {
 	self, ok := env.Get("self")
 	if !ok {
@@ -2015,3 +2766,45 @@ func evalSelf(env *object.Env) object.Object {
 	}
 	return self
 }
+
+func evalSuper(env *object.Env) object.Object {
+	superClass, ok := env.Get("super")
+	if !ok {
+		return newError("'super' can only be used inside a subclass method")
+	}
+	self, ok := env.Get("self")
+	if !ok {
+		return newError("'super' can only be used inside an instance method")
+	}
+	instance, ok := self.(*object.Instance)
+	if !ok {
+		return newError("'super' requires self to be an instance, got %s", self.Type())
+	}
+	return &object.BoundSuper{Instance: instance, Class: superClass.(*object.Class)}
+}
+
+func evalInstanceOf(left, right object.Object) object.Object {
+	if trait, ok := right.(*object.Trait); ok {
+		return evalImplements(left, trait)
+	}
+	class, ok := right.(*object.Class)
+	if !ok {
+		return newError("right operand of 'instanceof' must be CLASS or TRAIT, got %s", right.Type())
+	}
+	instance, ok := left.(*object.Instance)
+	if !ok {
+		return FALSE
+	}
+	return nativeBoolToBooleanObject(instance.Class.IsSubclassOf(class))
+}
+
+func evalImplements(obj object.Object, trait *object.Trait) object.Object {
+	switch obj := obj.(type) {
+	case *object.Instance:
+		return nativeBoolToBooleanObject(obj.Class.Implements(trait))
+	case *object.Class:
+		return nativeBoolToBooleanObject(obj.Implements(trait))
+	default:
+		return FALSE
+	}
+}
@@ -0,0 +1,153 @@
+package evaluator
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/object"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// builtinHelp prints the documentation of a function, class or module:
+// its signature, its /// doc comment and, for classes and modules, a line
+// for each method or member
+func builtinHelp(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got %d, expected 1", len(args))
+	}
+	text, ok := helpText(args[0])
+	if !ok {
+		return newError("help: no documentation for %s", DescribeType(args[0]))
+	}
+	fmt.Print(text)
+	return NULL
+}
+
+func helpText(obj object.Object) (string, bool) {
+	var out strings.Builder
+	switch obj := obj.(type) {
+	case *object.Function:
+		out.WriteString(functionSignature(obj) + "\n")
+		writeDoc(&out, obj.Doc)
+	case *object.Builtin:
+		fmt.Fprintf(&out, "%s(...)\n\nBuilt-in function.\n", obj.Name)
+	case *object.Class:
+		out.WriteString("class " + obj.Name)
+		if obj.SuperClass != nil {
+			out.WriteString("(" + obj.SuperClass.Name + ")")
+		}
+		out.WriteString("\n")
+		writeDoc(&out, obj.Doc)
+		names := []string{}
+		for name := range obj.Methods {
+			if !obj.IsPrivate(name) {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		members := [][2]string{}
+		for _, name := range names {
+			fn := obj.Methods[name]
+			members = append(members, [2]string{name + ast.Signature(fn.Parameters, fn.ParamTypes, fn.ReturnType), summary(fn.Doc)})
+		}
+		writeMembers(&out, "Methods", members)
+	case *object.Module:
+		out.WriteString("module " + obj.Name + "\n")
+		writeDoc(&out, obj.Doc)
+		members := [][2]string{}
+		for _, name := range moduleMembers(obj) {
+			val, _ := obj.Get(name)
+			switch val := val.(type) {
+			case *object.Function:
+				members = append(members, [2]string{name + ast.Signature(val.Parameters, val.ParamTypes, val.ReturnType), summary(val.Doc)})
+			case *object.Class:
+				members = append(members, [2]string{"class " + name, summary(val.Doc)})
+			default:
+				members = append(members, [2]string{name, summary(obj.Docs[name])})
+			}
+		}
+		writeMembers(&out, "Members", members)
+	default:
+		return "", false
+	}
+	return out.String(), true
+}
+
+// functionSignature is a function's name and parameters, as in area(w, h)
+func functionSignature(fn *object.Function) string {
+	name := fn.Name
+	if name == "" {
+		name = "fn"
+	}
+	return name + ast.Signature(fn.Parameters, fn.ParamTypes, fn.ReturnType)
+}
+
+// moduleMembers lists the names importers of mod can use, other than
+// private ones starting with an underscore
+func moduleMembers(mod *object.Module) []string {
+	names := []string{}
+	if mod.Members != nil {
+		for name := range mod.Members {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		for _, name := range mod.Env.Names() {
+			if _, ok := mod.Get(name); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	visible := names[:0]
+	for _, name := range names {
+		if !strings.HasPrefix(name, "_") {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+func writeDoc(out *strings.Builder, doc string) {
+	if doc != "" {
+		out.WriteString("\n" + doc + "\n")
+	}
+}
+
+// writeMembers writes a table of signatures and the first sentence of
+// their docs
+func writeMembers(out *strings.Builder, title string, members [][2]string) {
+	if len(members) == 0 {
+		return
+	}
+	width := 0
+	for _, m := range members {
+		width = max(width, len(m[0]))
+	}
+	out.WriteString("\n" + title + ":\n")
+	for _, m := range members {
+		if m[1] == "" {
+			out.WriteString("    " + m[0] + "\n")
+			continue
+		}
+		fmt.Fprintf(out, "    %-*s  %s\n", width, m[0], m[1])
+	}
+}
+
+// summary is the first sentence of a doc comment, as go doc shows it: the
+// lines of its first paragraph are joined and cut after the first period
+// followed by a space, unless the period ends an initial such as "J."
+func summary(doc string) string {
+	paragraph, _, _ := strings.Cut(doc, "\n\n")
+	text := strings.Join(strings.Fields(paragraph), " ")
+	for i := 0; i < len(text); i++ {
+		if text[i] != '.' || (i+1 < len(text) && text[i+1] != ' ') {
+			continue
+		}
+		initial := i >= 1 && unicode.IsUpper(rune(text[i-1])) && (i == 1 || text[i-2] == ' ')
+		if !initial {
+			return text[:i+1]
+		}
+	}
+	return text
+}
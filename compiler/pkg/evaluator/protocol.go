@@ -0,0 +1,163 @@
+package evaluator
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"lynx/pkg/object"
+)
+
+// Special method names consulted for infix operators on class instances
+var operatorMethods = map[string]string{
+	"+":  "add",
+	"-":  "sub",
+	"*":  "mul",
+	"/":  "div",
+	"%":  "mod",
+	"^":  "pow",
+	"++": "concat",
+	"==": "eq",
+	"!=": "ne",
+	"<":  "lt",
+	">":  "gt",
+	"<=": "le",
+	">=": "ge",
+}
+
+// Mirrored comparison methods tried on the right operand, e.g. a < b as b.gt(a)
+var reflectedMethods = map[string]string{
+	"==": "eq",
+	"!=": "ne",
+	"<":  "gt",
+	">":  "lt",
+	"<=": "ge",
+	">=": "le",
+}
+
+func init() {
+	object.MethodInvoker = callSpecialMethod
+}
+
+// callSpecialMethod invokes a protocol method on an instance. The boolean
+// result reports whether the class defines the method at all.
+func callSpecialMethod(inst *object.Instance, method string, args []object.Object) (object.Object, bool) {
+	fn, ok := inst.Class.Methods[method]
+	if !ok {
+		return nil, false
+	}
+	return callMethod(inst, fn, args), true
+}
+
+func callMethod(inst *object.Instance, fn *object.Function, args []object.Object) object.Object {
+	if len(args) != len(fn.Parameters) {
+		return newError("wrong number of arguments: want=%d, got=%d",
+			len(fn.Parameters), len(args))
+	}
+	methodEnv, err := extendFunctionEnv(fn, args)
+	if err != nil {
+		return err
+	}
+	methodEnv.Set("self", inst, false)
+	return checkReturnType(fn, evalFunctionBody(fn, methodEnv))
+}
+
+func evalInstanceInfixExpression(operator string, left, right object.Object) (object.Object, bool) {
+	if inst, ok := left.(*object.Instance); ok {
+		if operator == "in" {
+			return nil, false
+		}
+		if method, ok := operatorMethods[operator]; ok {
+			if result, ok := callSpecialMethod(inst, method, []object.Object{right}); ok {
+				return result, true
+			}
+		}
+		if operator == "!=" {
+			if result, ok := callSpecialMethod(inst, "eq", []object.Object{right}); ok {
+				if isError(result) {
+					return result, true
+				}
+				return nativeBoolToBooleanObject(!isTruthy(result)), true
+			}
+		}
+	}
+
+	if inst, ok := right.(*object.Instance); ok {
+		if operator == "in" {
+			result, ok := callSpecialMethod(inst, "contains", []object.Object{left})
+			if !ok {
+				return newError("%s does not define contains", inst.Class.Name), true
+			}
+			if isError(result) {
+				return result, true
+			}
+			return nativeBoolToBooleanObject(isTruthy(result)), true
+		}
+		if method, ok := reflectedMethods[operator]; ok {
+			if result, ok := callSpecialMethod(inst, method, []object.Object{left}); ok {
+				return result, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// hashKeyOf returns the hash key for obj. An instance's key combines its
+// class with what its hash method returns, which unequal instances may
+// share, so instances are looked up with findPair.
+func hashKeyOf(obj object.Object) (object.HashKey, object.Object) {
+	if inst, ok := obj.(*object.Instance); ok {
+		result, ok := callSpecialMethod(inst, "hash", []object.Object{})
+		if !ok {
+			return object.HashKey{}, newError("unusable as hash key: %s", inst.Class.Name)
+		}
+		if _, ok := inst.Class.Methods["eq"]; !ok {
+			return object.HashKey{}, newError("unusable as hash key: %s defines hash but not eq", inst.Class.Name)
+		}
+		if isError(result) {
+			return object.HashKey{}, result
+		}
+		hashable, ok := result.(object.Hashable)
+		if !ok {
+			return object.HashKey{}, newError("%s.hash must return a hashable value, got %s",
+				inst.Class.Name, result.Type())
+		}
+		h := fnv.New64a()
+		h.Write([]byte(inst.Class.Name))
+		binary.Write(h, binary.LittleEndian, hashable.HashKey().Value)
+		return object.HashKey{Type: object.INSTANCE_OBJ, Value: h.Sum64()}, nil
+	}
+
+	hashable, ok := obj.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newError("unusable as hash key: %s", obj.Type())
+	}
+	return hashable.HashKey(), nil
+}
+
+// findPair looks key up in hash, returning the slot holding it or, when it
+// is missing, the slot it would go in. Instances whose keys collide take the
+// following slots; each candidate of the same class is compared with eq.
+// Pairs are never removed from a hash, so a free slot ends the search.
+func findPair(hash *object.Hash, key object.Object) (object.HashKey, object.HashPair, bool, object.Object) {
+	slot, errObj := hashKeyOf(key)
+	if errObj != nil {
+		return slot, object.HashPair{}, false, errObj
+	}
+	inst, isInstance := key.(*object.Instance)
+	for {
+		pair, ok := hash.Pairs[slot]
+		if !ok || !isInstance {
+			return slot, pair, ok, nil
+		}
+		if other, ok := pair.Key.(*object.Instance); ok && other.Class == inst.Class {
+			result := callMethod(inst, inst.Class.Methods["eq"], []object.Object{other})
+			if isError(result) {
+				return slot, pair, false, result
+			}
+			if isTruthy(result) {
+				return slot, pair, true, nil
+			}
+		}
+		slot.Value++
+	}
+}
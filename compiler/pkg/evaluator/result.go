@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"lynx/pkg/object"
+)
+
+func okResult(value object.Object) *object.Result {
+	return &object.Result{Ok: true, Value: value}
+}
+
+func errResult(value object.Object) *object.Result {
+	return &object.Result{Ok: false, Value: value}
+}
+
+// evalTryExpression unwraps an Ok, or unwinds to the enclosing function
+// which then returns the Err to its caller
+func evalTryExpression(val object.Object) object.Object {
+	result, ok := val.(*object.Result)
+	if !ok {
+		return newError("'?' expects a result, got %s", val.Type())
+	}
+	if result.Ok {
+		return result.Value
+	}
+	return &object.Error{
+		Message: "'?' outside a function propagated " + result.Inspect(),
+		Result:  result,
+	}
+}
+
+func evalResultMethod(result *object.Result, method string, args []object.Object) object.Object {
+	switch method {
+	case "isOk", "isErr", "unwrap":
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+	case "unwrapOr", "map", "mapErr":
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+	}
+
+	switch method {
+	case "isOk":
+		return nativeBoolToBooleanObject(result.Ok)
+	case "isErr":
+		return nativeBoolToBooleanObject(!result.Ok)
+	case "unwrap":
+		if result.Ok {
+			return result.Value
+		}
+		if exc, ok := result.Value.(*object.Exception); ok {
+			return raise(exc)
+		}
+		return raise(&object.Exception{Kind: "UnwrapError", Message: "called unwrap on " + result.Inspect()})
+	case "unwrapOr":
+		if result.Ok {
+			return result.Value
+		}
+		return args[0]
+	case "map":
+		if !result.Ok {
+			return result
+		}
+		mapped := applyFunction(args[0], []object.Object{result.Value})
+		if isError(mapped) {
+			return mapped
+		}
+		return okResult(mapped)
+	case "mapErr":
+		if result.Ok {
+			return result
+		}
+		mapped := applyFunction(args[0], []object.Object{result.Value})
+		if isError(mapped) {
+			return mapped
+		}
+		return errResult(mapped)
+	default:
+		return newError("unknown method: %s", method)
+	}
+}
+
+func builtinOk(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got %d, expected 1", len(args))
+	}
+	return okResult(args[0])
+}
+
+func builtinErr(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got %d, expected 1", len(args))
+	}
+	return errResult(args[0])
+}
+
+// builtinAttempt calls fn with the remaining arguments and returns its
+// value as Ok, or the error it raised as an Err holding the exception
+func builtinAttempt(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError("wrong number of arguments. got %d, expected at least 1", len(args))
+	}
+	val := applyFunction(args[0], args[1:])
+	if err, ok := val.(*object.Error); ok {
+		if err.Internal {
+			return err
+		}
+		return errResult(exceptionFromError(err))
+	}
+	if result, ok := val.(*object.Result); ok {
+		return result
+	}
+	return okResult(val)
+}
+
+func evalResultEqual(left, right object.Object) object.Object {
+	return nativeBoolToBooleanObject(objectsEqual(left, right))
+}
+
+func evalResultNotEqual(left, right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!objectsEqual(left, right))
+}
@@ -0,0 +1,174 @@
+package evaluator
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/object"
+	"strings"
+)
+
+// StrictTypes makes calls check annotated parameters and return values
+var StrictTypes bool
+
+// checkArgumentTypes verifies args against fn's parameter annotations
+func checkArgumentTypes(fn *object.Function, args []object.Object) *object.Error {
+	for i, ta := range fn.ParamTypes {
+		if ta == nil || i >= len(args) {
+			continue
+		}
+		ok, err := matchesType(args[i], ta, fn.Env)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return typeError("%s: parameter %s expects %s, got %s",
+				functionName(fn), fn.Parameters[i].Value, ta, DescribeType(args[i]))
+		}
+	}
+	return nil
+}
+
+// checkReturnType verifies a call's result against fn's return annotation
+func checkReturnType(fn *object.Function, result object.Object) object.Object {
+	if !StrictTypes || fn.ReturnType == nil || isError(result) {
+		return result
+	}
+	// An empty body evaluates to nothing, which callers see as null
+	if result == nil {
+		result = NULL
+	}
+	ok, err := matchesType(result, fn.ReturnType, fn.Env)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return typeError("%s: return value expects %s, got %s",
+			functionName(fn), fn.ReturnType, DescribeType(result))
+	}
+	return result
+}
+
+func typeError(format string, a ...any) *object.Error {
+	return raise(&object.Exception{Kind: "TypeError", Message: fmt.Sprintf(format, a...)})
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "anonymous function"
+	}
+	return fn.Name
+}
+
+// matchesType reports whether obj is a value of the annotated type. Class
+// and trait names are resolved in env, the environment the function was defined in.
+func matchesType(obj object.Object, ta *ast.TypeAnnotation, env *object.Env) (bool, *object.Error) {
+	if len(ta.Union) > 0 {
+		for _, alt := range ta.Union {
+			ok, err := matchesType(obj, alt, env)
+			if ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+	if ta.Nullable && obj == NULL {
+		return true, nil
+	}
+
+	switch ta.Name {
+	case "any":
+		return true, nil
+	case "int":
+		_, ok := obj.(*object.Integer)
+		return ok, nil
+	case "float":
+		switch obj.(type) {
+		case *object.Float, *object.Integer:
+			return true, nil
+		}
+		return false, nil
+	case "str":
+		_, ok := obj.(*object.String)
+		return ok, nil
+	case "bool":
+		_, ok := obj.(*object.Boolean)
+		return ok, nil
+	case "null":
+		return obj == NULL, nil
+	case "fn":
+		switch obj.(type) {
+		case *object.Function, *object.Builtin, *object.Class:
+			return true, nil
+		}
+		return false, nil
+	case "result":
+		_, ok := obj.(*object.Result)
+		return ok, nil
+	case "exception":
+		_, ok := obj.(*object.Exception)
+		return ok, nil
+	case "array":
+		arr, ok := obj.(*object.Array)
+		if !ok || len(ta.Params) == 0 {
+			return ok, nil
+		}
+		for _, el := range arr.Elements {
+			if ok, err := matchesType(el, ta.Params[0], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	case "hash":
+		hash, ok := obj.(*object.Hash)
+		if !ok || len(ta.Params) != 2 {
+			return ok, nil
+		}
+		for _, pair := range hash.Pairs {
+			if ok, err := matchesType(pair.Key, ta.Params[0], env); !ok || err != nil {
+				return false, err
+			}
+			if ok, err := matchesType(pair.Value, ta.Params[1], env); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	val, ok := env.Get(ta.Name)
+	if !ok {
+		return false, typeError("unknown type %s", ta.Name)
+	}
+	switch val := val.(type) {
+	case *object.Class:
+		inst, ok := obj.(*object.Instance)
+		return ok && inst.Class.IsSubclassOf(val), nil
+	case *object.Trait:
+		_, ok := obj.(*object.Instance)
+		return ok && evalImplements(obj, val) == TRUE, nil
+	}
+	return false, typeError("%s is not a type", ta.Name)
+}
+
+// DescribeType names obj's runtime type for messages, including
+// element types of arrays so mismatches inside containers are visible
+func DescribeType(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.Function, *object.Builtin:
+		return "fn"
+	case *object.Array:
+		elems := []string{}
+		seen := map[string]bool{}
+		for _, el := range obj.Elements {
+			name := DescribeType(el)
+			if !seen[name] {
+				seen[name] = true
+				elems = append(elems, name)
+			}
+		}
+		if len(elems) == 0 {
+			return "array"
+		}
+		return "array[" + strings.Join(elems, " | ") + "]"
+	}
+	return builtinType(obj).Inspect()
+}
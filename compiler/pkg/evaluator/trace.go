@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"lynx/pkg/ast"
+	"lynx/pkg/object"
+)
+
+// Tracer observes a running program, for tools such as the debugger
+type Tracer interface {
+	// Statement is called before each statement runs, with the innermost
+	// environment it runs in
+	Statement(stmt ast.Statement, env *object.Env)
+	// Call and Return bracket every call of a Lynx function; env is the
+	// call's frame
+	Call(fn *object.Function, env *object.Env)
+	Return(fn *object.Function, result object.Object)
+	// Load and Loaded bracket running the top level of an imported module
+	Load(path string, env *object.Env)
+	Loaded(path string)
+	// CallBuiltin and ReturnBuiltin bracket every call of a builtin, named
+	// as it is called: len, or str.upper for a method of a built-in type
+	CallBuiltin(name string)
+	ReturnBuiltin(name string)
+	// Branch is called as an if or switch picks the branch to run: 0 for
+	// an if's consequence and 1 for its alternative, which may be empty, or
+	// the index of the switch case, len(Cases) if none matched
+	Branch(node ast.Node, taken int, env *object.Env)
+}
+
+// Trace is notified as programs run when set. It is nil unless a tool
+// installs one, which keeps the cost to a check per statement and call.
+var Trace Tracer
+
+func traceBranch(node ast.Node, taken int, env *object.Env) {
+	if Trace != nil {
+		Trace.Branch(node, taken, env)
+	}
+}
+
+func traceBuiltin(name string, call func() object.Object) object.Object {
+	Trace.CallBuiltin(name)
+	result := call()
+	Trace.ReturnBuiltin(name)
+	return result
+}
+
+// CallName names a call of fn for display: its binding name, which is
+// Class.name for a method, or <anonymous>
+func CallName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "<anonymous>"
+	}
+	return fn.Name
+}
@@ -0,0 +1,138 @@
+// Package format prints Lynx programs in their canonical layout: four-space
+// indentation, one statement per line, single blank lines kept between
+// statements and comments put back where they were written. Formatting
+// already formatted source returns it unchanged.
+package format
+
+import (
+	"errors"
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"lynx/pkg/token"
+	"math"
+	"strings"
+)
+
+const indentation = "    "
+
+// Source formats a program. Source that does not parse is returned as an
+// error listing the parse errors, since it cannot be printed faithfully.
+func Source(src string) (string, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return "", errors.New(strings.Join(p.ErrorStrings(), "\n"))
+	}
+	return Program(program), nil
+}
+
+// Program prints a parsed program along with its comments
+func Program(program *ast.Program) string {
+	p := &printer{
+		comments: program.Comments,
+		blank:    make(map[int]bool),
+	}
+	for _, line := range program.BlankLines {
+		p.blank[line] = true
+	}
+
+	first := true
+	p.statements(program.Statements, math.MaxInt, &first)
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+	return p.out.String()
+}
+
+type printer struct {
+	out      strings.Builder
+	depth    int
+	comments []token.Comment
+	next     int // index of the first comment not printed yet
+	blank    map[int]bool
+}
+
+// line starts a new line at the current indentation, preceded by an empty
+// line when the source had one there. Nothing is written before the first
+// line of the output.
+func (p *printer) line(blank bool) {
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+		if blank {
+			p.out.WriteString("\n")
+		}
+	}
+	p.out.WriteString(strings.Repeat(indentation, p.depth))
+}
+
+// commentsBefore prints the pending comments that start before line.
+// Trailing comments go at the end of the current output line, the others
+// on lines of their own; first tracks whether the enclosing list has
+// printed anything yet, since a list never starts with a blank line.
+func (p *printer) commentsBefore(line int, first *bool) {
+	for p.next < len(p.comments) && p.comments[p.next].Line < line {
+		c := p.comments[p.next]
+		p.next++
+		if c.Trailing && p.out.Len() > 0 {
+			p.out.WriteString(" " + c.Text)
+			continue
+		}
+		p.line(!*first && p.blank[c.Line-1])
+		p.out.WriteString(c.Text)
+		*first = false
+	}
+}
+
+// item starts a line for a list element that begins at line
+func (p *printer) item(line int, first *bool) {
+	p.commentsBefore(line, first)
+	p.line(!*first && p.blank[line-1])
+	*first = false
+}
+
+func (p *printer) statements(stmts []ast.Statement, end int, first *bool) {
+	for _, stmt := range stmts {
+		p.item(ast.StartToken(stmt).Line, first)
+		p.statement(stmt)
+	}
+	p.commentsBefore(end, first)
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	if p.oneLine(block) {
+		p.out.WriteString("{ ")
+		p.statement(block.Statements[0])
+		p.out.WriteString(" }")
+		return
+	}
+
+	p.out.WriteString("{")
+	p.depth++
+	first := true
+	p.statements(block.Statements, block.End.Line, &first)
+	p.depth--
+	if !first {
+		p.line(false)
+	}
+	p.out.WriteString("}")
+}
+
+// oneLine reports whether a block was written on a single line with one
+// statement and no comments, like `fn(x) { x * 2 }`, and stays that way
+func (p *printer) oneLine(block *ast.BlockStatement) bool {
+	if len(block.Statements) != 1 || block.Token.Line != block.End.Line {
+		return false
+	}
+	if p.next == len(p.comments) {
+		return true
+	}
+	c := p.comments[p.next]
+	return c.Line > block.End.Line || c.Line == block.End.Line && c.Column > block.End.Column
+}
+
+func (p *printer) write(parts ...string) {
+	for _, part := range parts {
+		p.out.WriteString(part)
+	}
+}
@@ -0,0 +1,479 @@
+package format
+
+import (
+	"lynx/pkg/ast"
+	"lynx/pkg/parser"
+	"lynx/pkg/token"
+	"strconv"
+	"strings"
+)
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch s := stmt.(type) {
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression, parser.LOWEST)
+	case *ast.VarStatement:
+		p.varStatement(s)
+	case *ast.Assignment:
+		p.expr(s.Name, parser.LOWEST)
+		p.write(" = ")
+		p.expr(s.Value, parser.LOWEST)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if s.Value != nil {
+			p.write(" ")
+			p.expr(s.Value, parser.LOWEST)
+		}
+	case *ast.ForRange:
+		p.write("for ", s.Variable.Value)
+		if s.Index != nil {
+			p.write(", ", s.Index.Value)
+		}
+		p.write(" in ")
+		p.expr(s.Collection, parser.LOWEST)
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.While:
+		p.write("while ")
+		p.expr(s.Condition, parser.LOWEST)
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.Break:
+		p.write("break")
+	case *ast.Continue:
+		p.write("continue")
+	case *ast.ModuleLoad:
+		p.moduleLoad(s)
+	case *ast.SwitchStatement:
+		p.switchStatement(s)
+	case *ast.ErrorStatement:
+		p.write("error ")
+		p.expr(s.Value, parser.LOWEST)
+	case *ast.DeferStatement:
+		p.write("defer ")
+		if s.Body != nil {
+			p.block(s.Body)
+		} else {
+			p.expr(s.Value, parser.LOWEST)
+		}
+	case *ast.CatchStatement:
+		p.catchStatement(s)
+	case *ast.Class:
+		p.write("class ", s.Name.Value)
+		if s.SuperClass != nil {
+			p.write("(", s.SuperClass.Value, ")")
+		}
+		if len(s.Traits) > 0 {
+			p.write(" impl ", identifiers(s.Traits))
+		}
+		p.write(" ")
+		p.block(s.Body)
+	case *ast.Accessor:
+		p.write(s.Kind, " ", s.Name.Value, " = ")
+		p.expr(s.Value, parser.LOWEST)
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(s.Statement)
+	case *ast.PrivateStatement:
+		p.write("private ")
+		p.statement(s.Statement)
+	case *ast.StaticStatement:
+		p.write("static ")
+		p.statement(s.Statement)
+	case *ast.Trait:
+		p.trait(s)
+	default:
+		p.write(stmt.String())
+	}
+}
+
+func (p *printer) varStatement(s *ast.VarStatement) {
+	if fn, ok := s.Value.(*ast.FunctionLiteral); ok && s.IsFnDecl {
+		p.write("fn ", s.Name.Value)
+		p.signature(fn)
+		p.block(fn.Body)
+		return
+	}
+
+	if s.IsConst {
+		p.write("const ")
+	} else {
+		p.write("let ")
+	}
+	p.write(s.Name.Value)
+	if s.Type != nil {
+		p.write(": ", s.Type.String())
+	}
+	p.write(" = ")
+	p.expr(s.Value, parser.LOWEST)
+}
+
+// signature prints `(params) -> type ` for fn literals and declarations
+func (p *printer) signature(fn *ast.FunctionLiteral) {
+	p.write("(", parameters(fn), ") ")
+	if fn.ReturnType != nil {
+		p.write("-> ", fn.ReturnType.String(), " ")
+	}
+}
+
+func parameters(fn *ast.FunctionLiteral) string {
+	var out strings.Builder
+	for i, param := range fn.Parameters {
+		// A variadic marker follows the parameter before it directly
+		if param.Token.Type == token.SPREAD {
+			out.WriteString("...")
+			continue
+		}
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString(param.Value)
+		if i < len(fn.ParamTypes) && fn.ParamTypes[i] != nil {
+			out.WriteString(": " + fn.ParamTypes[i].String())
+		}
+	}
+	return out.String()
+}
+
+func identifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+func (p *printer) moduleLoad(m *ast.ModuleLoad) {
+	p.write("@")
+	if m.IsRelative() {
+		p.write(quote(m.Path()))
+	} else {
+		p.write(m.Path())
+	}
+	if m.Alias != nil {
+		p.write(" as ", m.Alias.Value)
+	}
+	if m.Members != nil {
+		p.write("(", identifiers(m.Members), ")")
+	}
+}
+
+func (p *printer) switchStatement(s *ast.SwitchStatement) {
+	p.write("switch ")
+	p.expr(s.Expression, parser.LOWEST)
+	p.write(" {")
+	p.depth++
+	first := true
+	for _, c := range s.Cases {
+		p.item(ast.StartToken(c).Line, &first)
+		if c.Value == nil {
+			p.write("default: ")
+		} else {
+			p.write("case ")
+			p.expr(c.Value, parser.LOWEST)
+			if c.Guard != nil {
+				p.write(" if ")
+				p.expr(c.Guard, parser.LOWEST)
+			}
+			p.write(": ")
+		}
+		switch {
+		case c.Body == nil:
+		case c.Body.End.Type == token.RBRACE:
+			p.block(c.Body)
+		case len(c.Body.Statements) == 1:
+			// A case written without braces holds exactly one statement
+			p.statement(c.Body.Statements[0])
+		}
+	}
+	p.depth--
+	p.line(false)
+	p.write("}")
+}
+
+func (p *printer) catchStatement(s *ast.CatchStatement) {
+	p.write("catch ")
+	p.block(s.Body)
+	for _, h := range s.Handlers {
+		p.write(" on ", h.ErrorVar.Value)
+		if len(h.Types) > 0 {
+			p.write(": ", identifiers(h.Types))
+		}
+		p.write(" ")
+		p.block(h.Body)
+	}
+	if s.Finally != nil {
+		p.write(" finally ")
+		p.block(s.Finally)
+	}
+}
+
+func (p *printer) trait(t *ast.Trait) {
+	p.write("trait ", t.Name.Value, " {")
+	p.depth++
+	first := true
+	for _, m := range t.Methods {
+		p.item(ast.StartToken(m).Line, &first)
+		fn := &ast.FunctionLiteral{Parameters: m.Parameters}
+		// Defaults may be written like class methods; keep that spelling
+		if m.Token.Type == token.LET {
+			p.write("let ", m.Name.Value, " = fn")
+		} else {
+			p.write(m.Name.Value)
+		}
+		if m.Body == nil {
+			p.write("(", parameters(fn), ")")
+			continue
+		}
+		p.signature(fn)
+		p.block(m.Body)
+	}
+	p.depth--
+	if !first {
+		p.line(false)
+	}
+	p.write("}")
+}
+
+// precedence is how tightly an expression binds as an operand; operands
+// that bind more loosely than their position requires are parenthesized
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PipeExpression:
+		return parser.PIPE
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression, *ast.MethodCall, *ast.PropertyAccess,
+		*ast.IndexExpression, *ast.TryExpression:
+		return parser.CALL
+	case *ast.FunctionLiteral:
+		// An arrow function's expression body extends as far as it can
+		if e.Token.Type == token.ARROW {
+			return parser.LOWEST
+		}
+	case *ast.ErrorStatement:
+		return parser.LOWEST
+	}
+	return parser.CALL + 1
+}
+
+// expr prints e, in parentheses if it binds more loosely than min
+func (p *printer) expr(e ast.Expression, min int) {
+	if e == nil {
+		return
+	}
+	if precedence(e) < min {
+		p.write("(")
+		p.expr(e, parser.LOWEST)
+		p.write(")")
+		return
+	}
+
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+	case *ast.FloatLiteral:
+		p.write(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(quote(e.Value))
+	case *ast.Boolean:
+		p.write(strconv.FormatBool(e.Value))
+	case *ast.Null:
+		p.write("null")
+	case *ast.Self:
+		p.write("self")
+	case *ast.Super:
+		p.write("super")
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expr(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := parser.Precedence(e.Token.Type)
+		p.expr(e.Left, prec)
+		p.write(" ", e.Operator, " ")
+		p.expr(e.Right, prec+1)
+	case *ast.PipeExpression:
+		p.expr(e.Left, parser.PIPE+1)
+		p.write(" |> ")
+		p.expr(e.Right, parser.LOWEST)
+	case *ast.TryExpression:
+		p.expr(e.Value, parser.CALL)
+		p.write("?")
+	case *ast.CallExpression:
+		p.expr(e.Function, parser.CALL)
+		p.arguments(e.Arguments)
+	case *ast.MethodCall:
+		p.expr(e.Object, parser.CALL)
+		p.write(".", e.Method.Value)
+		p.arguments(e.Arguments)
+	case *ast.PropertyAccess:
+		p.expr(e.Object, parser.CALL)
+		p.write(".", e.Property.Value)
+	case *ast.IndexExpression:
+		p.expr(e.Left, parser.CALL)
+		p.write("[")
+		p.expr(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.IfExpression:
+		p.write("if ")
+		p.expr(e.Condition, parser.LOWEST)
+		p.write(" ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.function(e)
+	case *ast.ArrayLiteral:
+		p.list("[", "]", e.Elements, e.Token.Line)
+	case *ast.Tuple:
+		p.list("(", ")", e.Elements, e.Token.Line)
+	case *ast.HashLiteral:
+		p.hash(e)
+	case *ast.ModuleLoad:
+		p.moduleLoad(e)
+	case *ast.ErrorStatement:
+		p.write("error ")
+		p.expr(e.Value, parser.LOWEST)
+	default:
+		p.write(e.String())
+	}
+}
+
+func (p *printer) function(fn *ast.FunctionLiteral) {
+	if fn.Token.Type != token.ARROW {
+		p.write("fn")
+		p.signature(fn)
+		p.block(fn.Body)
+		return
+	}
+
+	if len(fn.Parameters) == 1 {
+		p.write(fn.Parameters[0].Value)
+	} else {
+		p.write("(", parameters(fn), ")")
+	}
+	p.write(" => ")
+	// An expression body is parsed into an implicit return in a block that
+	// shares the arrow's token
+	if fn.Body.Token.Type == token.ARROW && len(fn.Body.Statements) == 1 {
+		if ret, ok := fn.Body.Statements[0].(*ast.ReturnStatement); ok {
+			p.expr(ret.Value, parser.LOWEST)
+			return
+		}
+	}
+	p.block(fn.Body)
+}
+
+func (p *printer) arguments(args []ast.Expression) {
+	p.write("(")
+	for i, arg := range args {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(arg, parser.LOWEST)
+	}
+	p.write(")")
+}
+
+// list prints array or tuple elements on one line, or one per line when
+// the first element was written on a line after the opening bracket
+func (p *printer) list(open, close string, elements []ast.Expression, line int) {
+	p.write(open)
+	multiline := len(elements) > 0 && ast.StartToken(elements[0]).Line > line
+	if multiline {
+		p.depth++
+	}
+	first := true
+	for i, el := range elements {
+		if multiline {
+			p.item(ast.StartToken(el).Line, &first)
+		} else if i > 0 {
+			p.write(", ")
+		}
+		p.expr(el, parser.LOWEST)
+		if multiline && i < len(elements)-1 {
+			p.write(",")
+		}
+	}
+	if multiline {
+		p.depth--
+		p.line(false)
+	}
+	p.write(close)
+}
+
+// hash prints pairs in source order, on one line or, like lists, one per
+// line when the first key was written on a line after the brace
+func (p *printer) hash(h *ast.HashLiteral) {
+	keys := h.Keys
+	if len(keys) != len(h.Pairs) {
+		// Built without the parser; the order is unknown
+		keys = keys[:0:0]
+		for key := range h.Pairs {
+			keys = append(keys, key)
+		}
+	}
+
+	p.write("{")
+	multiline := len(keys) > 0 && ast.StartToken(keys[0]).Line > h.Token.Line
+	if multiline {
+		p.depth++
+	}
+	first := true
+	for i, key := range keys {
+		if multiline {
+			p.item(ast.StartToken(key).Line, &first)
+		} else if i > 0 {
+			p.write(", ")
+		}
+		p.expr(key, parser.LOWEST)
+		p.write(": ")
+		p.expr(h.Pairs[key], parser.LOWEST)
+		if multiline && i < len(keys)-1 {
+			p.write(",")
+		}
+	}
+	if multiline {
+		p.depth--
+		p.line(false)
+	}
+	p.write("}")
+}
+
+// quote writes a string literal using the escapes the lexer understands.
+// Backslashes that don't start an escape, as in "\d+", are left alone.
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	runes := []rune(s)
+	for i, r := range runes {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case 0:
+			out.WriteString(`\0`)
+		case '\\':
+			if i+1 == len(runes) || strings.ContainsRune(`ntr\"'0`, runes[i+1]) {
+				out.WriteString(`\\`)
+			} else {
+				out.WriteRune(r)
+			}
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
@@ -3,6 +3,7 @@ package lexer
 import (
 	"fmt"
 	"lynx/pkg/token"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -35,6 +36,15 @@ type Lexer struct {
 	line         int
 	column       int
 	errors       []LexError
+	unterminated bool // input ended inside a string or block comment
+
+	comments   []token.Comment
+	blankLines []int
+	tokenLine  int // line of the last token returned
+
+	// position of the character before ch, the last of a token just read
+	prevLine   int
+	prevColumn int
 }
 
 // New creates a lexer from source code string
@@ -46,6 +56,12 @@ func New(input string) *Lexer {
 		errors: []LexError{},
 	}
 	l.readChar()
+	// A leading #! line lets scripts be run directly; it is skipped like a
+	// comment so line numbers stay correct
+	if l.ch == '#' && l.peekChar() == '!' {
+		l.skipLineComment()
+		l.recordComment(0, 1, 1)
+	}
 	return l
 }
 
@@ -53,6 +69,23 @@ func (l *Lexer) Errors() []LexError {
 	return l.errors
 }
 
+// Comments returns the comments skipped so far, in source order
+func (l *Lexer) Comments() []token.Comment {
+	return l.comments
+}
+
+// BlankLines returns the numbers of the lines seen so far that hold only
+// whitespace
+func (l *Lexer) BlankLines() []int {
+	return l.blankLines
+}
+
+// Unterminated reports whether the input ended inside a string literal or
+// block comment, so more input could complete it
+func (l *Lexer) Unterminated() bool {
+	return l.unterminated
+}
+
 func (l *Lexer) ErrorStrings() []string {
 	var strs []string
 	for _, err := range l.errors {
@@ -74,6 +107,7 @@ func (l *Lexer) addError(message, context string) {
 }
 
 func (l *Lexer) readChar() {
+	l.prevLine, l.prevColumn = l.line, l.column
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 		l.position = l.readPosition
@@ -101,6 +135,16 @@ func (l *Lexer) readChar() {
 }
 
 func (l *Lexer) NextToken() token.Token {
+	tok := l.nextToken()
+	tok.EndLine, tok.EndColumn = l.prevLine, l.prevColumn+1
+	if tok.Type == token.EOF {
+		tok.EndLine, tok.EndColumn = tok.Line, tok.Column
+	}
+	l.tokenLine = tok.Line
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
@@ -119,6 +163,15 @@ func (l *Lexer) NextToken() token.Token {
 				Line:    currentLine,
 				Column:  currentColumn,
 			}
+		} else if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{
+				Type:    token.ARROW,
+				Literal: string(ch) + string(l.ch),
+				Line:    currentLine,
+				Column:  currentColumn,
+			}
 		} else {
 			tok = l.newToken(token.ASSIGN, l.ch)
 		}
@@ -159,7 +212,18 @@ func (l *Lexer) NextToken() token.Token {
 			tok = l.newToken(token.PLUS, l.ch)
 		}
 	case '-':
-		tok = l.newToken(token.MINUS, l.ch)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{
+				Type:    token.THIN_ARROW,
+				Literal: string(ch) + string(l.ch),
+				Line:    currentLine,
+				Column:  currentColumn,
+			}
+		} else {
+			tok = l.newToken(token.MINUS, l.ch)
+		}
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -181,10 +245,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok = l.newToken(token.POWER, l.ch)
 	case '/':
 		if l.peekChar() == '/' {
+			start := l.position
 			l.skipLineComment()
+			l.recordComment(start, currentLine, currentColumn)
 			return l.NextToken()
 		} else if l.peekChar() == '*' {
+			start := l.position
 			l.skipBlockComment()
+			l.recordComment(start, currentLine, currentColumn)
 			return l.NextToken()
 		} else {
 			tok = l.newToken(token.SLASH, l.ch)
@@ -225,6 +293,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = l.newToken(token.RBRACKET, l.ch)
 	case ':':
 		tok = l.newToken(token.COLON, l.ch)
+	case '?':
+		tok = l.newToken(token.TRY, l.ch)
 	case '.':
 		if l.isDigit(l.peekChar()) {
 			tok.Type = token.FLOAT
@@ -362,12 +432,31 @@ func (l *Lexer) readFloatFromInt(intPart string) string {
 	return intPart
 }
 
+// skipWhitespace also records blank lines: a newline that ends a line
+// holding nothing but whitespace, including at the start of the input
 func (l *Lexer) skipWhitespace() {
+	lineStart := l.position == 0
 	for unicode.IsSpace(l.ch) {
+		if l.ch == '\n' {
+			// readChar has already moved l.line past this newline
+			if lineStart {
+				l.blankLines = append(l.blankLines, l.line-1)
+			}
+			lineStart = true
+		}
 		l.readChar()
 	}
 }
 
+func (l *Lexer) recordComment(start, line, column int) {
+	l.comments = append(l.comments, token.Comment{
+		Text:     strings.TrimRight(l.input[start:l.position], " \t\r\n"),
+		Line:     line,
+		Column:   column,
+		Trailing: l.tokenLine == line,
+	})
+}
+
 func (l *Lexer) skipLineComment() {
 	l.readChar()
 	l.readChar()
@@ -383,13 +472,14 @@ func (l *Lexer) skipBlockComment() {
 
 	for {
 		if l.ch == 0 {
+			l.unterminated = true
 			l.addError(
 				"Unterminated block comment",
 				"reached end of file while parsing block comment",
 			)
 			break
 		}
-		if l.peekChar() == '/' {
+		if l.ch == '*' && l.peekChar() == '/' {
 			l.readChar()
 			l.readChar()
 			break
@@ -414,6 +504,7 @@ func (l *Lexer) readString() string {
 		if l.ch == '\\' {
 			l.readChar()
 			if l.ch == 0 {
+				l.unterminated = true
 				l.addError(
 					"Unterminated string literal",
 					"unexpected end of file in string",
@@ -427,6 +518,7 @@ func (l *Lexer) readString() string {
 		}
 	}
 	if l.ch == 0 {
+		l.unterminated = true
 		l.addError(
 			"Unterminated string literal",
 			"reached end of file without closing quote",
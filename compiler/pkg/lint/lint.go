@@ -0,0 +1,207 @@
+// Package lint reports likely mistakes in programs that parse and may even
+// run: unused and shadowed variables, unreachable code, duplicate keys and
+// the like. Each finding comes from a rule with an ID and a severity; rules
+// can be reconfigured per project and silenced per line with a comment:
+//
+//	let x = 1 // lint:ignore unused-variable
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Off Severity = iota
+	Warning
+	Error
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	}
+	return "off"
+}
+
+// ParseSeverity reads a severity as written in configuration
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "off":
+		return Off, nil
+	case "warning":
+		return Warning, nil
+	case "error":
+		return Error, nil
+	}
+	return Off, fmt.Errorf("unknown severity %q (want off, warning or error)", s)
+}
+
+// Rule is a kind of problem the linter looks for
+type Rule struct {
+	ID       string
+	Severity Severity // used unless configured otherwise
+	Summary  string
+}
+
+var (
+	UnusedVariable      = &Rule{"unused-variable", Warning, "a local variable is declared but never read"}
+	ShadowedName        = &Rule{"shadowed-name", Warning, "a declaration hides a variable of an enclosing function"}
+	UndefinedAssignment = &Rule{"undefined-assignment", Error, "a variable is assigned without ever being declared"}
+	UnreachableCode     = &Rule{"unreachable-code", Warning, "statements follow return, break, continue or error"}
+	SelfOutsideMethod   = &Rule{"self-outside-method", Error, "self or super is used outside a class or trait"}
+	DuplicateKey        = &Rule{"duplicate-key", Warning, "a hash literal repeats a key"}
+	DuplicateCase       = &Rule{"duplicate-case", Warning, "a switch repeats a case value"}
+	WrongArity          = &Rule{"wrong-arity", Error, "a known function is called with the wrong number of arguments"}
+)
+
+// Rules lists every rule in the order they are documented
+var Rules = []*Rule{
+	UnusedVariable,
+	ShadowedName,
+	UndefinedAssignment,
+	UnreachableCode,
+	SelfOutsideMethod,
+	DuplicateKey,
+	DuplicateCase,
+	WrongArity,
+}
+
+// Lookup finds a rule by ID
+func Lookup(id string) *Rule {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Config overrides the severity of rules by ID; Off disables a rule
+type Config map[string]Severity
+
+// ParseConfig reads rule settings such as the [lint] table of lynx.toml,
+// which maps rule IDs to "off", "warning" or "error"
+func ParseConfig(settings map[string]string) (Config, error) {
+	config := Config{}
+	for id, value := range settings {
+		if Lookup(id) == nil {
+			return nil, fmt.Errorf("unknown lint rule %q", id)
+		}
+		severity, err := ParseSeverity(value)
+		if err != nil {
+			return nil, fmt.Errorf("lint rule %s: %v", id, err)
+		}
+		config[id] = severity
+	}
+	return config, nil
+}
+
+func (c Config) severity(rule *Rule) Severity {
+	if severity, ok := c[rule.ID]; ok {
+		return severity
+	}
+	return rule.Severity
+}
+
+// Diagnostic is a problem found at a source position
+type Diagnostic struct {
+	File     string
+	Line     int
+	Column   int
+	Rule     *Rule
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	pos := fmt.Sprintf("%d:%d", d.Line, d.Column)
+	if d.File != "" {
+		pos = d.File + ":" + pos
+	}
+	return fmt.Sprintf("%s: %s: %s (%s)", pos, d.Severity, d.Message, d.Rule.ID)
+}
+
+// Source parses and lints a program; file names it in diagnostics. Source
+// that does not parse is returned as an error listing the parse errors.
+func Source(src, file string, config Config) ([]Diagnostic, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.ErrorStrings(), "\n"))
+	}
+	return Program(program, file, config), nil
+}
+
+// Program lints a parsed program, which needs its comments for suppressions
+func Program(program *ast.Program, file string, config Config) []Diagnostic {
+	l := &linter{file: file, config: config}
+	l.program(program)
+
+	ignored := suppressions(program)
+	diags := []Diagnostic{}
+	for _, d := range l.diags {
+		if ids, ok := ignored[d.Line]; ok && (ids == nil || ids[d.Rule.ID]) {
+			continue
+		}
+		diags = append(diags, d)
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+	return diags
+}
+
+const ignoreDirective = "lint:ignore"
+
+// suppressions maps lines to the rule IDs silenced on them, nil meaning
+// every rule. A `lint:ignore [ids]` comment applies to its own line when it
+// follows code and to the next line when it stands alone.
+func suppressions(program *ast.Program) map[int]map[string]bool {
+	ignored := map[int]map[string]bool{}
+	for _, c := range program.Comments {
+		text := strings.TrimPrefix(c.Text, "//")
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, ignoreDirective) {
+			continue
+		}
+
+		line := c.Line
+		if !c.Trailing {
+			line += strings.Count(c.Text, "\n") + 1
+		}
+		fields := strings.FieldsFunc(text[len(ignoreDirective):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) == 0 {
+			ignored[line] = nil
+			continue
+		}
+		ids, ok := ignored[line]
+		if ok && ids == nil {
+			continue
+		}
+		if ids == nil {
+			ids = map[string]bool{}
+			ignored[line] = ids
+		}
+		for _, id := range fields {
+			ids[id] = true
+		}
+	}
+	return ignored
+}
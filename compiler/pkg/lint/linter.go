@@ -0,0 +1,456 @@
+package lint
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/token"
+	"lynx/pkg/types"
+	"strconv"
+	"strings"
+)
+
+type scopeKind int
+
+const (
+	programScope scopeKind = iota
+	functionScope
+	classScope
+	// loops, catch handlers and binding cases get an environment of their
+	// own at runtime; other blocks share the enclosing one
+	blockScope
+)
+
+type binding struct {
+	name     string
+	token    token.Token
+	local    bool // reported when never read
+	used     bool
+	assigned bool
+	fn       *ast.FunctionLiteral // the function bound by a declaration
+}
+
+type scope struct {
+	kind  scopeKind
+	names map[string]*binding
+	order []*binding
+	outer *scope
+}
+
+func newScope(kind scopeKind, outer *scope) *scope {
+	return &scope{kind: kind, names: make(map[string]*binding), outer: outer}
+}
+
+func (s *scope) lookup(name string) *binding {
+	for sc := s; sc != nil; sc = sc.outer {
+		if b, ok := sc.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// call is a call to a declared function, checked once every assignment in
+// the program is known
+type call struct {
+	node    *ast.CallExpression
+	binding *binding
+}
+
+type linter struct {
+	file    string
+	config  Config
+	diags   []Diagnostic
+	calls   []call
+	methods int // depth of class and trait bodies, where self is bound
+}
+
+func (l *linter) report(rule *Rule, tok token.Token, format string, a ...any) {
+	severity := l.config.severity(rule)
+	if severity == Off {
+		return
+	}
+	l.diags = append(l.diags, Diagnostic{
+		File:     l.file,
+		Line:     tok.Line,
+		Column:   tok.Column,
+		Rule:     rule,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (l *linter) program(program *ast.Program) {
+	l.body(program.Statements, newScope(programScope, nil))
+
+	for _, c := range l.calls {
+		fn := c.binding.fn
+		if c.binding.assigned || variadic(fn) {
+			continue
+		}
+		l.checkArity(c.node, c.binding.name, len(fn.Parameters))
+	}
+}
+
+func variadic(fn *ast.FunctionLiteral) bool {
+	for _, param := range fn.Parameters {
+		if param.Token.Type == token.SPREAD {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *linter) checkArity(node *ast.CallExpression, name string, want int) {
+	if got := len(node.Arguments); got != want {
+		l.report(WrongArity, ast.StartToken(node.Function), "%s takes %s, got %d", name, plural(want, "argument"), got)
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// body checks the statements of a scope. Bindings are declared before any
+// statement is checked, as functions may refer to names declared after
+// them, and unused locals are reported at the end.
+func (l *linter) body(stmts []ast.Statement, s *scope) {
+	l.hoist(stmts, s)
+	l.statements(stmts, s)
+	for _, b := range s.order {
+		if b.local && !b.used && !strings.HasPrefix(b.name, "_") {
+			l.report(UnusedVariable, b.token, "%s is declared but never used", b.name)
+		}
+	}
+}
+
+func (l *linter) declare(s *scope, ident *ast.Identifier, local bool) *binding {
+	if b, ok := s.names[ident.Value]; ok {
+		// Declaring a name again replaces what it is bound to
+		b.assigned = true
+		return b
+	}
+	if s.kind != programScope && s.kind != classScope && !strings.HasPrefix(ident.Value, "_") {
+		if outer := shadowed(s.outer, ident.Value); outer != nil {
+			l.report(ShadowedName, ident.Token, "%s shadows the declaration on line %d", ident.Value, outer.token.Line)
+		}
+	}
+	b := &binding{name: ident.Value, token: ident.Token, local: local}
+	s.names[ident.Value] = b
+	s.order = append(s.order, b)
+	return b
+}
+
+// shadowed finds a variable named name in an enclosing function. Reusing
+// a top-level name for a parameter is common and harmless, and class
+// members are reached through self, so neither counts.
+func shadowed(s *scope, name string) *binding {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sc.kind == classScope || sc.kind == programScope {
+			continue
+		}
+		if b, ok := sc.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// hoist declares the bindings that stmts make in s, including those in
+// nested blocks that share s at runtime
+func (l *linter) hoist(stmts []ast.Statement, s *scope) {
+	local := s.kind == functionScope || s.kind == blockScope
+	ast.Hoist(stmts, s.kind == classScope, func(stmt ast.Statement) {
+		switch n := stmt.(type) {
+		case *ast.VarStatement:
+			b := l.declare(s, n.Name, local)
+			if fn, ok := n.Value.(*ast.FunctionLiteral); ok && s.kind != classScope && !b.assigned {
+				b.fn = fn
+			}
+		case *ast.ModuleLoad:
+			if n.Members == nil {
+				l.declare(s, &ast.Identifier{Token: n.Token, Value: n.Binding()}, false)
+			}
+			for _, m := range n.Members {
+				l.declare(s, m, false)
+			}
+		case *ast.Assignment:
+			l.declare(s, n.Name.(*ast.Identifier), false)
+		case *ast.Class:
+			l.declare(s, n.Name, false)
+		case *ast.Trait:
+			l.declare(s, n.Name, false)
+		}
+	})
+}
+
+// statements checks a statement list whose bindings are already declared
+func (l *linter) statements(stmts []ast.Statement, s *scope) {
+	for i, stmt := range stmts {
+		l.statement(stmt, s)
+		if terminates(stmt) && i+1 < len(stmts) {
+			l.report(UnreachableCode, ast.StartToken(stmts[i+1]), "unreachable code after %s", stmt.TokenLiteral())
+			for _, rest := range stmts[i+1:] {
+				l.statement(rest, s)
+			}
+			return
+		}
+	}
+}
+
+func terminates(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ReturnStatement, *ast.Break, *ast.Continue, *ast.ErrorStatement:
+		return true
+	}
+	return false
+}
+
+func (l *linter) block(block *ast.BlockStatement, s *scope) {
+	if block != nil {
+		l.statements(block.Statements, s)
+	}
+}
+
+func (l *linter) statement(stmt ast.Statement, s *scope) {
+	switch n := stmt.(type) {
+	case *ast.ExpressionStatement:
+		l.expr(n.Expression, s)
+	case *ast.VarStatement:
+		l.expr(n.Value, s)
+	case *ast.Assignment:
+		l.assignment(n, s)
+	case *ast.ReturnStatement:
+		l.expr(n.Value, s)
+	case *ast.ErrorStatement:
+		l.expr(n.Value, s)
+	case *ast.ForRange:
+		l.expr(n.Collection, s)
+		ls := newScope(blockScope, s)
+		l.declare(ls, n.Variable, true)
+		if n.Index != nil {
+			l.declare(ls, n.Index, true)
+		}
+		l.body(n.Body.Statements, ls)
+	case *ast.While:
+		l.expr(n.Condition, s)
+		l.block(n.Body, s)
+	case *ast.SwitchStatement:
+		l.switchStatement(n, s)
+	case *ast.DeferStatement:
+		l.expr(n.Value, s)
+		l.block(n.Body, s)
+	case *ast.CatchStatement:
+		l.block(n.Body, s)
+		for _, h := range n.Handlers {
+			for _, t := range h.Types {
+				l.expr(t, s)
+			}
+			hs := newScope(blockScope, s)
+			l.declare(hs, h.ErrorVar, false)
+			l.body(h.Body.Statements, hs)
+		}
+		l.block(n.Finally, s)
+	case *ast.Class:
+		if n.SuperClass != nil {
+			l.expr(n.SuperClass, s)
+		}
+		for _, t := range n.Traits {
+			l.expr(t, s)
+		}
+		l.methods++
+		if n.Body != nil {
+			l.body(n.Body.Statements, newScope(classScope, s))
+		}
+		l.methods--
+	case *ast.Accessor:
+		l.expr(n.Value, s)
+	case *ast.ExportStatement:
+		l.statement(n.Statement, s)
+	case *ast.PrivateStatement:
+		l.statement(n.Statement, s)
+	case *ast.StaticStatement:
+		l.statement(n.Statement, s)
+	case *ast.Trait:
+		l.methods++
+		for _, m := range n.Methods {
+			if m.Body != nil {
+				l.function(m.Parameters, m.Body, s)
+			}
+		}
+		l.methods--
+	}
+}
+
+func (l *linter) assignment(n *ast.Assignment, s *scope) {
+	l.expr(n.Value, s)
+	ident, ok := n.Name.(*ast.Identifier)
+	if !ok {
+		l.expr(n.Name, s)
+		return
+	}
+	b := s.lookup(ident.Value)
+	if b == nil {
+		l.report(UndefinedAssignment, ident.Token, "assignment to undeclared variable %s; declare it with let", ident.Value)
+		return
+	}
+	b.assigned = true
+}
+
+func (l *linter) switchStatement(n *ast.SwitchStatement, s *scope) {
+	l.expr(n.Expression, s)
+	seen := map[string]bool{}
+	for _, c := range n.Cases {
+		if ident, binds := c.Value.(*ast.Identifier); binds {
+			// A bare name matches anything and binds it for the case
+			cs := newScope(blockScope, s)
+			l.declare(cs, ident, false)
+			l.expr(c.Guard, cs)
+			if c.Body != nil {
+				l.body(c.Body.Statements, cs)
+			}
+			continue
+		}
+
+		if key, ok := literalKey(c.Value); ok && c.Guard == nil {
+			if seen[key] {
+				l.report(DuplicateCase, c.Token, "duplicate case %s", describe(c.Value))
+			}
+			seen[key] = true
+		}
+		l.expr(c.Value, s)
+		l.expr(c.Guard, s)
+		l.block(c.Body, s)
+	}
+}
+
+func (l *linter) function(params []*ast.Identifier, body *ast.BlockStatement, s *scope) {
+	fs := newScope(functionScope, s)
+	for _, param := range params {
+		if param.Token.Type != token.SPREAD {
+			l.declare(fs, param, false)
+		}
+	}
+	if body != nil {
+		l.body(body.Statements, fs)
+	}
+}
+
+func (l *linter) expr(e ast.Expression, s *scope) {
+	switch n := e.(type) {
+	case nil:
+	case *ast.Identifier:
+		if b := s.lookup(n.Value); b != nil {
+			b.used = true
+		}
+	case *ast.Self:
+		if l.methods == 0 {
+			l.report(SelfOutsideMethod, n.Token, "self used outside a class or trait method")
+		}
+	case *ast.Super:
+		if l.methods == 0 {
+			l.report(SelfOutsideMethod, n.Token, "super used outside a class method")
+		}
+	case *ast.FunctionLiteral:
+		l.function(n.Parameters, n.Body, s)
+	case *ast.CallExpression:
+		l.expr(n.Function, s)
+		for _, arg := range n.Arguments {
+			l.expr(arg, s)
+		}
+		l.callee(n, s)
+	case *ast.MethodCall:
+		l.expr(n.Object, s)
+		for _, arg := range n.Arguments {
+			l.expr(arg, s)
+		}
+	case *ast.PropertyAccess:
+		l.expr(n.Object, s)
+	case *ast.IndexExpression:
+		l.expr(n.Left, s)
+		l.expr(n.Index, s)
+	case *ast.PrefixExpression:
+		l.expr(n.Right, s)
+	case *ast.InfixExpression:
+		l.expr(n.Left, s)
+		l.expr(n.Right, s)
+	case *ast.PipeExpression:
+		l.expr(n.Left, s)
+		l.expr(n.Right, s)
+	case *ast.TryExpression:
+		l.expr(n.Value, s)
+	case *ast.IfExpression:
+		l.expr(n.Condition, s)
+		l.block(n.Consequence, s)
+		l.block(n.Alternative, s)
+	case *ast.ArrayLiteral:
+		for _, el := range n.Elements {
+			l.expr(el, s)
+		}
+	case *ast.Tuple:
+		for _, el := range n.Elements {
+			l.expr(el, s)
+		}
+	case *ast.HashLiteral:
+		l.hash(n, s)
+	case *ast.ErrorStatement:
+		l.expr(n.Value, s)
+	}
+}
+
+// callee records calls to declared functions and checks calls to builtins
+// with a fixed number of parameters
+func (l *linter) callee(n *ast.CallExpression, s *scope) {
+	ident, ok := n.Function.(*ast.Identifier)
+	if !ok {
+		return
+	}
+	if b := s.lookup(ident.Value); b != nil {
+		if b.fn != nil {
+			l.calls = append(l.calls, call{n, b})
+		}
+		return
+	}
+	if want, ok := types.BuiltinArity(ident.Value); ok {
+		l.checkArity(n, ident.Value, want)
+	}
+}
+
+func (l *linter) hash(n *ast.HashLiteral, s *scope) {
+	seen := map[string]bool{}
+	for _, key := range n.Keys {
+		if k, ok := literalKey(key); ok {
+			if seen[k] {
+				l.report(DuplicateKey, ast.StartToken(key), "duplicate key %s in hash literal", describe(key))
+			}
+			seen[k] = true
+		}
+		l.expr(key, s)
+		l.expr(n.Pairs[key], s)
+	}
+}
+
+// literalKey identifies constant values, so that equal keys and cases can
+// be found; the type is part of the key as 1 and "1" differ
+func literalKey(e ast.Expression) (string, bool) {
+	switch n := e.(type) {
+	case *ast.StringLiteral:
+		return "str:" + n.Value, true
+	case *ast.IntegerLiteral:
+		return "int:" + strconv.FormatInt(n.Value, 10), true
+	case *ast.FloatLiteral:
+		return "float:" + strconv.FormatFloat(n.Value, 'g', -1, 64), true
+	case *ast.Boolean:
+		return "bool:" + strconv.FormatBool(n.Value), true
+	}
+	return "", false
+}
+
+func describe(e ast.Expression) string {
+	if str, ok := e.(*ast.StringLiteral); ok {
+		return strconv.Quote(str.Value)
+	}
+	return e.String()
+}
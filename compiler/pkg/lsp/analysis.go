@@ -0,0 +1,529 @@
+package lsp
+
+import (
+	"lynx/pkg/ast"
+	"lynx/pkg/token"
+	"math"
+	"unicode/utf8"
+)
+
+// pos is a source position as the lexer reports it: 1-based lines and
+// 1-based columns counted in runes
+type pos struct {
+	line, col int
+}
+
+func tokenPos(tok token.Token) pos {
+	return pos{tok.Line, tok.Column}
+}
+
+// tokenEnd is the position just after an identifier or keyword token
+func tokenEnd(tok token.Token) pos {
+	return pos{tok.Line, tok.Column + utf8.RuneCountInString(tok.Literal)}
+}
+
+func (p pos) before(q pos) bool {
+	return p.line < q.line || p.line == q.line && p.col < q.col
+}
+
+type declKind int
+
+const (
+	kindVariable declKind = iota
+	kindConstant
+	kindFunction
+	kindParameter
+	kindClass
+	kindTrait
+	kindModule
+	kindMember // a class member or a property assigned to a variable
+)
+
+// decl is a declared name and every place it occurs in the document
+type decl struct {
+	name   string
+	kind   declKind
+	tok    token.Token // the name where it is declared
+	node   ast.Node    // the declaring statement, for symbol ranges
+	fn     *ast.FunctionLiteral
+	typ    *ast.TypeAnnotation
+	value  ast.Expression
+	class  *ast.Class
+	params []*ast.Identifier // trait method parameters
+
+	module   *ast.ModuleLoad // the import that binds the name
+	imported string          // member of the module the import binds, if any
+	// synthetic names do not appear in the source as written, like the
+	// binding of @"./util", and cannot be renamed
+	synthetic bool
+
+	refs    []token.Token // occurrences, the declaration first
+	members []*decl       // class members, trait methods and assigned properties
+}
+
+type scope struct {
+	start, end pos
+	names      map[string]*decl
+	outer      *scope
+	children   []*scope
+	class      *decl // the class whose body this is
+}
+
+func newScope(outer *scope, start pos) *scope {
+	s := &scope{start: start, end: start, names: make(map[string]*decl), outer: outer}
+	if outer != nil {
+		outer.children = append(outer.children, s)
+	}
+	return s
+}
+
+func (s *scope) lookup(name string) *decl {
+	for sc := s; sc != nil; sc = sc.outer {
+		if d, ok := sc.names[name]; ok {
+			return d
+		}
+	}
+	return nil
+}
+
+// enclosingClass returns the class whose body contains s, if any
+func (s *scope) enclosingClass() *decl {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sc.class != nil {
+			return sc.class
+		}
+	}
+	return nil
+}
+
+// extend grows s and its outer scopes to include p
+func (s *scope) extend(p pos) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sc.end.before(p) {
+			sc.end = p
+		}
+	}
+}
+
+// at returns the innermost scope containing p
+func (s *scope) at(p pos) *scope {
+	for _, child := range s.children {
+		if !p.before(child.start) && !child.end.before(p) {
+			return child.at(p)
+		}
+	}
+	return s
+}
+
+// occurrence is an identifier in the source and the declaration it names,
+// if any
+type occurrence struct {
+	tok  token.Token
+	decl *decl
+}
+
+// memberRef is a property or method name accessed on a declared name, as
+// in math.sqrt, resolved lazily since the member may live in another file
+type memberRef struct {
+	tok    token.Token
+	object *decl
+}
+
+// analysis indexes the names in a parsed program
+type analysis struct {
+	root        *scope
+	top         []*decl
+	occurrences []occurrence
+	members     []memberRef
+	classes     map[*ast.Class]*decl
+}
+
+func analyze(program *ast.Program) *analysis {
+	a := &analysis{classes: make(map[*ast.Class]*decl)}
+	return a.run(program)
+}
+
+func (a *analysis) run(program *ast.Program) *analysis {
+	a.root = newScope(nil, pos{1, 1})
+	a.root.end = pos{math.MaxInt, math.MaxInt}
+	a.body(program.Statements, a.root)
+	return a
+}
+
+// occurrenceAt finds the identifier covering p
+func (a *analysis) occurrenceAt(p pos) (occurrence, bool) {
+	for _, o := range a.occurrences {
+		if covers(o.tok, p) {
+			return o, true
+		}
+	}
+	return occurrence{}, false
+}
+
+func (a *analysis) memberAt(p pos) (memberRef, bool) {
+	for _, m := range a.members {
+		if covers(m.tok, p) {
+			return m, true
+		}
+	}
+	return memberRef{}, false
+}
+
+func covers(tok token.Token, p pos) bool {
+	return !p.before(tokenPos(tok)) && !tokenEnd(tok).before(p)
+}
+
+func (a *analysis) declare(s *scope, ident *ast.Identifier, kind declKind, node ast.Node) *decl {
+	if d, ok := s.names[ident.Value]; ok {
+		a.occur(s, ident.Token, d)
+		return d
+	}
+	d := &decl{name: ident.Value, kind: kind, tok: ident.Token, node: node}
+	s.names[ident.Value] = d
+	if s == a.root {
+		a.top = append(a.top, d)
+	}
+	a.occur(s, ident.Token, d)
+	return d
+}
+
+func (a *analysis) occur(s *scope, tok token.Token, d *decl) {
+	d.refs = append(d.refs, tok)
+	a.occurrences = append(a.occurrences, occurrence{tok, d})
+	s.extend(tokenEnd(tok))
+}
+
+// reference records a use of a name; names that are not declared, such as
+// builtins, are recorded with no declaration
+func (a *analysis) reference(ident *ast.Identifier, s *scope) {
+	d := s.lookup(ident.Value)
+	if d == nil {
+		a.occurrences = append(a.occurrences, occurrence{ident.Token, nil})
+		s.extend(tokenEnd(ident.Token))
+		return
+	}
+	a.occur(s, ident.Token, d)
+}
+
+func (a *analysis) body(stmts []ast.Statement, s *scope) {
+	a.hoist(stmts, s)
+	for _, stmt := range stmts {
+		a.statement(stmt, s)
+	}
+}
+
+// hoist declares the names stmts bind in s before any use is resolved, as
+// the evaluator lets functions refer to names declared after them
+func (a *analysis) hoist(stmts []ast.Statement, s *scope) {
+	ast.Hoist(stmts, s.class != nil, func(stmt ast.Statement) {
+		switch n := stmt.(type) {
+		case *ast.VarStatement:
+			a.hoistVar(n, s)
+		case *ast.Assignment:
+			d := a.declare(s, n.Name.(*ast.Identifier), kindMember, n)
+			d.value = n.Value
+			d.fn, _ = n.Value.(*ast.FunctionLiteral)
+			s.class.members = append(s.class.members, d)
+		case *ast.ModuleLoad:
+			a.hoistImport(n, s)
+		case *ast.Class:
+			d := a.declare(s, n.Name, kindClass, n)
+			d.class = n
+			a.classes[n] = d
+		case *ast.Trait:
+			d := a.declare(s, n.Name, kindTrait, n)
+			for _, m := range n.Methods {
+				method := &decl{name: m.Name.Value, kind: kindMember, tok: m.Name.Token, node: m, params: m.Parameters}
+				method.refs = []token.Token{m.Name.Token}
+				d.members = append(d.members, method)
+			}
+		case *ast.Accessor:
+			if s.class != nil {
+				d := &decl{name: n.Name.Value, kind: kindMember, tok: n.Name.Token, node: n, value: n.Value}
+				d.fn, _ = n.Value.(*ast.FunctionLiteral)
+				d.refs = []token.Token{n.Name.Token}
+				s.class.members = append(s.class.members, d)
+			}
+		}
+	})
+}
+
+func (a *analysis) hoistVar(n *ast.VarStatement, s *scope) {
+	kind := kindVariable
+	if n.IsConst {
+		kind = kindConstant
+	}
+	fn, isFn := n.Value.(*ast.FunctionLiteral)
+	if isFn {
+		kind = kindFunction
+	}
+	if s.class != nil {
+		kind = kindMember
+	}
+
+	d := a.declare(s, n.Name, kind, n)
+	if len(d.refs) > 1 {
+		return
+	}
+	d.typ = n.Type
+	d.value = n.Value
+	if isFn {
+		d.fn = fn
+	}
+	if s.class != nil {
+		s.class.members = append(s.class.members, d)
+	}
+}
+
+func (a *analysis) hoistImport(n *ast.ModuleLoad, s *scope) {
+	if n.Members != nil {
+		for _, m := range n.Members {
+			d := a.declare(s, m, kindVariable, n)
+			d.module = n
+			d.imported = m.Value
+		}
+		return
+	}
+
+	ident := n.Alias
+	synthetic := false
+	if ident == nil {
+		if name, ok := n.Name.(*ast.Identifier); ok {
+			ident = name
+		} else {
+			tok := n.Token
+			tok.Literal = n.Binding()
+			ident = &ast.Identifier{Token: tok, Value: n.Binding()}
+			synthetic = true
+		}
+	}
+	d := a.declare(s, ident, kindModule, n)
+	d.module = n
+	d.synthetic = synthetic
+}
+
+func (a *analysis) statement(stmt ast.Statement, s *scope) {
+	switch n := stmt.(type) {
+	case *ast.ExpressionStatement:
+		a.expr(n.Expression, s)
+	case *ast.VarStatement:
+		a.expr(n.Value, s)
+	case *ast.Assignment:
+		a.assignment(n, s)
+	case *ast.ReturnStatement:
+		a.expr(n.Value, s)
+	case *ast.ErrorStatement:
+		a.expr(n.Value, s)
+	case *ast.ForRange:
+		a.expr(n.Collection, s)
+		ls := newScope(s, tokenPos(n.Token))
+		a.declare(ls, n.Variable, kindVariable, n)
+		if n.Index != nil {
+			a.declare(ls, n.Index, kindVariable, n)
+		}
+		a.scopeBody(n.Body, ls)
+	case *ast.While:
+		a.expr(n.Condition, s)
+		a.block(n.Body, s)
+	case *ast.SwitchStatement:
+		a.expr(n.Expression, s)
+		for _, c := range n.Cases {
+			if ident, binds := c.Value.(*ast.Identifier); binds {
+				cs := newScope(s, tokenPos(c.Token))
+				a.declare(cs, ident, kindVariable, c)
+				a.expr(c.Guard, cs)
+				a.scopeBody(c.Body, cs)
+				continue
+			}
+			a.expr(c.Value, s)
+			a.expr(c.Guard, s)
+			a.block(c.Body, s)
+		}
+	case *ast.DeferStatement:
+		a.expr(n.Value, s)
+		a.block(n.Body, s)
+	case *ast.CatchStatement:
+		a.block(n.Body, s)
+		for _, h := range n.Handlers {
+			for _, t := range h.Types {
+				a.reference(t, s)
+			}
+			hs := newScope(s, tokenPos(h.Token))
+			a.declare(hs, h.ErrorVar, kindVariable, h.ErrorVar)
+			a.scopeBody(h.Body, hs)
+		}
+		a.block(n.Finally, s)
+	case *ast.Class:
+		if n.SuperClass != nil {
+			a.reference(n.SuperClass, s)
+		}
+		for _, t := range n.Traits {
+			a.reference(t, s)
+		}
+		if n.Body != nil {
+			cs := newScope(s, tokenPos(n.Token))
+			cs.class = a.classes[n]
+			a.scopeBody(n.Body, cs)
+		}
+	case *ast.Accessor:
+		a.expr(n.Value, s)
+	case *ast.ExportStatement:
+		a.statement(n.Statement, s)
+	case *ast.PrivateStatement:
+		a.statement(n.Statement, s)
+	case *ast.StaticStatement:
+		a.statement(n.Statement, s)
+	case *ast.Trait:
+		for _, m := range n.Methods {
+			if m.Body != nil {
+				a.function(m.Token, m.Parameters, nil, m.Body, s)
+			}
+		}
+	}
+}
+
+func (a *analysis) assignment(n *ast.Assignment, s *scope) {
+	a.expr(n.Value, s)
+	switch target := n.Name.(type) {
+	case *ast.Identifier:
+		if s.class == nil {
+			a.reference(target, s)
+		}
+	case *ast.PropertyAccess:
+		a.expr(target.Object, s)
+		// x.name = value gives x a member, as modules are built in std
+		if ident, ok := target.Object.(*ast.Identifier); ok {
+			if d := s.lookup(ident.Value); d != nil && d.member(target.Property.Value) == nil {
+				member := &decl{name: target.Property.Value, kind: kindMember, tok: target.Property.Token, node: n, value: n.Value}
+				member.fn, _ = n.Value.(*ast.FunctionLiteral)
+				member.refs = []token.Token{target.Property.Token}
+				d.members = append(d.members, member)
+				return
+			}
+		}
+		a.property(target.Object, target.Property, s)
+	default:
+		a.expr(n.Name, s)
+	}
+}
+
+func (d *decl) member(name string) *decl {
+	for _, m := range d.members {
+		if m.name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// property records a member name accessed on a declared name or on self
+func (a *analysis) property(object ast.Expression, name *ast.Identifier, s *scope) {
+	var d *decl
+	switch o := object.(type) {
+	case *ast.Identifier:
+		d = s.lookup(o.Value)
+	case *ast.Self:
+		d = s.enclosingClass()
+	}
+	if d != nil {
+		a.members = append(a.members, memberRef{name.Token, d})
+	}
+	s.extend(tokenEnd(name.Token))
+}
+
+// scopeBody checks a block that has a scope of its own
+func (a *analysis) scopeBody(block *ast.BlockStatement, s *scope) {
+	if block == nil {
+		return
+	}
+	a.body(block.Statements, s)
+	if block.End.Line > 0 {
+		s.extend(tokenEnd(block.End))
+	}
+}
+
+func (a *analysis) block(block *ast.BlockStatement, s *scope) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		a.statement(stmt, s)
+	}
+	if block.End.Line > 0 {
+		s.extend(tokenEnd(block.End))
+	}
+}
+
+func (a *analysis) function(start token.Token, params []*ast.Identifier, types []*ast.TypeAnnotation, body *ast.BlockStatement, s *scope) {
+	fs := newScope(s, tokenPos(start))
+	for i, param := range params {
+		if param.Token.Type == token.SPREAD {
+			continue
+		}
+		d := a.declare(fs, param, kindParameter, param)
+		if i < len(types) {
+			d.typ = types[i]
+		}
+	}
+	a.scopeBody(body, fs)
+}
+
+func (a *analysis) expr(e ast.Expression, s *scope) {
+	switch n := e.(type) {
+	case nil:
+	case *ast.Identifier:
+		a.reference(n, s)
+	case *ast.FunctionLiteral:
+		start := n.Token
+		if n.Token.Type == token.ARROW && len(n.Parameters) > 0 {
+			start = n.Parameters[0].Token
+		}
+		a.function(start, n.Parameters, n.ParamTypes, n.Body, s)
+	case *ast.CallExpression:
+		a.expr(n.Function, s)
+		for _, arg := range n.Arguments {
+			a.expr(arg, s)
+		}
+	case *ast.MethodCall:
+		a.expr(n.Object, s)
+		a.property(n.Object, n.Method, s)
+		for _, arg := range n.Arguments {
+			a.expr(arg, s)
+		}
+	case *ast.PropertyAccess:
+		a.expr(n.Object, s)
+		a.property(n.Object, n.Property, s)
+	case *ast.IndexExpression:
+		a.expr(n.Left, s)
+		a.expr(n.Index, s)
+	case *ast.PrefixExpression:
+		a.expr(n.Right, s)
+	case *ast.InfixExpression:
+		a.expr(n.Left, s)
+		a.expr(n.Right, s)
+	case *ast.PipeExpression:
+		a.expr(n.Left, s)
+		a.expr(n.Right, s)
+	case *ast.TryExpression:
+		a.expr(n.Value, s)
+	case *ast.IfExpression:
+		a.expr(n.Condition, s)
+		a.block(n.Consequence, s)
+		a.block(n.Alternative, s)
+	case *ast.ArrayLiteral:
+		for _, el := range n.Elements {
+			a.expr(el, s)
+		}
+	case *ast.Tuple:
+		for _, el := range n.Elements {
+			a.expr(el, s)
+		}
+	case *ast.HashLiteral:
+		for _, key := range n.Keys {
+			a.expr(key, s)
+			a.expr(n.Pairs[key], s)
+		}
+	case *ast.ErrorStatement:
+		a.expr(n.Value, s)
+	}
+}
@@ -0,0 +1,535 @@
+package lsp
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/token"
+	"lynx/pkg/types"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// target is a declaration found at a position, and the module it was
+// declared in when that is not the document itself
+type target struct {
+	decl   *decl
+	module *module
+}
+
+// resolve finds what the name at p refers to. Imported names and members
+// of imported modules resolve into the module's file.
+func (s *Server) resolve(doc *document, p pos) (target, token.Token, bool) {
+	a := doc.analysis
+	if a == nil {
+		return target{}, token.Token{}, false
+	}
+	if o, ok := a.occurrenceAt(p); ok {
+		if o.decl != nil && o.decl.imported != "" {
+			if mod := s.loadModule(o.decl.module, doc.dir); mod != nil {
+				if d := mod.member(o.decl.imported); d != nil {
+					return target{d, mod}, o.tok, true
+				}
+			}
+		}
+		return target{decl: o.decl}, o.tok, true
+	}
+	if m, ok := a.memberAt(p); ok {
+		return s.resolveMember(doc, m), m.tok, true
+	}
+	return target{}, token.Token{}, false
+}
+
+func (s *Server) resolveMember(doc *document, m memberRef) target {
+	if m.object.kind == kindModule {
+		if mod := s.loadModule(m.object.module, doc.dir); mod != nil {
+			if d := mod.member(m.tok.Literal); d != nil {
+				return target{d, mod}
+			}
+		}
+		return target{}
+	}
+	return target{decl: doc.analysis.objectMember(m.object, m.tok.Literal)}
+}
+
+// objectMember finds a member of a declared name, following the
+// superclasses of classes
+func (a *analysis) objectMember(d *decl, name string) *decl {
+	for seen := map[*decl]bool{}; d != nil && !seen[d]; d = a.superclass(d) {
+		seen[d] = true
+		if m := d.member(name); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func (a *analysis) superclass(d *decl) *decl {
+	if d.class == nil || d.class.SuperClass == nil {
+		return nil
+	}
+	return a.root.names[d.class.SuperClass.Value]
+}
+
+func (s *Server) hover(doc *document, p pos) any {
+	t, tok, ok := s.resolve(doc, p)
+	if !ok {
+		return nil
+	}
+	var text, comment string
+	switch {
+	case t.decl != nil:
+		text = signature(t.decl)
+		comment = docComment(t.decl)
+	case tok.Type == token.IDENT && isBuiltin(tok.Literal):
+		text = builtinSignature(tok.Literal)
+	default:
+		return nil
+	}
+	value := "```lynx\n" + text + "\n```"
+	if comment != "" {
+		value += "\n\n" + comment
+	}
+	r := doc.span(tokenPos(tok), len([]rune(tok.Literal)))
+	return Hover{Contents: markupContent{Kind: "markdown", Value: value}, Range: &r}
+}
+
+// docComment is the /// comment written above a function or class
+func docComment(d *decl) string {
+	switch {
+	case d.fn != nil:
+		return d.fn.Doc
+	case d.class != nil:
+		return d.class.Doc
+	}
+	return ""
+}
+
+// signature describes a declaration the way it is written
+func signature(d *decl) string {
+	switch {
+	case d.fn != nil:
+		return "fn " + d.name + functionSignature(d.fn.Parameters, d.fn.ParamTypes, d.fn.ReturnType)
+	case isTraitMethod(d.node):
+		return "fn " + d.name + functionSignature(d.params, nil, nil)
+	}
+
+	switch d.kind {
+	case kindParameter:
+		return "(parameter) " + d.name + annotation(d.typ, nil)
+	case kindConstant:
+		return "const " + d.name + annotation(d.typ, d.value)
+	case kindClass:
+		out := "class " + d.name
+		if d.class.SuperClass != nil {
+			out += "(" + d.class.SuperClass.Value + ")"
+		}
+		if len(d.class.Traits) > 0 {
+			names := []string{}
+			for _, t := range d.class.Traits {
+				names = append(names, t.Value)
+			}
+			out += " impl " + strings.Join(names, ", ")
+		}
+		if init := d.member("init"); init != nil && init.fn != nil {
+			out += "\n" + d.name + functionSignature(init.fn.Parameters, init.fn.ParamTypes, nil)
+		}
+		return out
+	case kindTrait:
+		return "trait " + d.name
+	case kindModule:
+		out := "@" + d.module.Path()
+		if d.module.Alias != nil {
+			out += " as " + d.module.Alias.Value
+		}
+		return out
+	}
+	return "let " + d.name + annotation(d.typ, d.value)
+}
+
+func isTraitMethod(node ast.Node) bool {
+	_, ok := node.(*ast.TraitMethod)
+	return ok
+}
+
+func functionSignature(params []*ast.Identifier, paramTypes []*ast.TypeAnnotation, ret *ast.TypeAnnotation) string {
+	list := []string{}
+	for i, param := range params {
+		if param.Token.Type == token.SPREAD {
+			list = append(list, "...")
+			continue
+		}
+		var typ *ast.TypeAnnotation
+		if i < len(paramTypes) {
+			typ = paramTypes[i]
+		}
+		list = append(list, param.Value+annotation(typ, nil))
+	}
+	out := "(" + strings.Join(list, ", ") + ")"
+	if ret != nil {
+		out += " -> " + ret.String()
+	}
+	return out
+}
+
+// annotation is the declared type, or the type of a literal initializer
+func annotation(typ *ast.TypeAnnotation, value ast.Expression) string {
+	if typ != nil {
+		return ": " + typ.String()
+	}
+	if t := literalType(value); t != "" {
+		return ": " + t
+	}
+	return ""
+}
+
+func literalType(value ast.Expression) string {
+	switch value.(type) {
+	case *ast.IntegerLiteral:
+		return "int"
+	case *ast.FloatLiteral:
+		return "float"
+	case *ast.StringLiteral:
+		return "str"
+	case *ast.Boolean:
+		return "bool"
+	case *ast.Null:
+		return "null"
+	case *ast.ArrayLiteral:
+		return "array"
+	case *ast.HashLiteral:
+		return "hash"
+	}
+	return ""
+}
+
+func isBuiltin(name string) bool {
+	for _, b := range evaluator.BuiltinNames() {
+		if b == name {
+			return true
+		}
+	}
+	return false
+}
+
+func builtinSignature(name string) string {
+	if sig := types.BuiltinSignature(name); sig != nil {
+		return name + ": " + sig.String()
+	}
+	return name + ": builtin"
+}
+
+var (
+	memberPrefix = regexp.MustCompile(`(self|[A-Za-z_][A-Za-z0-9_]*)\.[A-Za-z0-9_]*$`)
+	stringPrefix = regexp.MustCompile(`"\.[A-Za-z0-9_]*$`)
+	arrayPrefix  = regexp.MustCompile(`\]\.[A-Za-z0-9_]*$`)
+)
+
+func (s *Server) completion(doc *document, p pos) []CompletionItem {
+	before := ""
+	if p.line-1 < len(doc.lines) {
+		runes := []rune(doc.lines[p.line-1])
+		before = string(runes[:min(p.col-1, len(runes))])
+	}
+
+	switch {
+	case stringPrefix.MatchString(before):
+		return methodItems(evaluator.StringMethods)
+	case arrayPrefix.MatchString(before):
+		return methodItems(evaluator.ArrayMethods)
+	}
+	if m := memberPrefix.FindStringSubmatch(before); m != nil {
+		return s.memberCompletion(doc, p, m[1])
+	}
+
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	if doc.analysis != nil {
+		for sc := doc.analysis.root.at(p); sc != nil; sc = sc.outer {
+			for _, d := range sortedDecls(sc.names) {
+				if !seen[d.name] {
+					seen[d.name] = true
+					items = append(items, declItem(d))
+				}
+			}
+		}
+	}
+	builtins := evaluator.BuiltinNames()
+	sort.Strings(builtins)
+	for _, name := range builtins {
+		if !seen[name] && !strings.HasPrefix(name, "_") {
+			items = append(items, CompletionItem{Label: name, Kind: CompletionFunction, Detail: builtinSignature(name)})
+		}
+	}
+	for _, word := range token.Keywords() {
+		items = append(items, CompletionItem{Label: word, Kind: CompletionKeyword})
+	}
+	return items
+}
+
+// memberCompletion lists what can follow object. in the document: members
+// of modules, classes and objects built by assignment, or the string and
+// array methods when the object's type is unknown
+func (s *Server) memberCompletion(doc *document, p pos, object string) []CompletionItem {
+	if doc.analysis == nil {
+		return []CompletionItem{}
+	}
+	sc := doc.analysis.root.at(p)
+	var d *decl
+	if object == "self" {
+		d = sc.enclosingClass()
+	} else {
+		d = sc.lookup(object)
+	}
+	if d == nil {
+		return []CompletionItem{}
+	}
+
+	var members []*decl
+	if d.kind == kindModule {
+		if mod := s.loadModule(d.module, doc.dir); mod != nil {
+			members = mod.members
+		}
+	} else {
+		seen := map[string]bool{}
+		for c := d; c != nil && !seen[c.name]; c = doc.analysis.superclass(c) {
+			seen[c.name] = true
+			members = append(members, c.members...)
+		}
+	}
+	if len(members) > 0 {
+		items := []CompletionItem{}
+		for _, m := range members {
+			items = append(items, declItem(m))
+		}
+		return items
+	}
+
+	switch typeName(d) {
+	case "str":
+		return methodItems(evaluator.StringMethods)
+	case "array":
+		return methodItems(evaluator.ArrayMethods)
+	case "":
+		return append(methodItems(evaluator.StringMethods), methodItems(evaluator.ArrayMethods)...)
+	}
+	return []CompletionItem{}
+}
+
+// typeName is the declared or literal type of a variable, without
+// parameters
+func typeName(d *decl) string {
+	if d.typ != nil {
+		return d.typ.Name
+	}
+	return literalType(d.value)
+}
+
+func methodItems(methods []string) []CompletionItem {
+	items := []CompletionItem{}
+	for _, m := range methods {
+		name, _, _ := strings.Cut(m, "(")
+		items = append(items, CompletionItem{Label: name, Kind: CompletionMethod, Detail: m})
+	}
+	return items
+}
+
+func declItem(d *decl) CompletionItem {
+	kind := CompletionVariable
+	switch {
+	case d.fn != nil && d.kind == kindMember, isTraitMethod(d.node):
+		kind = CompletionMethod
+	case d.fn != nil:
+		kind = CompletionFunction
+	case d.kind == kindMember:
+		kind = CompletionField
+	case d.kind == kindConstant:
+		kind = CompletionConstant
+	case d.kind == kindClass:
+		kind = CompletionClass
+	case d.kind == kindTrait:
+		kind = CompletionTrait
+	case d.kind == kindModule:
+		kind = CompletionModule
+	}
+	return CompletionItem{Label: d.name, Kind: kind, Detail: signature(d)}
+}
+
+func sortedDecls(names map[string]*decl) []*decl {
+	decls := make([]*decl, 0, len(names))
+	for _, d := range names {
+		decls = append(decls, d)
+	}
+	sort.Slice(decls, func(i, j int) bool { return decls[i].name < decls[j].name })
+	return decls
+}
+
+// location is where a target is declared. Modules embedded from the
+// standard library have no file an editor could open.
+func (s *Server) location(doc *document, t target) *Location {
+	if t.module == nil {
+		r := doc.span(tokenPos(t.decl.tok), len([]rune(t.decl.tok.Literal)))
+		return &Location{URI: doc.uri, Range: r}
+	}
+	if strings.HasPrefix(t.module.path, evaluator.StdRoot) {
+		return nil
+	}
+	lines := strings.Split(t.module.source, "\n")
+	start := tokenPos(t.decl.tok)
+	r := Range{toPosition(lines, start), toPosition(lines, tokenEnd(t.decl.tok))}
+	return &Location{URI: pathURI(t.module.path), Range: r}
+}
+
+func (s *Server) definition(doc *document, p pos) any {
+	t, _, ok := s.resolve(doc, p)
+	if !ok || t.decl == nil {
+		return nil
+	}
+	// A module name leads to the module's file
+	if t.module == nil && t.decl.kind == kindModule {
+		if mod := s.loadModule(t.decl.module, doc.dir); mod != nil && !strings.HasPrefix(mod.path, evaluator.StdRoot) {
+			return Location{URI: pathURI(mod.path)}
+		}
+	}
+	if loc := s.location(doc, t); loc != nil {
+		return *loc
+	}
+	return nil
+}
+
+func (s *Server) references(doc *document, p pos, includeDeclaration bool) []Location {
+	t, _, ok := s.resolve(doc, p)
+	locations := []Location{}
+	if !ok || t.decl == nil || t.module != nil {
+		return locations
+	}
+	refs := t.decl.refs
+	if !includeDeclaration && len(refs) > 0 {
+		refs = refs[1:]
+	}
+	for _, m := range doc.analysis.members {
+		if s.resolveMember(doc, m).decl == t.decl {
+			refs = append(refs, m.tok)
+		}
+	}
+	for _, tok := range refs {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.span(tokenPos(tok), len([]rune(tok.Literal)))})
+	}
+	return locations
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// rename renames a variable, function, class or trait declared in the
+// document. Members are left alone since uses through other objects can't
+// be found reliably.
+func (s *Server) rename(doc *document, p pos, newName string) (*WorkspaceEdit, error) {
+	if doc.analysis == nil {
+		return nil, fmt.Errorf("nothing to rename")
+	}
+	o, ok := doc.analysis.occurrenceAt(p)
+	switch {
+	case !ok || o.decl == nil:
+		return nil, fmt.Errorf("nothing to rename")
+	case o.decl.kind == kindMember:
+		return nil, fmt.Errorf("cannot rename member %s", o.decl.name)
+	case o.decl.imported != "":
+		return nil, fmt.Errorf("cannot rename %s imported from a module", o.decl.name)
+	case o.decl.kind == kindModule && (o.decl.synthetic || o.decl.module.Alias == nil):
+		return nil, fmt.Errorf("cannot rename module %s; import it with as instead", o.decl.name)
+	case !identifier.MatchString(newName):
+		return nil, fmt.Errorf("%q is not a valid name", newName)
+	case token.LookupIdent(newName) != token.IDENT:
+		return nil, fmt.Errorf("%q is a keyword", newName)
+	}
+
+	edits := []TextEdit{}
+	for _, tok := range o.decl.refs {
+		edits = append(edits, TextEdit{Range: doc.span(tokenPos(tok), len([]rune(tok.Literal))), NewText: newName})
+	}
+	return &WorkspaceEdit{Changes: map[string][]TextEdit{doc.uri: edits}}, nil
+}
+
+func (s *Server) symbols(doc *document) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	if doc.analysis == nil {
+		return symbols
+	}
+	for _, d := range doc.analysis.top {
+		symbols = append(symbols, symbol(doc, d))
+	}
+	return symbols
+}
+
+func symbol(doc *document, d *decl) DocumentSymbol {
+	kind := SymbolVariable
+	switch {
+	case d.kind == kindMember && (d.fn != nil || isTraitMethod(d.node)):
+		kind = SymbolMethod
+	case d.kind == kindMember:
+		kind = SymbolField
+	case d.fn != nil:
+		kind = SymbolFunction
+	case d.kind == kindConstant:
+		kind = SymbolConstant
+	case d.kind == kindClass:
+		kind = SymbolClass
+	case d.kind == kindTrait:
+		kind = SymbolInterface
+	case d.kind == kindModule:
+		kind = SymbolModule
+	}
+
+	selection := doc.span(tokenPos(d.tok), len([]rune(d.tok.Literal)))
+	start, end := tokenPos(d.tok), tokenEnd(d.tok)
+	if t := ast.StartToken(d.node); t.Line > 0 && tokenPos(t).before(start) {
+		start = tokenPos(t)
+	}
+	if t, ok := endToken(d.node); ok && t.Line > 0 && end.before(tokenEnd(t)) {
+		end = tokenEnd(t)
+	}
+
+	sym := DocumentSymbol{
+		Name:           d.name,
+		Detail:         signature(d),
+		Kind:           kind,
+		Range:          Range{doc.toPosition(start), doc.toPosition(end)},
+		SelectionRange: selection,
+	}
+	for _, m := range d.members {
+		sym.Children = append(sym.Children, symbol(doc, m))
+	}
+	return sym
+}
+
+// endToken is the closing brace of a declaration's body
+func endToken(node ast.Node) (token.Token, bool) {
+	var body *ast.BlockStatement
+	switch n := node.(type) {
+	case *ast.VarStatement:
+		if fn, ok := n.Value.(*ast.FunctionLiteral); ok {
+			body = fn.Body
+		}
+	case *ast.Assignment:
+		if fn, ok := n.Value.(*ast.FunctionLiteral); ok {
+			body = fn.Body
+		}
+	case *ast.Accessor:
+		if fn, ok := n.Value.(*ast.FunctionLiteral); ok {
+			body = fn.Body
+		}
+	case *ast.Class:
+		body = n.Body
+	case *ast.TraitMethod:
+		body = n.Body
+	case *ast.Trait:
+		for i := len(n.Methods) - 1; i >= 0 && body == nil; i-- {
+			body = n.Methods[i].Body
+		}
+	}
+	if body == nil {
+		return token.Token{}, false
+	}
+	return body.End, true
+}
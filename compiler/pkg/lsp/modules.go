@@ -0,0 +1,89 @@
+package lsp
+
+import (
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"path/filepath"
+	"strings"
+)
+
+// module is an imported file and the members importers can use
+type module struct {
+	path    string
+	source  string
+	members []*decl
+}
+
+// loadModule reads the module an import names, resolved from dir the way
+// the evaluator resolves it. The embedded standard library never changes,
+// so those modules are kept; others are read again each time.
+func (s *Server) loadModule(m *ast.ModuleLoad, dir string) *module {
+	name := m.Path()
+	if name == "" || name == "module" {
+		return nil
+	}
+	path, err := evaluator.FindModule(name, dir)
+	if err != nil {
+		return nil
+	}
+	if mod, ok := s.modules[path]; ok {
+		return mod
+	}
+	source, err := evaluator.ReadModule(path)
+	if err != nil {
+		return nil
+	}
+
+	// Modules with syntax errors still offer what parsed
+	program := parser.New(lexer.New(string(source))).ParseProgram()
+	a := analyze(program)
+	mod := &module{path: path, source: string(source), members: exportedDecls(program, a, path)}
+	if strings.HasPrefix(path, evaluator.StdRoot) {
+		s.modules[path] = mod
+	}
+	return mod
+}
+
+// exportedDecls mirrors what the evaluator binds for an import: a module
+// object named after the file if there is one, else the top-level names,
+// limited to the exported ones when the file exports any
+func exportedDecls(program *ast.Program, a *analysis, path string) []*decl {
+	base := strings.TrimSuffix(filepath.Base(path), ".lynx")
+	if d, ok := a.root.names[base]; ok {
+		if _, isModule := d.value.(*ast.ModuleLoad); isModule {
+			return d.members
+		}
+	}
+
+	exported := map[string]bool{}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			switch n := export.Statement.(type) {
+			case *ast.VarStatement:
+				exported[n.Name.Value] = true
+			case *ast.Class:
+				exported[n.Name.Value] = true
+			case *ast.Trait:
+				exported[n.Name.Value] = true
+			}
+		}
+	}
+	decls := []*decl{}
+	for _, d := range a.top {
+		if d.kind != kindModule && (len(exported) == 0 || exported[d.name]) {
+			decls = append(decls, d)
+		}
+	}
+	return decls
+}
+
+func (m *module) member(name string) *decl {
+	for _, d := range m.members {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
@@ -0,0 +1,392 @@
+// Package lsp implements a Language Server Protocol server for Lynx over
+// stdio. It reports syntax errors and lint findings as diagnostics and
+// answers hover, completion, definition, references, rename and document
+// symbol requests from the parsed program.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/lint"
+	"lynx/pkg/parser"
+	"lynx/pkg/project"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Server answers requests from one client, handling them in order
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document
+	modules   map[string]*module // standard library modules by path
+	shutdown  bool
+}
+
+// document is an open file as the client last sent it
+type document struct {
+	uri   string
+	text  string
+	lines []string
+	dir   string // where its imports are resolved from
+	// analysis of the latest text that parsed, so features keep working
+	// while an edit is half typed
+	analysis *analysis
+}
+
+func New(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*document),
+		modules:   make(map[string]*module),
+	}
+}
+
+// Run serves requests until the client sends exit or closes the input
+func (s *Server) Run() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, codeParseError, err.Error())
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		s.handle(req)
+	}
+}
+
+// read returns the body of the next message, framed by a Content-Length
+// header
+func (s *Server) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("message without Content-Length")
+	}
+	body := make([]byte, length)
+	_, err := io.ReadFull(s.in, body)
+	return body, err
+}
+
+func (s *Server) write(message any) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (s *Server) reply(id json.RawMessage, result any) {
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result})
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, message string) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	s.write(errorResponse{JSONRPC: "2.0", ID: id, Error: responseError{code, message}})
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) handle(req request) {
+	isRequest := req.ID != nil
+	if s.shutdown && isRequest {
+		s.replyError(req.ID, codeInvalidRequest, "server is shut down")
+		return
+	}
+
+	switch req.Method {
+	case "initialize":
+		s.reply(req.ID, map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // the full text on every change
+				"hoverProvider":          true,
+				"completionProvider":     map[string]any{"triggerCharacters": []string{"."}},
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"renameProvider":         true,
+				"documentSymbolProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "lynx"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		s.reply(req.ID, nil)
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if json.Unmarshal(req.Params, &params) == nil {
+			s.update(params.TextDocument.URI, params.TextDocument.Text)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if json.Unmarshal(req.Params, &params) == nil && len(params.ContentChanges) > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[len(params.ContentChanges)-1].Text)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if json.Unmarshal(req.Params, &params) == nil {
+			delete(s.documents, params.TextDocument.URI)
+			s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		}
+	case "textDocument/hover":
+		s.positionRequest(req, func(doc *document, p pos) any { return s.hover(doc, p) })
+	case "textDocument/completion":
+		s.positionRequest(req, func(doc *document, p pos) any { return s.completion(doc, p) })
+	case "textDocument/definition":
+		s.positionRequest(req, func(doc *document, p pos) any { return s.definition(doc, p) })
+	case "textDocument/references":
+		var params referenceParams
+		if doc, p, ok := s.position(req, &params, &params.positionParams); ok {
+			s.reply(req.ID, s.references(doc, p, params.Context.IncludeDeclaration))
+		}
+	case "textDocument/rename":
+		var params renameParams
+		if doc, p, ok := s.position(req, &params, &params.positionParams); ok {
+			edit, err := s.rename(doc, p, params.NewName)
+			if err != nil {
+				s.replyError(req.ID, codeRequestFailed, err.Error())
+				return
+			}
+			s.reply(req.ID, edit)
+		}
+	case "textDocument/documentSymbol":
+		var params documentSymbolParams
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.replyError(req.ID, codeInvalidParams, err.Error())
+			return
+		}
+		doc, ok := s.documents[params.TextDocument.URI]
+		if !ok {
+			s.replyError(req.ID, codeInvalidParams, "unknown document "+params.TextDocument.URI)
+			return
+		}
+		s.reply(req.ID, s.symbols(doc))
+	default:
+		// Notifications the server has no use for are ignored, as the
+		// protocol asks
+		if isRequest {
+			s.replyError(req.ID, codeMethodNotFound, "unsupported method "+req.Method)
+		}
+	}
+}
+
+// position decodes the params of a request about a position in an open
+// document, replying with an error when that fails
+func (s *Server) position(req request, params any, at *positionParams) (*document, pos, bool) {
+	if err := json.Unmarshal(req.Params, params); err != nil {
+		s.replyError(req.ID, codeInvalidParams, err.Error())
+		return nil, pos{}, false
+	}
+	doc, ok := s.documents[at.TextDocument.URI]
+	if !ok {
+		s.replyError(req.ID, codeInvalidParams, "unknown document "+at.TextDocument.URI)
+		return nil, pos{}, false
+	}
+	return doc, doc.fromPosition(at.Position), true
+}
+
+func (s *Server) positionRequest(req request, answer func(*document, pos) any) {
+	var params positionParams
+	if doc, p, ok := s.position(req, &params, &params); ok {
+		s.reply(req.ID, answer(doc, p))
+	}
+}
+
+// update reparses a document and publishes its diagnostics
+func (s *Server) update(uri, text string) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		doc = &document{uri: uri, dir: uriDir(uri)}
+		s.documents[uri] = doc
+	}
+	doc.text = text
+	doc.lines = strings.Split(text, "\n")
+
+	l := lexer.New(text)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	diags := []Diagnostic{}
+	for _, e := range l.Errors() {
+		diags = append(diags, Diagnostic{
+			Range:    doc.span(pos{e.Position.Line, e.Position.Column}, 1),
+			Severity: SeverityError,
+			Source:   "lynx",
+			Message:  e.Message,
+		})
+	}
+	for _, e := range p.Errors() {
+		length := utf8.RuneCountInString(e.Token.Literal)
+		if e.Token.Line != e.Line || e.Token.Column != e.Column || length == 0 {
+			length = 1
+		}
+		diags = append(diags, Diagnostic{
+			Range:    doc.span(pos{e.Line, max(e.Column, 1)}, length),
+			Severity: SeverityError,
+			Source:   "lynx",
+			Message:  e.Message,
+		})
+	}
+
+	if len(l.Errors()) == 0 && len(p.Errors()) == 0 {
+		doc.analysis = analyze(program)
+		for _, d := range lint.Program(program, "", lintConfig(doc.dir)) {
+			severity := SeverityWarning
+			if d.Severity == lint.Error {
+				severity = SeverityError
+			}
+			diags = append(diags, Diagnostic{
+				Range:    doc.span(pos{d.Line, d.Column}, 1),
+				Severity: severity,
+				Code:     d.Rule.ID,
+				Source:   "lynx lint",
+				Message:  d.Message,
+			})
+		}
+	} else if doc.analysis == nil {
+		doc.analysis = analyzePartial(program)
+	}
+
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+// analyzePartial analyzes a program that failed to parse. Its tree can
+// hold nil nodes where parsing gave up; what was indexed before reaching
+// one is kept.
+func analyzePartial(program *ast.Program) (a *analysis) {
+	a = &analysis{classes: make(map[*ast.Class]*decl)}
+	defer func() {
+		if recover() != nil {
+			a.root.end = pos{math.MaxInt, math.MaxInt}
+		}
+	}()
+	return a.run(program)
+}
+
+// lintConfig reads the rule settings of the project governing dir; a
+// broken manifest just leaves the defaults
+func lintConfig(dir string) lint.Config {
+	m, err := project.Find(dir)
+	if err != nil || m == nil {
+		return lint.Config{}
+	}
+	config, err := lint.ParseConfig(m.Lint)
+	if err != nil {
+		return lint.Config{}
+	}
+	return config
+}
+
+// uriPath returns the file a file:// URI names, or "" for other schemes
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+func uriDir(uri string) string {
+	if path := uriPath(uri); path != "" {
+		return filepath.Dir(path)
+	}
+	dir, _ := os.Getwd()
+	return dir
+}
+
+// The protocol counts characters in UTF-16 code units from 0, the lexer in
+// runes from 1, so positions are converted through the line's text
+
+func (doc *document) fromPosition(p Position) pos {
+	if p.Line < 0 || p.Line >= len(doc.lines) {
+		return pos{p.Line + 1, p.Character + 1}
+	}
+	return pos{p.Line + 1, runeColumn(doc.lines[p.Line], p.Character)}
+}
+
+func (doc *document) toPosition(p pos) Position {
+	return toPosition(doc.lines, p)
+}
+
+// span is the range of length runes starting at p
+func (doc *document) span(p pos, length int) Range {
+	return Range{doc.toPosition(p), doc.toPosition(pos{p.line, p.col + length})}
+}
+
+func toPosition(lines []string, p pos) Position {
+	line := max(p.line-1, 0)
+	if line >= len(lines) {
+		return Position{line, max(p.col-1, 0)}
+	}
+	return Position{line, utf16Column(lines[line], p.col)}
+}
+
+// runeColumn converts a UTF-16 offset into a 1-based rune column
+func runeColumn(line string, character int) int {
+	col, units := 1, 0
+	for _, r := range line {
+		if units >= character {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		col++
+	}
+	return col
+}
+
+// utf16Column converts a 1-based rune column into a UTF-16 offset
+func utf16Column(line string, col int) int {
+	units, n := 0, 1
+	for _, r := range line {
+		if n >= col {
+			return units
+		}
+		units += len(utf16.Encode([]rune{r}))
+		n++
+	}
+	return units + col - n
+}
@@ -2,6 +2,8 @@ package object
 
 import (
 	"fmt"
+	"lynx/pkg/ast"
+	"sort"
 )
 
 // Env holds variable bindings and tracks constants
@@ -10,6 +12,23 @@ type Env struct {
 	consts map[string]bool
 	outer  *Env
 	Dir    string
+	File   string // source file of the code running in this scope, if known
+	Owner  *Class // class whose methods run in this scope, if any
+
+	frame    bool // function call scope that collects deferred actions
+	loop     bool // scope of a for loop, rebound on every iteration
+	deferred []Deferred
+	exports  map[string]bool
+}
+
+// Deferred is an action registered by `defer`, run when the enclosing
+// function or program exits. A deferred call had its function and arguments
+// evaluated at the defer and only Call is left; any other action is
+// evaluated in Env.
+type Deferred struct {
+	Action ast.Node
+	Env    *Env
+	Call   func() Object
 }
 
 // New creates a new environment with the given directory for module resolution
@@ -54,8 +73,113 @@ func (e *Env) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Names returns the bindings defined directly in e, sorted
+func (e *Env) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Export marks a top-level binding as visible to importers of the module
+// evaluated in e. It fails for nested environments.
+func (e *Env) Export(name string) bool {
+	if e.outer != nil {
+		return false
+	}
+	if e.exports == nil {
+		e.exports = make(map[string]bool)
+	}
+	e.exports[name] = true
+	return true
+}
+
+// Exports returns the exported names, or nil if the module exports nothing
+// explicitly
+func (e *Env) Exports() map[string]bool {
+	return e.exports
+}
+
+// EnclosingClass returns the class owning the nearest method scope
+func (e *Env) EnclosingClass() *Class {
+	for env := e; env != nil; env = env.outer {
+		if env.Owner != nil {
+			return env.Owner
+		}
+	}
+	return nil
+}
+
+// NewFrame creates an enclosed environment for a function call
+func (e *Env) NewFrame() *Env {
+	frame := e.NewEnclosedEnv()
+	frame.frame = true
+	return frame
+}
+
+// NewLoopEnv creates an enclosed environment for the variables of a for
+// loop
+func (e *Env) NewLoopEnv() *Env {
+	loop := e.NewEnclosedEnv()
+	loop.loop = true
+	return loop
+}
+
+// Defer registers an action on the nearest function frame, or on the
+// outermost environment when not inside a function. The action sees the
+// variables of enclosing loops as they are now, so each iteration defers
+// with its own values, and any other variable as it is at exit.
+func (e *Env) Defer(action ast.Node) {
+	env, snapshot := e, e
+	seen := map[string]bool{}
+	for ; !env.frame && env.outer != nil; env = env.outer {
+		for name, val := range env.store {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			if env.loop {
+				if snapshot == e {
+					snapshot = e.NewEnclosedEnv()
+				}
+				snapshot.store[name] = val
+			}
+		}
+	}
+	env.deferred = append(env.deferred, Deferred{Action: action, Env: snapshot})
+}
+
+// DeferCall registers a call whose function and arguments are already
+// evaluated, as Defer does for other actions
+func (e *Env) DeferCall(call func() Object) {
+	env := e
+	for !env.frame && env.outer != nil {
+		env = env.outer
+	}
+	env.deferred = append(env.deferred, Deferred{Call: call})
+}
+
+// TakeDeferred returns the actions registered on e in the order they must
+// run (last registered first) and clears them
+func (e *Env) TakeDeferred() []Deferred {
+	actions := make([]Deferred, 0, len(e.deferred))
+	for i := len(e.deferred) - 1; i >= 0; i-- {
+		actions = append(actions, e.deferred[i])
+	}
+	e.deferred = nil
+	return actions
+}
+
 func (e *Env) NewEnclosedEnv() *Env {
 	enclosed := New(e.Dir)
+	enclosed.File = e.File
 	enclosed.outer = e
 	return enclosed
 }
+
+// Outer returns the enclosing environment, or nil for a top-level one
+func (e *Env) Outer() *Env {
+	return e.outer
+}
@@ -36,6 +36,9 @@ const (
 	EXCEPTION_OBJ = "EXCEPTION"
 	CLASS_OBJ     = "CLASS"
 	INSTANCE_OBJ  = "INSTANCE"
+	SUPER_OBJ     = "SUPER"
+	TRAIT_OBJ     = "TRAIT"
+	RESULT_OBJ    = "RESULT"
 )
 
 // Float represents a floating-point number
@@ -66,7 +69,10 @@ func (n *Null) Type() ObjectType { return NULL_OBJ }
 func (n *Null) Inspect() string  { return "null" }
 
 type Error struct {
-	Message string
+	Message   string
+	Exception *Exception // payload of a user-raised error, nil for runtime errors
+	Internal  bool       // interpreter bug rather than a program error; never caught
+	Result    *Result    // Err being returned early by `?`, unwinds to the caller
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
@@ -83,6 +89,11 @@ type Function struct {
 	Parameters 	[]*ast.Identifier
 	Body		*ast.BlockStatement
 	Env 		*Env
+
+	Name       string                // binding name, used in error messages
+	ParamTypes []*ast.TypeAnnotation // parallel to Parameters, nil if none annotated
+	ReturnType *ast.TypeAnnotation
+	Doc        string // the /// comment of its declaration
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -176,7 +187,8 @@ func (b *Function) HashKey() HashKey {
 }
 
 type Builtin struct {
-	Fn func(args ...Object) Object
+	Fn   func(args ...Object) Object
+	Name string // registry name, for tooling
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
@@ -196,11 +208,40 @@ type Module struct {
 	Name    string
 	Members map[string]Object
 	Env     *Env
+	Exports map[string]bool // visible names; nil exposes every binding
+	Doc     string
+	Docs    map[string]string // doc comments of members, by name
 }
 
 func (m *Module) Type() ObjectType { return "MODULE" }
 func (m *Module) Inspect() string  { return fmt.Sprintf("<module %s>", m.Name) }
 
+// Get looks up a member visible to importers of the module
+func (m *Module) Get(name string) (Object, bool) {
+	if m.Members != nil {
+		val, ok := m.Members[name]
+		return val, ok
+	}
+	if m.Exports != nil && !m.Exports[name] {
+		return nil, false
+	}
+	return m.Env.Get(name)
+}
+
+// Result is an explicit success (Ok) or failure (Err) value
+type Result struct {
+	Ok    bool
+	Value Object
+}
+
+func (r *Result) Type() ObjectType { return RESULT_OBJ }
+func (r *Result) Inspect() string {
+	if r.Ok {
+		return "Ok(" + r.Value.Inspect() + ")"
+	}
+	return "Err(" + r.Value.Inspect() + ")"
+}
+
 type Tuple struct {
 	Elements []Object
 }
@@ -216,20 +257,62 @@ func (t *Tuple) Inspect() string {
 	return out
 }
 
+// Exception is a catchable error as seen by an `on` clause
 type Exception struct {
-	Value Error
+	Kind    string
+	Message string
+	Fields  map[string]Object
+	Cause   *Exception
+	Class   *Class // set when raised from a class instance
 }
 
 func (e *Exception) Type() ObjectType { return EXCEPTION_OBJ }
 func (e *Exception) Inspect() string {
-	return e.Value.Inspect()
+	return e.Kind + ": " + e.Message
+}
+
+// Is reports whether the exception matches the type name, walking the
+// raising class's superclasses. Every exception is an "Exception".
+func (e *Exception) Is(kind string) bool {
+	if kind == "Exception" || kind == e.Kind {
+		return true
+	}
+	for c := e.Class; c != nil; c = c.SuperClass {
+		if c.Name == kind {
+			return true
+		}
+	}
+	return false
 }
 
 type Class struct {
 	Name       string
 	SuperClass *Class
 	Methods    map[string]*Function
+	Fields     []*ast.VarStatement
+	Statics    *Env
+	Traits     []*Trait
+	Getters    map[string]*Function
+	Setters    map[string]*Function
+	Private    map[string]bool
 	Env        *Env
+	Doc        string
+}
+
+// IsPrivate reports whether member is hidden outside the class's methods,
+// either by a leading underscore or an explicit private declaration
+func (c *Class) IsPrivate(member string) bool {
+	return (len(member) > 1 && member[0] == '_') || c.Private[member]
+}
+
+// IsSubclassOf reports whether c is other or inherits from it
+func (c *Class) IsSubclassOf(other *Class) bool {
+	for cls := c; cls != nil; cls = cls.SuperClass {
+		if cls == other {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *Class) Type() ObjectType { return CLASS_OBJ }
@@ -237,12 +320,61 @@ func (c *Class) Inspect() string {
 	return fmt.Sprintf("<class %s>", c.Name)
 }
 
+// Implements reports whether c or one of its superclasses declares trait
+func (c *Class) Implements(trait *Trait) bool {
+	for cls := c; cls != nil; cls = cls.SuperClass {
+		for _, t := range cls.Traits {
+			if t == trait {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Trait is a named contract of required methods (name to arity) plus
+// default methods mixed into implementing classes
+type Trait struct {
+	Name     string
+	Required map[string]int
+	Defaults map[string]*Function
+}
+
+func (t *Trait) Type() ObjectType { return TRAIT_OBJ }
+func (t *Trait) Inspect() string {
+	return fmt.Sprintf("<trait %s>", t.Name)
+}
+
 type Instance struct {
 	Class      *Class
 	Attributes map[string]Object
 }
 
+// BoundSuper is the value of `super` inside a method: lookups start at Class
+// while Instance stays bound as self
+type BoundSuper struct {
+	Instance *Instance
+	Class    *Class
+}
+
+func (b *BoundSuper) Type() ObjectType { return SUPER_OBJ }
+func (b *BoundSuper) Inspect() string {
+	return fmt.Sprintf("<super %s>", b.Class.Name)
+}
+
+// MethodInvoker calls a special method such as str on an instance. It is
+// installed by the evaluator and reports false when the method is undefined.
+var MethodInvoker func(inst *Instance, method string, args []Object) (Object, bool)
+
 func (i *Instance) Type() ObjectType { return INSTANCE_OBJ }
 func (i *Instance) Inspect() string {
+	if MethodInvoker != nil {
+		if result, ok := MethodInvoker(i, "str", []Object{}); ok {
+			if str, ok := result.(*String); ok {
+				return str.Value
+			}
+			return result.Inspect()
+		}
+	}
 	return fmt.Sprintf("<instance of %s>", i.Class.Name)
 }
@@ -0,0 +1,42 @@
+package object
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Repr renders obj the way it would be written in source: strings are
+// quoted, also inside containers, so "x" and x can be told apart. Hash
+// pairs are sorted to keep the output stable.
+func Repr(obj Object) string {
+	switch obj := obj.(type) {
+	case *String:
+		return strconv.Quote(obj.Value)
+	case *Array:
+		return "[" + reprList(obj.Elements) + "]"
+	case *Tuple:
+		return "(" + reprList(obj.Elements) + ")"
+	case *Hash:
+		pairs := []string{}
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, Repr(pair.Key)+": "+Repr(pair.Value))
+		}
+		sort.Strings(pairs)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	case *Result:
+		if obj.Ok {
+			return "Ok(" + Repr(obj.Value) + ")"
+		}
+		return "Err(" + Repr(obj.Value) + ")"
+	}
+	return obj.Inspect()
+}
+
+func reprList(elements []Object) string {
+	items := make([]string, len(elements))
+	for i, el := range elements {
+		items[i] = Repr(el)
+	}
+	return strings.Join(items, ", ")
+}
@@ -6,6 +6,7 @@ import (
 	"lynx/pkg/lexer"
 	"lynx/pkg/token"
 	"strconv"
+	"strings"
 )
 
 // Operator precedence levels (higher = binds tighter)
@@ -31,28 +32,30 @@ const (
 
 // Precedence map for infix operators
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.DOT:      CALL,
-	token.LBRACKET: CALL,
-	token.LTE:      LESSEQ,
-	token.GTE:      GREATEREQ,
-	token.MODULOS:  PRODUCT,
-	token.POWER:    PRODUCT,
-	token.IN:       LESSGREATER,
-	token.AND:      AND,
-	token.OR:       OR,
-	token.CONCAT:   CONCAT,
-	token.SQUARE:   SQUARE,
-	token.PIPE:     PIPE,
-	token.NULL:     NULL,
+	token.EQ:         EQUALS,
+	token.NOT_EQ:     EQUALS,
+	token.LT:         LESSGREATER,
+	token.GT:         LESSGREATER,
+	token.PLUS:       SUM,
+	token.MINUS:      SUM,
+	token.SLASH:      PRODUCT,
+	token.ASTERISK:   PRODUCT,
+	token.LPAREN:     CALL,
+	token.DOT:        CALL,
+	token.LBRACKET:   CALL,
+	token.TRY:        CALL,
+	token.LTE:        LESSEQ,
+	token.GTE:        GREATEREQ,
+	token.MODULOS:    PRODUCT,
+	token.POWER:      PRODUCT,
+	token.IN:         LESSGREATER,
+	token.INSTANCEOF: LESSGREATER,
+	token.AND:        AND,
+	token.OR:         OR,
+	token.CONCAT:     CONCAT,
+	token.SQUARE:     SQUARE,
+	token.PIPE:       PIPE,
+	token.NULL:       NULL,
 }
 
 type ParseError struct {
@@ -85,6 +88,8 @@ type Parser struct {
 	currentStatement string
 	functionDepth    int
 	loopDepth        int
+	incomplete       bool // an error was caused by the input ending early
+	ends             map[ast.Node]token.Token
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -121,6 +126,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.registerPrefix(token.NULL, p.parseNull)
 	p.registerPrefix(token.SELF, p.parseSelf)
+	p.registerPrefix(token.SUPER, p.parseSuper)
 	p.registerPrefix(token.AT, p.parseAtExpression)
 	p.registerPrefix(token.ERROR, p.parseErrorExpression)
 
@@ -134,7 +140,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.CONCAT, p.parseInfixExpression)
 	p.registerInfix(token.AND, p.parseInfixExpression)
 	p.registerInfix(token.IN, p.parseInfixExpression)
+	p.registerInfix(token.INSTANCEOF, p.parseInfixExpression)
 	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.TRY, p.parseTryExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
@@ -152,6 +160,39 @@ func (p *Parser) Errors() []ParseError {
 	return p.errors
 }
 
+// Incomplete reports whether the program failed to parse because the input
+// ended early, as with an unclosed block or string or a trailing operator
+func (p *Parser) Incomplete() bool {
+	return p.incomplete || p.l.Unterminated()
+}
+
+// RecordEnds makes the parser remember the last token of every statement,
+// expression and type annotation it produces, for End. It must be called
+// before parsing.
+func (p *Parser) RecordEnds() {
+	p.ends = map[ast.Node]token.Token{}
+}
+
+// End is the last token of a statement, expression or type annotation the
+// parser produced, which together with its first gives its span. It is only
+// known when RecordEnds was called.
+func (p *Parser) End(node ast.Node) (token.Token, bool) {
+	end, ok := p.ends[node]
+	return end, ok
+}
+
+// markEnd records curToken as the last token of node. A node already
+// complete keeps its end, so an expression in parentheses doesn't take in
+// the closing parenthesis.
+func (p *Parser) markEnd(node ast.Node) {
+	if p.ends == nil || node == nil {
+		return
+	}
+	if _, ok := p.ends[node]; !ok {
+		p.ends[node] = p.curToken
+	}
+}
+
 func (p *Parser) ErrorStrings() []string {
 	var strs []string
 	for _, err := range p.errors {
@@ -171,6 +212,9 @@ func (p *Parser) addError(errorType, message string) {
 }
 
 func (p *Parser) peekError(expected token.TokenType) {
+	if p.peekTokenIs(token.EOF) {
+		p.incomplete = true
+	}
 	message := fmt.Sprintf("expected %q, got %q", expected, p.peekToken.Literal)
 	p.addError("SyntaxError", message)
 }
@@ -190,11 +234,17 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	program.Comments = p.l.Comments()
+	program.BlankLines = p.l.BlankLines()
 	return program
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.peekTokenIs(token.ARROW) {
+		return p.parseArrowFunction([]*ast.Identifier{ident})
+	}
+	return ident
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
@@ -212,7 +262,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parseFloatLiteral() ast.Expression {
-	lit := &ast.FloatLiteral{}
+	lit := &ast.FloatLiteral{Token: p.curToken}
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
 		p.addError(
@@ -225,13 +275,103 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	return lit
 }
 
+// parseStatement parses a statement and attaches the /// doc comment on the
+// lines directly above it to the declaration it makes
 func (p *Parser) parseStatement() ast.Statement {
+	doc := p.docComment(p.curToken.Line)
+	stmt := p.parseBareStatement()
+	if doc != "" {
+		attachDoc(stmt, doc)
+	}
+	p.markEnd(stmt)
+	return stmt
+}
+
+// docComment joins the /// comments on the lines ending just above line,
+// without their markers
+func (p *Parser) docComment(line int) string {
+	comments := p.l.Comments()
+	i := len(comments) - 1
+	for i >= 0 && comments[i].Line >= line {
+		i--
+	}
+	lines := []string{}
+	for ; i >= 0; i-- {
+		c := comments[i]
+		if c.Line != line-1-len(lines) || c.Trailing || !strings.HasPrefix(c.Text, "///") {
+			break
+		}
+		text := strings.TrimPrefix(c.Text, "///")
+		lines = append([]string{strings.TrimPrefix(text, " ")}, lines...)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// attachDoc records doc on a declaration, and on the function it binds so
+// the doc is there at runtime. Statements that failed to parse are nil.
+func attachDoc(stmt ast.Statement, doc string) {
+	var value ast.Expression
+	switch s := stmt.(type) {
+	case *ast.VarStatement:
+		if s != nil {
+			s.Doc = doc
+			value = s.Value
+		}
+	case *ast.Assignment:
+		if s != nil {
+			s.Doc = doc
+			value = s.Value
+		}
+	case *ast.Accessor:
+		if s != nil {
+			s.Doc = doc
+			value = s.Value
+		}
+	case *ast.Class:
+		if s != nil {
+			s.Doc = doc
+		}
+	case *ast.ExportStatement:
+		if s != nil {
+			attachDoc(s.Statement, doc)
+		}
+	case *ast.PrivateStatement:
+		if s != nil {
+			attachDoc(s.Statement, doc)
+		}
+	case *ast.StaticStatement:
+		if s != nil {
+			attachDoc(s.Statement, doc)
+		}
+	}
+	if fn, ok := value.(*ast.FunctionLiteral); ok && fn != nil {
+		fn.Doc = doc
+	}
+}
+
+func (p *Parser) parseBareStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET, token.CONST:
 		return p.parseVarStatement()
+	case token.FUNCTION:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionDeclaration()
+		}
+		return p.parseExpressionStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
-	case token.IDENT, token.SELF:
+	case token.IDENT:
+		if p.isAccessorStart() {
+			return p.parseAccessorStatement()
+		}
+		if p.isPrivateStart() {
+			return p.parsePrivateStatement()
+		}
+		if p.isExportStart() {
+			return p.parseExportStatement()
+		}
+		return p.parseAssignmentOrExpressionStatement()
+	case token.SELF:
 		return p.parseAssignmentOrExpressionStatement()
 	case token.FOR:
 		return p.parseForStatement()
@@ -242,15 +382,24 @@ func (p *Parser) parseStatement() ast.Statement {
 	case token.BREAK:
 		return p.parseBreakStatement()
 	case token.AT:
-		return p.parseAtExpression().(ast.Statement)
+		if load, ok := p.parseAtExpression().(*ast.ModuleLoad); ok {
+			return load
+		}
+		return nil
 	case token.SWITCH:
 		return p.parseSwitchStatement()
 	case token.ERROR:
 		return p.parseErrorStatement()
 	case token.CATCH:
 		return p.parseCatchStatement()
+	case token.DEFER:
+		return p.parseDeferStatement()
 	case token.CLASS:
 		return p.parseClassStatement()
+	case token.STATIC:
+		return p.parseStaticStatement()
+	case token.TRAIT:
+		return p.parseTraitStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -288,6 +437,24 @@ func (p *Parser) parseClassStatement() ast.Statement {
 		}
 	}
 
+	if p.peekTokenIs(token.IMPL) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(token.IDENT) {
+				p.addError("SyntaxError", "Expected trait name after 'impl'")
+				return nil
+			}
+			stmt.Traits = append(stmt.Traits, &ast.Identifier{
+				Token: p.curToken,
+				Value: p.curToken.Literal,
+			})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	}
+
 	if !p.expectPeek(token.LBRACE) {
 		p.addError("SyntaxError", "Expected '{' to start class body")
 		return nil
@@ -298,6 +465,188 @@ func (p *Parser) parseClassStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseTraitStatement() ast.Statement {
+	stmt := &ast.Trait{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		p.addError("SyntaxError", "Expected trait name after 'trait'")
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		p.addError("SyntaxError", "Expected '{' to start trait body")
+		return nil
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		method := p.parseTraitMethod()
+		if method == nil {
+			return nil
+		}
+		stmt.Methods = append(stmt.Methods, method)
+		p.nextToken()
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+		p.addError("SyntaxError", "Missing closing brace for trait body")
+		return nil
+	}
+	return stmt
+}
+
+// parseTraitMethod parses `name(params)` with an optional `{ body }`, or a
+// default written like a class method: `let name = fn(params) { body }`
+func (p *Parser) parseTraitMethod() *ast.TraitMethod {
+	if p.curTokenIs(token.LET) {
+		varStmt := p.parseVarStatement()
+		if varStmt == nil {
+			return nil
+		}
+		fnLit, ok := varStmt.Value.(*ast.FunctionLiteral)
+		if !ok {
+			p.addError("SyntaxError", "Trait members must be methods")
+			return nil
+		}
+		return &ast.TraitMethod{
+			Token:      varStmt.Token,
+			Name:       varStmt.Name,
+			Parameters: fnLit.Parameters,
+			Body:       fnLit.Body,
+		}
+	}
+
+	if !p.curTokenIs(token.IDENT) {
+		p.addError("SyntaxError", fmt.Sprintf("Expected method signature in trait body, got %s", p.curToken.Type))
+		return nil
+	}
+	method := &ast.TraitMethod{
+		Token: p.curToken,
+		Name:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+	}
+
+	if !p.expectPeek(token.LPAREN) {
+		p.addError("SyntaxError", "Expected '(' after trait method name")
+		return nil
+	}
+	p.functionDepth++
+	defer func() { p.functionDepth-- }()
+	method.Parameters, _ = p.parseFunctionParameters()
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		method.Body = p.parseBlockStatement()
+	}
+	return method
+}
+
+// get, set and private are contextual so they stay usable as identifiers
+func (p *Parser) isAccessorStart() bool {
+	return (p.curToken.Literal == "get" || p.curToken.Literal == "set") && p.peekTokenIs(token.IDENT)
+}
+
+func (p *Parser) isPrivateStart() bool {
+	if p.curToken.Literal != "private" {
+		return false
+	}
+	return p.peekTokenIs(token.LET) || p.peekTokenIs(token.CONST) ||
+		p.peekTokenIs(token.STATIC) || p.peekTokenIs(token.IDENT)
+}
+
+func (p *Parser) isExportStart() bool {
+	if p.curToken.Literal != "export" {
+		return false
+	}
+	return p.peekTokenIs(token.LET) || p.peekTokenIs(token.CONST) ||
+		p.peekTokenIs(token.FUNCTION) || p.peekTokenIs(token.CLASS) ||
+		p.peekTokenIs(token.TRAIT)
+}
+
+func (p *Parser) parseExportStatement() ast.Statement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+	p.nextToken()
+
+	switch p.curToken.Type {
+	case token.LET, token.CONST:
+		if v := p.parseVarStatement(); v != nil {
+			stmt.Statement = v
+		}
+	case token.FUNCTION:
+		if !p.peekTokenIs(token.IDENT) {
+			p.addError("SyntaxError", "Expected function name after 'export fn'")
+			return nil
+		}
+		stmt.Statement = p.parseFunctionDeclaration()
+	case token.CLASS:
+		stmt.Statement = p.parseClassStatement()
+	case token.TRAIT:
+		stmt.Statement = p.parseTraitStatement()
+	}
+
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseAccessorStatement() ast.Statement {
+	stmt := &ast.Accessor{Token: p.curToken, Kind: p.curToken.Literal}
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.ASSIGN) {
+		p.addError("SyntaxError", "Missing assignment operator")
+		return nil
+	}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if _, ok := stmt.Value.(*ast.FunctionLiteral); !ok {
+		p.addError("SyntaxError", fmt.Sprintf("%s accessor %s must be a function", stmt.Kind, stmt.Name.Value))
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parsePrivateStatement() ast.Statement {
+	stmt := &ast.PrivateStatement{Token: p.curToken}
+	p.nextToken()
+
+	switch {
+	case p.curTokenIs(token.LET), p.curTokenIs(token.CONST):
+		stmt.Statement = p.parseVarStatement()
+	case p.curTokenIs(token.STATIC):
+		stmt.Statement = p.parseStaticStatement()
+	case p.isAccessorStart():
+		stmt.Statement = p.parseAccessorStatement()
+	default:
+		p.addError("SyntaxError", "Expected member declaration after 'private'")
+		return nil
+	}
+
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
+func (p *Parser) parseStaticStatement() ast.Statement {
+	stmt := &ast.StaticStatement{Token: p.curToken}
+
+	if !p.peekTokenIs(token.LET) && !p.peekTokenIs(token.CONST) {
+		p.addError("SyntaxError", "Expected 'let' or 'const' after 'static'")
+		return nil
+	}
+	p.nextToken()
+
+	stmt.Statement = p.parseVarStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+	return stmt
+}
+
 func (p *Parser) parseAssignmentOrExpressionStatement() ast.Statement {
 	lhs := p.parseExpression(LOWEST)
 
@@ -329,6 +678,17 @@ func (p *Parser) parseErrorStatement() ast.Statement {
 	return stmt
 }
 
+func (p *Parser) parseDeferStatement() ast.Statement {
+	stmt := &ast.DeferStatement{Token: p.curToken}
+	p.nextToken()
+	if p.curTokenIs(token.LBRACE) {
+		stmt.Body = p.parseBlockStatement()
+		return stmt
+	}
+	stmt.Value = p.parseExpression(LOWEST)
+	return stmt
+}
+
 func (p *Parser) parseCatchStatement() ast.Statement {
 	stmt := &ast.CatchStatement{Token: p.curToken}
 
@@ -342,28 +702,65 @@ func (p *Parser) parseCatchStatement() ast.Statement {
 
 	stmt.Body = p.parseBlockStatement()
 
-	if p.peekTokenIs(token.ON) {
+	for p.peekTokenIs(token.ON) {
 		p.nextToken()
-
-		if !p.expectPeek(token.IDENT) {
-			p.addError("SyntaxError", "Expected identifier after 'on'")
+		handler := p.parseCatchHandler()
+		if handler == nil {
 			return nil
 		}
-		stmt.ErrorVar = &ast.Identifier{
-			Token: p.curToken,
-			Value: p.curToken.Literal,
-		}
+		stmt.Handlers = append(stmt.Handlers, handler)
+	}
 
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
 		if !p.expectPeek(token.LBRACE) {
-			p.addError("SyntaxError", "Missing opening brace for 'on' block")
+			p.addError("SyntaxError", "Missing opening brace for 'finally' block")
 			return nil
 		}
-		stmt.OnBody = p.parseBlockStatement()
+		stmt.Finally = p.parseBlockStatement()
 	}
 
 	return stmt
 }
 
+func (p *Parser) parseCatchHandler() *ast.CatchHandler {
+	handler := &ast.CatchHandler{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		p.addError("SyntaxError", "Expected identifier after 'on'")
+		return nil
+	}
+	handler.ErrorVar = &ast.Identifier{
+		Token: p.curToken,
+		Value: p.curToken.Literal,
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		for {
+			if !p.expectPeek(token.IDENT) {
+				p.addError("SyntaxError", "Expected error type after ':'")
+				return nil
+			}
+			handler.Types = append(handler.Types, &ast.Identifier{
+				Token: p.curToken,
+				Value: p.curToken.Literal,
+			})
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		p.addError("SyntaxError", "Missing opening brace for 'on' block")
+		return nil
+	}
+	handler.Body = p.parseBlockStatement()
+	return handler
+}
+
 func (p *Parser) parseSwitchStatement() ast.Statement {
 	switchStmt := &ast.SwitchStatement{Token: p.curToken}
 	p.nextToken()
@@ -514,7 +911,33 @@ func (p *Parser) parseAtExpression() ast.Expression {
 	expr := &ast.ModuleLoad{Token: p.curToken}
 	p.nextToken()
 
-	expr.Name = p.parseIdentifier()
+	switch p.curToken.Type {
+	case token.STR:
+		expr.Name = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	case token.IDENT:
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		for p.peekTokenIs(token.DOT) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				p.addError("SyntaxError", "Expected module name after '.'")
+				return nil
+			}
+			name.Value += "." + p.curToken.Literal
+		}
+		expr.Name = name
+	default:
+		p.addError("SyntaxError", "Expected module name or path after '@'")
+		return nil
+	}
+
+	if p.peekToken.Type == token.IDENT && p.peekToken.Literal == "as" {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			p.addError("SyntaxError", "Expected alias after 'as'")
+			return nil
+		}
+		expr.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if p.peekTokenIs(token.LPAREN) {
 		p.nextToken()
@@ -662,6 +1085,15 @@ func (p *Parser) parseVarStatement() *ast.VarStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Type = p.parseTypeAnnotation()
+		if stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		p.addError(
 			"SyntaxError",
@@ -676,6 +1108,95 @@ func (p *Parser) parseVarStatement() *ast.VarStatement {
 	return stmt
 }
 
+// parseFunctionDeclaration parses `fn name(params) { ... }` as sugar for
+// `let name = fn(params) { ... }`
+func (p *Parser) parseFunctionDeclaration() ast.Statement {
+	stmt := &ast.VarStatement{
+		Token: token.Token{
+			Type:    token.LET,
+			Literal: "let",
+			Line:    p.curToken.Line,
+			Column:  p.curToken.Column,
+		},
+		IsFnDecl: true,
+	}
+	fnToken := p.curToken
+	p.nextToken()
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.curToken = fnToken
+	lit := p.parseFunctionLiteral()
+	if lit == nil {
+		return nil
+	}
+	stmt.Value = lit
+	return stmt
+}
+
+// parseTypeAnnotation parses a type starting at curToken, leaving curToken
+// on its last token
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	first := p.parseTypeTerm()
+	if first == nil {
+		return nil
+	}
+	if !p.peekIsUnionBar() {
+		return first
+	}
+
+	union := &ast.TypeAnnotation{Token: first.Token, Union: []*ast.TypeAnnotation{first}}
+	for p.peekIsUnionBar() {
+		p.nextToken()
+		p.nextToken()
+		alt := p.parseTypeTerm()
+		if alt == nil {
+			return nil
+		}
+		union.Union = append(union.Union, alt)
+	}
+	p.markEnd(union)
+	return union
+}
+
+func (p *Parser) peekIsUnionBar() bool {
+	return p.peekTokenIs(token.OR) && p.peekToken.Literal == "|"
+}
+
+func (p *Parser) parseTypeTerm() *ast.TypeAnnotation {
+	if !p.curTokenIs(token.IDENT) && !p.curTokenIs(token.NULL) && !p.curTokenIs(token.FUNCTION) {
+		p.addError("SyntaxError", fmt.Sprintf("Expected type name, got %s", p.curToken.Literal))
+		return nil
+	}
+	ta := &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+
+	if p.peekTokenIs(token.LBRACKET) {
+		p.nextToken()
+		for {
+			p.nextToken()
+			param := p.parseTypeAnnotation()
+			if param == nil {
+				return nil
+			}
+			ta.Params = append(ta.Params, param)
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expectPeek(token.RBRACKET) {
+			p.addError("SyntaxError", "Missing closing bracket in type")
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(token.TRY) {
+		p.nextToken()
+		ta.Nullable = true
+	}
+	p.markEnd(ta)
+	return ta
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -690,6 +1211,9 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	if t == token.EOF {
+		p.incomplete = true
+	}
 	message := fmt.Sprintf("Unexpected token %s", t)
 	if p.curToken.Literal != "" && p.curToken.Literal != string(t) {
 		message += fmt.Sprintf("%s", p.curToken.Literal)
@@ -704,6 +1228,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 	leftExp := prefix()
+	p.markEnd(leftExp)
 	for precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
 		if infix == nil {
@@ -711,6 +1236,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		}
 		p.nextToken()
 		leftExp = infix(leftExp)
+		p.markEnd(leftExp)
 	}
 	return leftExp
 }
@@ -737,13 +1263,32 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression(left ast.Expression) ast.Expression {
+	return &ast.TryExpression{Token: p.curToken, Value: left}
+}
+
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		if !p.peekTokenIs(token.ARROW) {
+			p.addError("SyntaxError", "Expected '=>' after '()'")
+			return nil
+		}
+		return p.parseArrowFunction([]*ast.Identifier{})
+	}
+
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
+	exps := []ast.Expression{exp}
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		exps = append(exps, p.parseExpression(LOWEST))
+	}
 	if !p.expectPeek(token.RPAREN) {
 		p.addError(
 			"SyntaxError",
@@ -751,9 +1296,62 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 		)
 		return nil
 	}
+
+	if p.peekTokenIs(token.ARROW) {
+		params := []*ast.Identifier{}
+		for _, e := range exps {
+			ident, ok := e.(*ast.Identifier)
+			if !ok {
+				p.addError("SyntaxError", "Arrow function parameters must be identifiers")
+				return nil
+			}
+			params = append(params, ident)
+		}
+		return p.parseArrowFunction(params)
+	}
+
+	if len(exps) > 1 {
+		p.addError("SyntaxError", "Unexpected ',' in parenthesized expression")
+		return nil
+	}
 	return exp
 }
 
+// parseArrowFunction parses `params => expr` or `params => { ... }` with
+// curToken on the last token before '=>'. An expression body is wrapped in
+// an implicit return so the result evaluates like a regular fn literal.
+func (p *Parser) parseArrowFunction(params []*ast.Identifier) ast.Expression {
+	p.nextToken()
+	p.functionDepth++
+	defer func() { p.functionDepth-- }()
+	lit := &ast.FunctionLiteral{Token: p.curToken, Parameters: params}
+
+	p.nextToken()
+	if p.curTokenIs(token.LBRACE) {
+		lit.Body = p.parseBlockStatement()
+		return lit
+	}
+
+	returnToken := token.Token{
+		Type:    token.RETURN,
+		Literal: "return",
+		Line:    p.curToken.Line,
+		Column:  p.curToken.Column,
+	}
+	value := p.parseExpression(LOWEST)
+	if value == nil {
+		p.addError("SyntaxError", "Expected expression after '=>'")
+		return nil
+	}
+	lit.Body = &ast.BlockStatement{
+		Token: lit.Token,
+		Statements: []ast.Statement{
+			&ast.ReturnStatement{Token: returnToken, Value: value},
+		},
+	}
+	return lit
+}
+
 func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 	p.nextToken()
@@ -791,6 +1389,11 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.EOF) {
+		p.incomplete = true
+		p.addError("SyntaxError", "Missing closing brace")
+	}
+	block.End = p.curToken
 	return block
 }
 
@@ -805,7 +1408,15 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		)
 		return nil
 	}
-	lit.Parameters = p.parseFunctionParameters()
+	lit.Parameters, lit.ParamTypes = p.parseFunctionParameters()
+	if p.peekTokenIs(token.THIN_ARROW) {
+		p.nextToken()
+		p.nextToken()
+		lit.ReturnType = p.parseTypeAnnotation()
+		if lit.ReturnType == nil {
+			return nil
+		}
+	}
 	if !p.expectPeek(token.LBRACE) {
 		p.addError(
 			"SyntaxError",
@@ -817,25 +1428,50 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters returns the parameters and their optional type
+// annotations; the types slice is nil when no parameter is annotated
+func (p *Parser) parseFunctionParameters() ([]*ast.Identifier, []*ast.TypeAnnotation) {
 	identifiers := []*ast.Identifier{}
+	types := []*ast.TypeAnnotation{}
+	annotated := false
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return identifiers, nil
+	}
+
+	parseParam := func() bool {
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		identifiers = append(identifiers, ident)
+		var ta *ast.TypeAnnotation
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			ta = p.parseTypeAnnotation()
+			if ta == nil {
+				return false
+			}
+			annotated = true
+		}
+		types = append(types, ta)
+		return true
 	}
+
 	p.nextToken()
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	if !parseParam() {
+		return nil, nil
+	}
 	if p.peekTokenIs(token.SPREAD) {
 		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 		identifiers = append(identifiers, ident)
+		types = append(types, nil)
 	} else {
 		for p.peekTokenIs(token.COMMA) {
 			p.nextToken()
 			p.nextToken()
-			ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-			identifiers = append(identifiers, ident)
+			if !parseParam() {
+				return nil, nil
+			}
 		}
 	}
 	if !p.expectPeek(token.RPAREN) {
@@ -843,9 +1479,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 			"SyntaxError",
 			"Missing closing parenthesis",
 		)
-		return nil
+		return nil, nil
 	}
-	return identifiers
+	if !annotated {
+		types = nil
+	}
+	return identifiers, types
 }
 
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
@@ -962,6 +1601,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			p.addError(
 				"SyntaxError",
@@ -1024,6 +1664,23 @@ func (p *Parser) parseSelf() ast.Expression {
 	return &ast.Self{Token: p.curToken}
 }
 
+func (p *Parser) parseSuper() ast.Expression {
+	if !p.peekTokenIs(token.DOT) {
+		p.addError("SyntaxError", "Expected '.' after 'super'")
+		return nil
+	}
+	return &ast.Super{Token: p.curToken}
+}
+
+// Precedence returns how tightly an infix operator token binds, from
+// LOWEST upwards
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
+		return p
+	}
+	return LOWEST
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// Field numbers of the pprof profile.proto messages written below
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// protobuf appends protocol buffer wire format to a byte slice
+type protobuf []byte
+
+func (b *protobuf) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *protobuf) key(field, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, 0)
+	b.varint(uint64(v))
+}
+
+func (b *protobuf) bytes(field int, v []byte) {
+	b.key(field, 2)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *protobuf) packed(field int, vs []int64) {
+	var inner protobuf
+	for _, v := range vs {
+		inner.varint(uint64(v))
+	}
+	b.bytes(field, inner)
+}
+
+// WritePprof writes the profile in pprof's gzipped protocol buffer format,
+// with each call stack's self time and allocations as a sample, for go
+// tool pprof and the many viewers that read it
+func (p *Profiler) WritePprof(w io.Writer) error {
+	table := []string{""}
+	index := map[string]int64{"": 0}
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		index[s] = int64(len(table))
+		table = append(table, s)
+		return index[s]
+	}
+
+	var out protobuf
+	valueType := func(field int, typ, unit string) {
+		var vt protobuf
+		vt.int(valueTypeType, str(typ))
+		vt.int(valueTypeUnit, str(unit))
+		out.bytes(field, vt)
+	}
+	valueType(profileSampleType, "time", "nanoseconds")
+	valueType(profileSampleType, "alloc_objects", "count")
+
+	// Each function is also its own location, so their IDs are shared
+	ids := map[*Stats]int64{}
+	functions := p.Functions()
+	for i, s := range functions {
+		ids[s] = int64(i + 1)
+	}
+
+	stacks := []string{}
+	for stack := range p.samples {
+		stacks = append(stacks, stack)
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		s := p.samples[stack]
+		locations := make([]int64, len(s.stack))
+		for i, stats := range s.stack {
+			locations[len(locations)-1-i] = ids[stats] // innermost first
+		}
+		var sample protobuf
+		sample.packed(sampleLocationID, locations)
+		sample.packed(sampleValue, []int64{int64(s.time), int64(s.allocs)})
+		out.bytes(profileSample, sample)
+	}
+
+	for _, s := range functions {
+		var line protobuf
+		line.int(lineFunctionID, ids[s])
+		line.int(lineLine, int64(s.Line))
+		var location protobuf
+		location.int(locationID, ids[s])
+		location.bytes(locationLine, line)
+		out.bytes(profileLocation, location)
+
+		var function protobuf
+		function.int(functionID, ids[s])
+		function.int(functionName, str(s.Name))
+		function.int(functionSystemName, str(s.Name))
+		function.int(functionFilename, str(s.File))
+		function.int(functionStartLine, int64(s.Line))
+		out.bytes(profileFunction, function)
+	}
+
+	out.int(profileTimeNanos, p.started.UnixNano())
+	out.int(profileDurationNanos, int64(p.Duration))
+	valueType(profilePeriodType, "time", "nanoseconds")
+	out.int(profilePeriod, 1)
+	out.int(profileDefaultSampleType, str("time"))
+
+	// The string table is complete only now, and field order is free
+	for _, s := range table {
+		out.bytes(profileStringTable, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(out); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,267 @@
+// Package profile measures where a Lynx program spends its time. A
+// Profiler installed as the evaluator's Tracer times every call of a Lynx
+// function or builtin and reports, per function, how often it ran, the time
+// spent in it including and excluding its callees, and the Go heap objects
+// allocated meanwhile.
+package profile
+
+import (
+	"fmt"
+	"io"
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/object"
+	"path/filepath"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Site identifies a profiled function by name and the position of its
+// body. Builtins have no file.
+type Site struct {
+	Name string
+	File string
+	Line int
+}
+
+func (s Site) String() string {
+	if s.File == "" {
+		return s.Name
+	}
+	return fmt.Sprintf("%s (%s:%d)", s.Name, filepath.Base(s.File), s.Line)
+}
+
+// Stats are the totals for one function. Cumulative figures include the
+// function's callees and count a recursive call once, at its outermost
+// level; self figures exclude callees. Allocations are Go heap objects,
+// which the runtime counts in batches, so small functions may show zero.
+type Stats struct {
+	Site
+	Builtin    bool
+	Calls      int
+	Cumulative time.Duration
+	Self       time.Duration
+	Allocs     uint64
+	SelfAllocs uint64
+}
+
+// frame is a call in progress
+type frame struct {
+	stats       *Stats
+	stack       string // folded names of the calls leading here
+	start       time.Time
+	allocs      uint64
+	child       time.Duration
+	childAllocs uint64
+}
+
+// sample is the self cost of one distinct call stack
+type sample struct {
+	stack  []*Stats // outermost first
+	time   time.Duration
+	allocs uint64
+}
+
+// Profiler records calls between Start and Stop
+type Profiler struct {
+	stats   map[Site]*Stats
+	active  map[*Stats]int // calls of each function in progress
+	frames  []*frame
+	samples map[string]*sample
+	metrics []metrics.Sample
+
+	started  time.Time
+	Duration time.Duration
+}
+
+func New() *Profiler {
+	return &Profiler{
+		stats:   make(map[Site]*Stats),
+		active:  make(map[*Stats]int),
+		samples: make(map[string]*sample),
+		metrics: []metrics.Sample{{Name: "/gc/heap/allocs:objects"}},
+	}
+}
+
+// Start installs the profiler and opens the frame of the program's top
+// level, named main, in file
+func (p *Profiler) Start(file string) {
+	p.push(Site{Name: "main", File: file, Line: 1}, false)
+	p.started = p.frames[0].start
+	evaluator.Trace = p
+}
+
+// Stop uninstalls the profiler, closing any frames still open
+func (p *Profiler) Stop() {
+	if evaluator.Trace == p {
+		evaluator.Trace = nil
+	}
+	for len(p.frames) > 0 {
+		p.pop()
+	}
+	p.Duration = time.Since(p.started)
+}
+
+// allocated reads the runtime's running count of heap objects. The runtime
+// updates it as allocation caches are refilled rather than per object, so
+// small functions may see none of their allocations.
+func (p *Profiler) allocated() uint64 {
+	metrics.Read(p.metrics)
+	if p.metrics[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return p.metrics[0].Value.Uint64()
+}
+
+func (p *Profiler) push(site Site, builtin bool) {
+	stats, ok := p.stats[site]
+	if !ok {
+		stats = &Stats{Site: site, Builtin: builtin}
+		p.stats[site] = stats
+	}
+	stats.Calls++
+	p.active[stats]++
+
+	stack := site.String()
+	if len(p.frames) > 0 {
+		stack = p.frames[len(p.frames)-1].stack + ";" + stack
+	}
+	p.frames = append(p.frames, &frame{stats: stats, stack: stack, allocs: p.allocated(), start: time.Now()})
+}
+
+func (p *Profiler) pop() {
+	now := time.Now()
+	allocs := p.allocated()
+	f := p.frames[len(p.frames)-1]
+	p.frames = p.frames[:len(p.frames)-1]
+
+	elapsed := now.Sub(f.start)
+	allocated := allocs - f.allocs
+	self := max(elapsed-f.child, 0)
+	selfAllocs := allocated - min(f.childAllocs, allocated)
+
+	stats := f.stats
+	stats.Self += self
+	stats.SelfAllocs += selfAllocs
+	p.active[stats]--
+	if p.active[stats] == 0 {
+		stats.Cumulative += elapsed
+		stats.Allocs += allocated
+	}
+
+	if len(p.frames) > 0 {
+		parent := p.frames[len(p.frames)-1]
+		parent.child += elapsed
+		parent.childAllocs += allocated
+	}
+
+	s, ok := p.samples[f.stack]
+	if !ok {
+		s = &sample{}
+		for _, frame := range p.frames {
+			s.stack = append(s.stack, frame.stats)
+		}
+		s.stack = append(s.stack, stats)
+		p.samples[f.stack] = s
+	}
+	s.time += self
+	s.allocs += selfAllocs
+}
+
+// Statement implements evaluator.Tracer
+func (p *Profiler) Statement(stmt ast.Statement, env *object.Env) {}
+
+// Call implements evaluator.Tracer
+func (p *Profiler) Call(fn *object.Function, env *object.Env) {
+	p.push(Site{Name: evaluator.CallName(fn), File: fn.Env.File, Line: fn.Body.Token.Line}, false)
+}
+
+// Return implements evaluator.Tracer
+func (p *Profiler) Return(fn *object.Function, result object.Object) {
+	p.pop()
+}
+
+// Load implements evaluator.Tracer. A module's top level is profiled as a
+// function of its own.
+func (p *Profiler) Load(path string, env *object.Env) {
+	name := "<module " + strings.TrimSuffix(filepath.Base(path), ".lynx") + ">"
+	p.push(Site{Name: name, File: path, Line: 1}, false)
+}
+
+// Loaded implements evaluator.Tracer
+func (p *Profiler) Loaded(path string) {
+	p.pop()
+}
+
+// CallBuiltin implements evaluator.Tracer
+func (p *Profiler) CallBuiltin(name string) {
+	p.push(Site{Name: name}, true)
+}
+
+// ReturnBuiltin implements evaluator.Tracer
+func (p *Profiler) ReturnBuiltin(name string) {
+	p.pop()
+}
+
+// Branch implements evaluator.Tracer
+func (p *Profiler) Branch(node ast.Node, taken int, env *object.Env) {}
+
+// Functions returns the totals for every function called, by descending
+// self time
+func (p *Profiler) Functions() []*Stats {
+	functions := []*Stats{}
+	for _, stats := range p.stats {
+		functions = append(functions, stats)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		a, b := functions[i], functions[j]
+		if a.Self != b.Self {
+			return a.Self > b.Self
+		}
+		if a.Calls != b.Calls {
+			return a.Calls > b.Calls
+		}
+		return a.Site.String() < b.Site.String()
+	})
+	return functions
+}
+
+// WriteReport writes a table of the functions by descending self time
+func (p *Profiler) WriteReport(w io.Writer) {
+	total := p.Duration
+	if total <= 0 {
+		total = 1
+	}
+	percent := func(d time.Duration) float64 { return 100 * float64(d) / float64(total) }
+
+	fmt.Fprintf(w, "Profile: %s total\n\n", p.Duration.Round(time.Microsecond))
+	fmt.Fprintf(w, "%10s %12s %6s %12s %6s %10s %10s  %s\n",
+		"calls", "self", "self%", "cumulative", "cum%", "allocs", "self allocs", "function")
+	for _, s := range p.Functions() {
+		fmt.Fprintf(w, "%10d %12s %5.1f%% %12s %5.1f%% %10d %10d  %s\n",
+			s.Calls, s.Self.Round(time.Microsecond), percent(s.Self),
+			s.Cumulative.Round(time.Microsecond), percent(s.Cumulative),
+			s.Allocs, s.SelfAllocs, s.Site)
+	}
+}
+
+// WriteFolded writes the folded stacks flame graph tools read: a line per
+// distinct call stack, its functions separated by semicolons, followed by
+// the microseconds spent in its innermost function
+func (p *Profiler) WriteFolded(w io.Writer) error {
+	stacks := []string{}
+	for stack, s := range p.samples {
+		if s.time >= time.Microsecond {
+			stacks = append(stacks, stack)
+		}
+	}
+	sort.Strings(stacks)
+	for _, stack := range stacks {
+		if _, err := fmt.Fprintf(w, "%s %d\n", stack, p.samples[stack].time.Microseconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+// Package project reads and updates Lynx project manifests (lynx.toml) and
+// their lock files, and resolves the module roots a project contributes
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	ManifestFile = "lynx.toml"
+	LockFile     = "lynx.lock"
+	VendorDir    = "vendor"
+)
+
+// Manifest describes a project: its entrypoint, extra module search roots,
+// named dependencies and lint settings. Paths are relative to Dir.
+type Manifest struct {
+	Dir          string
+	Name         string
+	Entry        string
+	Roots        []string
+	Dependencies map[string]string // name -> local directory
+	Lint         map[string]string // rule ID -> severity
+}
+
+// LockEntry pins a dependency to the content it had when locked
+type LockEntry struct {
+	Path string
+	Hash string
+}
+
+// New returns a manifest for a fresh project in dir
+func New(dir string) *Manifest {
+	return &Manifest{
+		Dir:          dir,
+		Name:         filepath.Base(dir),
+		Entry:        "main.lynx",
+		Roots:        []string{},
+		Dependencies: make(map[string]string),
+		Lint:         make(map[string]string),
+	}
+}
+
+// Load reads the manifest in dir
+func Load(dir string) (*Manifest, error) {
+	source, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseTOML(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Join(dir, ManifestFile), err)
+	}
+
+	m := New(dir)
+	project := doc["project"]
+	if name, ok := project["name"].(string); ok {
+		m.Name = name
+	}
+	if entry, ok := project["entry"].(string); ok {
+		m.Entry = entry
+	}
+	if roots, ok := project["roots"].([]string); ok {
+		m.Roots = roots
+	}
+	for name, value := range doc["dependencies"] {
+		path, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: dependency %s must be a path string", ManifestFile, name)
+		}
+		m.Dependencies[name] = path
+	}
+	for rule, value := range doc["lint"] {
+		severity, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: lint rule %s must be a severity string", ManifestFile, rule)
+		}
+		m.Lint[rule] = severity
+	}
+	return m, nil
+}
+
+// Find loads the manifest in dir or the nearest parent directory that has
+// one. It returns nil without an error when there is none.
+func Find(dir string) (*Manifest, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ManifestFile)); err == nil {
+			return Load(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Save writes the manifest to lynx.toml. An existing file is updated in
+// place, so comments and formatting the user added are kept.
+func (m *Manifest) Save() error {
+	deps := table{}
+	for name, path := range m.Dependencies {
+		deps[name] = path
+	}
+	lint := table{}
+	for rule, severity := range m.Lint {
+		lint[rule] = severity
+	}
+	sections := []section{
+		{"project", table{"name": m.Name, "entry": m.Entry, "roots": m.Roots}, []string{"name", "entry", "roots"}},
+		{"dependencies", deps, nil},
+		{"lint", lint, nil},
+	}
+
+	path := filepath.Join(m.Dir, ManifestFile)
+	source, err := os.ReadFile(path)
+	if err == nil {
+		return os.WriteFile(path, []byte(updateTOML(string(source), sections)), 0o644)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	var out strings.Builder
+	for i, sec := range sections {
+		// A new manifest lists its dependencies, if only to show where
+		if len(sec.table) == 0 && sec.name != "dependencies" {
+			continue
+		}
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		writeTable(&out, sec.name, sec.table, sec.order)
+	}
+	return os.WriteFile(path, []byte(out.String()), 0o644)
+}
+
+// EntryPath returns the absolute path of the project's entrypoint
+func (m *Manifest) EntryPath() string {
+	return m.resolve(m.Entry)
+}
+
+// DependencyDir returns where a dependency's modules are loaded from: its
+// vendored copy when one exists, otherwise the declared path
+func (m *Manifest) DependencyDir(name string) string {
+	vendored := filepath.Join(m.Dir, VendorDir, name)
+	if info, err := os.Stat(vendored); err == nil && info.IsDir() {
+		return vendored
+	}
+	return m.resolve(m.Dependencies[name])
+}
+
+// ModuleRoots lists the directories the project adds to module resolution:
+// its roots in declared order, then its dependencies sorted by name
+func (m *Manifest) ModuleRoots() []string {
+	roots := []string{}
+	for _, root := range m.Roots {
+		roots = append(roots, m.resolve(root))
+	}
+	for _, name := range m.dependencyNames() {
+		roots = append(roots, m.DependencyDir(name))
+	}
+	return roots
+}
+
+// Add declares a dependency on the directory at path and relocks
+func (m *Manifest) Add(name, path string) error {
+	// The name is also the dependency's directory under vendor/
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid dependency name %q", name)
+	}
+	abs := m.resolve(path)
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return fmt.Errorf("dependency %s: %s is not a directory", name, path)
+	}
+	if rel, err := filepath.Rel(m.Dir, abs); err == nil {
+		path = filepath.ToSlash(rel)
+	}
+	m.Dependencies[name] = path
+	if err := m.Save(); err != nil {
+		return err
+	}
+	return m.WriteLock()
+}
+
+// Remove drops a dependency along with any vendored copy and relocks
+func (m *Manifest) Remove(name string) error {
+	if _, ok := m.Dependencies[name]; !ok {
+		return fmt.Errorf("%s is not a dependency", name)
+	}
+	delete(m.Dependencies, name)
+	vendor := filepath.Join(m.Dir, VendorDir)
+	if err := os.RemoveAll(filepath.Join(vendor, name)); err != nil {
+		return err
+	}
+	// Drop vendor/ itself once nothing is left in it
+	if entries, err := os.ReadDir(vendor); err == nil && len(entries) == 0 {
+		if err := os.Remove(vendor); err != nil {
+			return err
+		}
+	}
+	if err := m.Save(); err != nil {
+		return err
+	}
+	return m.WriteLock()
+}
+
+// Vendor copies every dependency's declared directory into vendor/, so the
+// project no longer needs the originals, and relocks
+func (m *Manifest) Vendor() error {
+	for _, name := range m.dependencyNames() {
+		dst := filepath.Join(m.Dir, VendorDir, name)
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+		if err := copyDir(m.resolve(m.Dependencies[name]), dst); err != nil {
+			return fmt.Errorf("vendoring %s: %v", name, err)
+		}
+	}
+	return m.WriteLock()
+}
+
+// Lock computes the lock entries for the current dependencies
+func (m *Manifest) Lock() (map[string]LockEntry, error) {
+	lock := make(map[string]LockEntry)
+	for _, name := range m.dependencyNames() {
+		hash, err := HashDir(m.DependencyDir(name))
+		if err != nil {
+			return nil, fmt.Errorf("hashing %s: %v", name, err)
+		}
+		lock[name] = LockEntry{Path: m.Dependencies[name], Hash: hash}
+	}
+	return lock, nil
+}
+
+// WriteLock records each dependency's content hash in lynx.lock
+func (m *Manifest) WriteLock() error {
+	lock, err := m.Lock()
+	if err != nil {
+		return err
+	}
+
+	var out strings.Builder
+	out.WriteString("# Generated by lynx. Do not edit.\n")
+	for _, name := range m.dependencyNames() {
+		out.WriteString("\n")
+		writeTable(&out, name, table{
+			"path": lock[name].Path,
+			"hash": lock[name].Hash,
+		}, []string{"path", "hash"})
+	}
+	return os.WriteFile(filepath.Join(m.Dir, LockFile), []byte(out.String()), 0o644)
+}
+
+// ReadLock returns the entries recorded in lynx.lock
+func (m *Manifest) ReadLock() (map[string]LockEntry, error) {
+	source, err := os.ReadFile(filepath.Join(m.Dir, LockFile))
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parseTOML(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", LockFile, err)
+	}
+
+	lock := make(map[string]LockEntry)
+	for name, t := range doc {
+		if name == "" {
+			continue
+		}
+		path, _ := t["path"].(string)
+		hash, _ := t["hash"].(string)
+		lock[name] = LockEntry{Path: path, Hash: hash}
+	}
+	return lock, nil
+}
+
+// StaleDependencies lists the locked dependencies whose content no longer
+// matches lynx.lock. Projects without a lock file have none.
+func (m *Manifest) StaleDependencies() ([]string, error) {
+	locked, err := m.ReadLock()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	current, err := m.Lock()
+	if err != nil {
+		return nil, err
+	}
+
+	stale := []string{}
+	for _, name := range m.dependencyNames() {
+		if locked[name].Hash != current[name].Hash {
+			stale = append(stale, name)
+		}
+	}
+	return stale, nil
+}
+
+// HashDir returns a sha256 over the relative paths and contents of the
+// .lynx files under dir, independent of walk order and file times
+func HashDir(dir string) (string, error) {
+	files := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && strings.HasSuffix(path, ".lynx") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, path := range files {
+		rel, _ := filepath.Rel(dir, path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+		h.Write(data)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *Manifest) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.Dir, filepath.FromSlash(path))
+}
+
+func (m *Manifest) dependencyNames() []string {
+	names := []string{}
+	for name := range m.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// copyDir copies the .lynx files under src into dst, keeping their layout
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".lynx") {
+			return nil
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,272 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// table is one [section] of a TOML document; values are strings or string
+// arrays, the only kinds lynx.toml and lynx.lock use
+type table map[string]any
+
+// parseTOML reads the subset of TOML used by project files: [section]
+// headers, comments, and keys set to strings or arrays of strings
+func parseTOML(source string) (map[string]table, error) {
+	doc := map[string]table{"": {}}
+	section := ""
+
+	for i, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(stripComment(line))
+		if line == "" {
+			continue
+		}
+		lineNo := i + 1
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			name, err := unquoteKey(section)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			section = name
+			if _, ok := doc[section]; !ok {
+				doc[section] = table{}
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		name, err := unquoteKey(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		parsed, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		doc[section][name] = parsed
+	}
+
+	return doc, nil
+}
+
+// stripComment removes a trailing # comment that is not inside a string
+func stripComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func unquoteKey(key string) (string, error) {
+	if strings.HasPrefix(key, "\"") {
+		return strconv.Unquote(key)
+	}
+	if key == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	return key, nil
+}
+
+func parseValue(value string) (any, error) {
+	if strings.HasPrefix(value, "\"") {
+		return strconv.Unquote(value)
+	}
+	if strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]") {
+		items := []string{}
+		inner := strings.TrimSpace(value[1 : len(value)-1])
+		for inner != "" {
+			if !strings.HasPrefix(inner, "\"") {
+				return nil, fmt.Errorf("arrays may only contain strings")
+			}
+			end := closingQuote(inner)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			item, err := strconv.Unquote(inner[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			inner = strings.TrimSpace(inner[end+1:])
+			inner = strings.TrimSpace(strings.TrimPrefix(inner, ","))
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", value)
+}
+
+// closingQuote returns the index of the quote ending the string at s[0]
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// writeTable renders a section with its keys in sorted order
+func writeTable(out *strings.Builder, name string, t table, order []string) {
+	if name != "" {
+		fmt.Fprintf(out, "[%s]\n", formatKey(name))
+	}
+	for _, key := range tableKeys(t, order) {
+		fmt.Fprintf(out, "%s = %s\n", formatKey(key), formatValue(t[key]))
+	}
+}
+
+// tableKeys lists the keys of t that are in order, or all of them sorted
+// when order is nil
+func tableKeys(t table, order []string) []string {
+	keys := []string{}
+	if order == nil {
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	for _, key := range order {
+		if _, ok := t[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []string:
+		quoted := []string{}
+		for _, item := range v {
+			quoted = append(quoted, strconv.Quote(item))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return ""
+}
+
+// section is a table to store in a document by updateTOML
+type section struct {
+	name  string
+	table table
+	order []string // key order, sorted when nil
+}
+
+// updateTOML changes source so the given sections hold exactly their
+// tables, keeping comments, blank lines, other sections and the lines of
+// unchanged keys as written. A changed key keeps its trailing comment, new
+// keys follow the last key of their section, and sections source lacks are
+// appended when they have keys. source must parse.
+func updateTOML(source string, sections []section) string {
+	managed := map[string]section{}
+	for _, sec := range sections {
+		managed[sec.name] = sec
+	}
+	seen := map[string]map[string]bool{}
+	insertAt := map[string]int{} // output index new keys of a section go before
+	out := []string{}
+	current := ""
+
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	for _, line := range lines {
+		code := stripComment(line)
+		trimmed := strings.TrimSpace(code)
+		switch {
+		case strings.HasPrefix(trimmed, "["):
+			current, _ = unquoteKey(strings.TrimSpace(trimmed[1 : len(trimmed)-1]))
+			out = append(out, line)
+			if seen[current] == nil {
+				seen[current] = map[string]bool{}
+			}
+			insertAt[current] = len(out)
+			continue
+		case trimmed == "":
+			out = append(out, line)
+			continue
+		}
+
+		sec, ok := managed[current]
+		if !ok {
+			out = append(out, line)
+			continue
+		}
+		key, value, _ := strings.Cut(trimmed, "=")
+		name, _ := unquoteKey(strings.TrimSpace(key))
+		want, keep := sec.table[name]
+		if !keep || seen[current][name] {
+			continue
+		}
+		seen[current][name] = true
+		if old, _ := parseValue(strings.TrimSpace(value)); formatValue(old) != formatValue(want) {
+			padding := code[len(strings.TrimRight(code, " \t")):]
+			line = formatKey(name) + " = " + formatValue(want) + padding + line[len(code):]
+		}
+		out = append(out, line)
+		insertAt[current] = len(out)
+	}
+
+	// Insert new keys from the last section up, so earlier indexes hold
+	inserts := []section{}
+	for _, sec := range sections {
+		if _, ok := insertAt[sec.name]; ok {
+			inserts = append(inserts, sec)
+		}
+	}
+	sort.Slice(inserts, func(i, j int) bool { return insertAt[inserts[i].name] > insertAt[inserts[j].name] })
+	for _, sec := range inserts {
+		added := []string{}
+		for _, key := range tableKeys(sec.table, sec.order) {
+			if !seen[sec.name][key] {
+				added = append(added, formatKey(key)+" = "+formatValue(sec.table[key]))
+			}
+		}
+		at := insertAt[sec.name]
+		out = append(out[:at], append(added, out[at:]...)...)
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.Join(out, "\n") + "\n")
+	for _, sec := range sections {
+		if _, ok := insertAt[sec.name]; !ok && len(sec.table) > 0 {
+			b.WriteString("\n")
+			writeTable(&b, sec.name, sec.table, sec.order)
+		}
+	}
+	return b.String()
+}
+
+func formatKey(key string) string {
+	for _, r := range key {
+		if !(r == '_' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9') {
+			return strconv.Quote(key)
+		}
+	}
+	return key
+}
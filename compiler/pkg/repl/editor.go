@@ -0,0 +1,245 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned by readLine when the user presses Ctrl-C
+var errInterrupted = errors.New("interrupted")
+
+// maxHistory bounds the number of lines kept in the history file
+const maxHistory = 1000
+
+// editor reads input lines. On a terminal it supports cursor movement,
+// Emacs-style editing keys and history; otherwise it reads plain lines and
+// prints no prompts.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       uintptr
+	terminal bool
+
+	history     []string
+	historyFile string
+}
+
+func newEditor(in io.Reader, out io.Writer) *editor {
+	e := &editor{in: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		if _, ok := out.(*os.File); ok {
+			e.fd = f.Fd()
+			e.terminal = true
+		}
+	}
+	return e
+}
+
+// loadHistory reads previous lines from path and appends new ones to it
+func (e *editor) loadHistory(path string) {
+	e.historyFile = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+		os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600)
+	}
+	for _, line := range lines {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+}
+
+func (e *editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if n := len(e.history); n > 0 && e.history[n-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func (e *editor) readLine(prompt string) (string, error) {
+	if !e.terminal {
+		return e.readPlainLine()
+	}
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		fmt.Fprint(e.out, prompt)
+		return e.readPlainLine()
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+func (e *editor) readPlainLine() (string, error) {
+	line, err := e.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// edit runs the line editor until Enter, Ctrl-C or Ctrl-D on an empty line
+func (e *editor) edit(prompt string) (string, error) {
+	buf := []rune{}
+	pos := 0
+	// Browsing history edits a copy; index len(e.history) is the new line
+	entries := append(append([]string{}, e.history...), "")
+	index := len(entries) - 1
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	recall := func(i int) {
+		entries[index] = string(buf)
+		index = i
+		buf = []rune(entries[index])
+		pos = len(buf)
+	}
+
+	refresh()
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf = buf[pos:]
+			pos = 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			buf = append(buf[:start], buf[pos:]...)
+			pos = start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			if index > 0 {
+				recall(index - 1)
+			}
+		case 14: // Ctrl-N
+			if index < len(entries)-1 {
+				recall(index + 1)
+			}
+		case '\t':
+			buf = append(buf[:pos], append([]rune("    "), buf[pos:]...)...)
+			pos += 4
+		case 27: // Escape sequences for arrows, Home, End and Delete
+			switch e.readEscape() {
+			case "A":
+				if index > 0 {
+					recall(index - 1)
+				}
+			case "B":
+				if index < len(entries)-1 {
+					recall(index + 1)
+				}
+			case "C":
+				if pos < len(buf) {
+					pos++
+				}
+			case "D":
+				if pos > 0 {
+					pos--
+				}
+			case "H", "1~", "7~":
+				pos = 0
+			case "F", "4~", "8~":
+				pos = len(buf)
+			case "3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(r) {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		refresh()
+	}
+}
+
+// readEscape reads the rest of an escape sequence after ESC and returns its
+// final part, such as "A" for ESC [ A or "3~" for ESC [ 3 ~
+func (e *editor) readEscape() string {
+	introducer, _, err := e.in.ReadRune()
+	if err != nil || (introducer != '[' && introducer != 'O') {
+		return ""
+	}
+	seq := []rune{}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return ""
+		}
+		seq = append(seq, r)
+		if r >= 'A' && r <= 'Z' || r == '~' {
+			return string(seq)
+		}
+	}
+}
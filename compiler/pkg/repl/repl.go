@@ -0,0 +1,275 @@
+// Package repl implements the interactive Lynx shell: it evaluates each
+// input in one environment that persists across inputs, continues lines
+// until the parser has a complete program, and offers :meta commands
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"lynx/pkg/ast"
+	"lynx/pkg/dump"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt             = ">>> "
+	continuationPrompt = "... "
+)
+
+// REPL holds the state of an interactive session
+type REPL struct {
+	Env *object.Env
+	Dir string // directory imports are resolved against
+
+	editor *editor
+	out    io.Writer
+	done   bool
+}
+
+type metaCommand struct {
+	args    string
+	summary string
+	run     func(r *REPL, arg string)
+}
+
+var metaCommands map[string]metaCommand
+
+func init() {
+	metaCommands = map[string]metaCommand{
+		"help":  {"", "show this help", (*REPL).help},
+		"load":  {"<file>", "run a file in the session", (*REPL).load},
+		"env":   {"", "list the session's bindings", (*REPL).listEnv},
+		"type":  {"<expr>", "show the runtime type of an expression", (*REPL).showType},
+		"ast":   {"<code>", "show the syntax tree of code", (*REPL).showAST},
+		"reset": {"", "clear all bindings and loaded modules", (*REPL).reset},
+		"quit":  {"", "leave the REPL (also Ctrl-D)", func(r *REPL, arg string) { r.done = true }},
+	}
+}
+
+// New creates a session reading from in and writing to out whose imports
+// resolve against dir
+func New(in io.Reader, out io.Writer, dir string) *REPL {
+	evaluator.RegisterBuiltins()
+	return &REPL{
+		Env:    object.New(dir),
+		Dir:    dir,
+		editor: newEditor(in, out),
+		out:    out,
+	}
+}
+
+// HistoryFile returns where input history is kept: $LYNX_HISTORY, or
+// .lynx_history in the home directory
+func HistoryFile() string {
+	if path := os.Getenv("LYNX_HISTORY"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".lynx_history")
+}
+
+// LoadHistory enables persistent history in path
+func (r *REPL) LoadHistory(path string) {
+	if path != "" {
+		r.editor.loadHistory(path)
+	}
+}
+
+// Run reads and evaluates inputs until end of input or :quit
+func (r *REPL) Run() error {
+	if r.editor.terminal {
+		fmt.Fprintln(r.out, "Lynx REPL. Type :help for commands, Ctrl-D to exit.")
+	}
+
+	lines := []string{}
+	for !r.done {
+		p := prompt
+		if len(lines) > 0 {
+			p = continuationPrompt
+		}
+		line, err := r.editor.readLine(p)
+		if errors.Is(err, errInterrupted) {
+			lines = lines[:0]
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		r.editor.addHistory(line)
+
+		if len(lines) == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			r.meta(strings.TrimSpace(line)[1:])
+			continue
+		}
+
+		lines = append(lines, line)
+		source := strings.Join(lines, "\n")
+		if strings.TrimSpace(source) == "" {
+			lines = lines[:0]
+			continue
+		}
+
+		// A blank line submits the input even if the parser wants more,
+		// so a mistake can't leave the prompt waiting forever
+		program, p2 := parse(source)
+		if p2.Incomplete() && strings.TrimSpace(line) != "" {
+			continue
+		}
+		lines = lines[:0]
+
+		if len(p2.Errors()) > 0 {
+			r.printParseErrors(p2)
+			continue
+		}
+		r.print(evaluator.Eval(program, r.Env))
+	}
+	return nil
+}
+
+func parse(source string) (*ast.Program, *parser.Parser) {
+	p := parser.New(lexer.New(source))
+	return p.ParseProgram(), p
+}
+
+func (r *REPL) printParseErrors(p *parser.Parser) {
+	for _, err := range p.Errors() {
+		fmt.Fprintf(r.out, "Parser error: %s\n", err)
+	}
+}
+
+// print shows a result in source form; statements that produce no value
+// print nothing
+func (r *REPL) print(result object.Object) {
+	if ret, ok := result.(*object.Return); ok {
+		result = ret.Value
+	}
+	switch result := result.(type) {
+	case nil, *object.Null:
+	case *object.Error:
+		if result.Internal {
+			fmt.Fprintf(r.out, "Internal error (interpreter bug): %s\n", result.Message)
+			return
+		}
+		fmt.Fprintf(r.out, "Error: %s\n", result.Message)
+	default:
+		fmt.Fprintln(r.out, object.Repr(result))
+	}
+}
+
+func (r *REPL) meta(command string) {
+	name, arg, _ := strings.Cut(command, " ")
+	arg = strings.TrimSpace(arg)
+	switch name {
+	case "q", "exit":
+		name = "quit"
+	case "h", "?":
+		name = "help"
+	}
+	cmd, ok := metaCommands[name]
+	if !ok {
+		fmt.Fprintf(r.out, "Unknown command :%s; type :help for a list\n", name)
+		return
+	}
+	if cmd.args != "" && arg == "" {
+		fmt.Fprintf(r.out, "Usage: :%s %s\n", name, cmd.args)
+		return
+	}
+	cmd.run(r, arg)
+}
+
+func (r *REPL) help(arg string) {
+	names := []string{}
+	for name := range metaCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := metaCommands[name]
+		fmt.Fprintf(r.out, "  %-16s %s\n", strings.TrimSpace(":"+name+" "+cmd.args), cmd.summary)
+	}
+}
+
+// load evaluates a file in the session; its imports resolve relative to
+// the file while it runs
+func (r *REPL) load(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+		return
+	}
+	program, p := parse(string(source))
+	if len(p.Errors()) > 0 {
+		r.printParseErrors(p)
+		return
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		r.Env.Dir = filepath.Dir(abs)
+		defer func() { r.Env.Dir = r.Dir }()
+	}
+	if result, ok := evaluator.Eval(program, r.Env).(*object.Error); ok {
+		r.print(result)
+	}
+}
+
+func (r *REPL) listEnv(arg string) {
+	for _, name := range r.Env.Names() {
+		val, _ := r.Env.Get(name)
+		switch val.(type) {
+		case *object.Function, *object.Builtin, *object.Class, *object.Trait, *object.Module:
+			fmt.Fprintf(r.out, "%s: %s\n", name, evaluator.DescribeType(val))
+		default:
+			fmt.Fprintf(r.out, "%s = %s\n", name, object.Repr(val))
+		}
+	}
+}
+
+// showType evaluates expr in a scope of its own, so bindings it makes are
+// discarded, and prints the type of the value
+func (r *REPL) showType(expr string) {
+	program, p := parse(expr)
+	if len(p.Errors()) > 0 {
+		r.printParseErrors(p)
+		return
+	}
+	result := evaluator.Eval(program, r.Env.NewEnclosedEnv())
+	if err, ok := result.(*object.Error); ok {
+		r.print(err)
+		return
+	}
+	fmt.Fprintln(r.out, evaluator.DescribeType(result))
+}
+
+// showAST prints the tree of code as lynx ast does
+func (r *REPL) showAST(code string) {
+	p := parser.New(lexer.New(code))
+	p.RecordEnds()
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		r.printParseErrors(p)
+		return
+	}
+	if err := dump.WriteTree(r.out, dump.Tree(program, p.End)); err != nil {
+		fmt.Fprintf(r.out, "Error: %v\n", err)
+	}
+}
+
+func (r *REPL) reset(arg string) {
+	r.Env = object.New(r.Dir)
+	evaluator.ResetModules()
+	fmt.Fprintln(r.out, "Session reset")
+}
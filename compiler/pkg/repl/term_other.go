@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || freebsd)
+
+package repl
+
+import "errors"
+
+// Line editing needs raw terminal mode, which is only implemented for
+// Unix-like systems; elsewhere input is read a line at a time.
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
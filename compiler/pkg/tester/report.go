@@ -0,0 +1,231 @@
+package tester
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Location is where a test failed, or where it is defined if it passed or
+// failed before reaching its body
+func (r *Result) Location(display func(path string) string) string {
+	line := r.Line
+	if line == 0 {
+		line = r.Test.Line
+	}
+	return fmt.Sprintf("%s:%d", display(r.Suite.Path), line)
+}
+
+// Counts is how many tests passed, failed and raised errors
+type Counts struct {
+	Passed, Failed, Errors int
+}
+
+// Count tallies results by status
+func Count(results []*Result) Counts {
+	var c Counts
+	for _, r := range results {
+		switch r.Status {
+		case Pass:
+			c.Passed++
+		case Fail:
+			c.Failed++
+		case Error:
+			c.Errors++
+		}
+	}
+	return c
+}
+
+// OK reports whether every test passed
+func (c Counts) OK() bool {
+	return c.Failed == 0 && c.Errors == 0
+}
+
+// WriteText writes a line for each test under its file and, for those that
+// did not pass, why, the diff of a failed comparison and what the test
+// printed. Paths are shown through display.
+func WriteText(w io.Writer, results []*Result, display func(path string) string) {
+	var elapsed time.Duration
+	var suite *Suite
+	for _, r := range results {
+		if r.Suite != suite {
+			suite = r.Suite
+			fmt.Fprintln(w, display(suite.Path))
+		}
+		elapsed += r.Duration
+		fmt.Fprintf(w, "  %-5s %s (%s)\n", r.Status, r.Test.Name, formatDuration(r.Duration))
+		if r.Status == Pass {
+			continue
+		}
+		message := r.Message
+		if r.Status == Error {
+			message = r.Kind + ": " + message
+		}
+		fmt.Fprintf(w, "      %s: %s\n", r.Location(display), message)
+		writeIndented(w, r.Diff(), "        ")
+		if r.Output != "" {
+			fmt.Fprintln(w, "      output:")
+			writeIndented(w, r.Output, "        ")
+		}
+	}
+
+	c := Count(results)
+	fmt.Fprintf(w, "\n%d passed, %d failed, %d errors (%s)\n", c.Passed, c.Failed, c.Errors, formatDuration(elapsed))
+}
+
+func writeIndented(w io.Writer, text, indent string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintln(w, indent+line)
+	}
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+// WriteTAP writes the results in the Test Anything Protocol, version 13,
+// with a YAML block describing each test that did not pass
+func WriteTAP(w io.Writer, results []*Result, display func(path string) string) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, "TAP version 13")
+	fmt.Fprintf(out, "1..%d\n", len(results))
+	for i, r := range results {
+		status := "ok"
+		if r.Status != Pass {
+			status = "not ok"
+		}
+		fmt.Fprintf(out, "%s %d - %s %s\n", status, i+1, display(r.Suite.Path), r.Test.Name)
+		if r.Status == Pass {
+			continue
+		}
+
+		// Strings are quoted as JSON, which is valid YAML
+		fmt.Fprintln(out, "  ---")
+		fmt.Fprintf(out, "  message: %s\n", strconv.Quote(r.Message))
+		fmt.Fprintf(out, "  severity: %s\n", strings.ToLower(r.Status.String()))
+		fmt.Fprintf(out, "  type: %s\n", strconv.Quote(r.Kind))
+		fmt.Fprintf(out, "  at: %s\n", strconv.Quote(r.Location(display)))
+		if r.Expected != "" || r.Actual != "" {
+			fmt.Fprintf(out, "  expected: %s\n", strconv.Quote(r.Expected))
+			fmt.Fprintf(out, "  actual: %s\n", strconv.Quote(r.Actual))
+		}
+		if r.Output != "" {
+			fmt.Fprintf(out, "  output: %s\n", strconv.Quote(r.Output))
+		}
+		fmt.Fprintln(out, "  ...")
+	}
+	return out.Flush()
+}
+
+// JUnit XML, as read by CI servers
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      int           `xml:"line,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, a testsuite for each file
+func WriteJUnit(w io.Writer, results []*Result, display func(path string) string) error {
+	doc := junitSuites{}
+	var total time.Duration
+	suites := map[*Suite]int{}
+	durations := []time.Duration{}
+	for _, r := range results {
+		i, ok := suites[r.Suite]
+		if !ok {
+			i = len(doc.Suites)
+			suites[r.Suite] = i
+			doc.Suites = append(doc.Suites, junitSuite{Name: display(r.Suite.Path)})
+			durations = append(durations, 0)
+		}
+		s := &doc.Suites[i]
+
+		c := junitCase{
+			Name:      r.Test.Name,
+			ClassName: strings.TrimSuffix(s.Name, ".lynx"),
+			File:      s.Name,
+			Line:      r.Test.Line,
+			Time:      seconds(r.Duration),
+			SystemOut: r.Output,
+		}
+		if r.Status != Pass {
+			problem := &junitProblem{
+				Message: r.Message,
+				Type:    r.Kind,
+				Text:    r.Location(display) + "\n" + r.Diff(),
+			}
+			if r.Status == Fail {
+				c.Failure = problem
+				s.Failures++
+			} else {
+				c.Error = problem
+				s.Errors++
+			}
+		}
+		s.Cases = append(s.Cases, c)
+		s.Tests++
+		durations[i] += r.Duration
+		total += r.Duration
+	}
+
+	for i := range doc.Suites {
+		s := &doc.Suites[i]
+		s.Time = seconds(durations[i])
+		doc.Tests += s.Tests
+		doc.Failures += s.Failures
+		doc.Errors += s.Errors
+	}
+	doc.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
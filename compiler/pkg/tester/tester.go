@@ -0,0 +1,354 @@
+// Package tester runs tests written in Lynx. A test file is named
+// *_test.lynx and each of its top-level functions named test_* is a test,
+// run in isolation: the file is evaluated afresh, with modules reloaded,
+// before each test is called.
+package tester
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"lynx/pkg/project"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Test is one test function of a file
+type Test struct {
+	Name string
+	Line int
+}
+
+// Suite is a test file and its tests, in source order
+type Suite struct {
+	Path    string
+	Program *ast.Program
+	Tests   []Test
+}
+
+// Status is the outcome of a test
+type Status int
+
+const (
+	Pass  Status = iota
+	Fail         // an assertion failed
+	Error        // the test raised any other error
+)
+
+func (s Status) String() string {
+	switch s {
+	case Fail:
+		return "FAIL"
+	case Error:
+		return "ERROR"
+	}
+	return "PASS"
+}
+
+// Result is the outcome of running one test
+type Result struct {
+	Suite    *Suite
+	Test     Test
+	Status   Status
+	Kind     string // the type of the exception raised, if any
+	Message  string
+	Line     int    // the last line of the test file to run before it failed
+	Expected string // for a failed comparison, the values compared, as source
+	Actual   string
+	Output   string // what the test printed
+	Duration time.Duration
+}
+
+// Discover finds the test files among paths, searching directories
+// recursively but skipping hidden and vendored ones
+func Discover(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if file != path && (strings.HasPrefix(name, ".") || name == project.VendorDir) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(file, "_test.lynx") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Load parses a test file and finds its tests
+func Load(path string) (*Suite, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		messages := []string{}
+		for _, e := range p.Errors() {
+			messages = append(messages, e.String())
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+
+	s := &Suite{Path: path, Program: program}
+	for _, stmt := range program.Statements {
+		vs, ok := stmt.(*ast.VarStatement)
+		if !ok || !strings.HasPrefix(vs.Name.Value, "test_") {
+			continue
+		}
+		if _, ok := vs.Value.(*ast.FunctionLiteral); ok {
+			s.Tests = append(s.Tests, Test{Name: vs.Name.Value, Line: vs.Token.Line})
+		}
+	}
+	return s, nil
+}
+
+// Run runs the suite's tests whose names match filter, or all of them if
+// it is nil
+func (s *Suite) Run(filter *regexp.Regexp) []*Result {
+	results := []*Result{}
+	for _, test := range s.Tests {
+		if filter == nil || filter.MatchString(test.Name) {
+			results = append(results, s.run(test))
+		}
+	}
+	return results
+}
+
+// run evaluates the file in a fresh environment, then calls the test,
+// capturing what it prints
+func (s *Suite) run(test Test) *Result {
+	r := &Result{Suite: s, Test: test}
+	loc := &locator{file: s.Path, next: evaluator.Trace}
+	evaluator.Trace = loc
+	defer func() { evaluator.Trace = loc.next }()
+
+	stdout := os.Stdout
+	output := &bytes.Buffer{}
+	pr, pw, err := os.Pipe()
+	if err == nil {
+		os.Stdout = pw
+	}
+	copied := make(chan struct{})
+	go func() {
+		if pr != nil {
+			io.Copy(output, pr)
+			pr.Close()
+		}
+		close(copied)
+	}()
+
+	started := time.Now()
+	evaluator.ResetModules()
+	evaluator.RegisterBuiltins()
+	env := object.New(filepath.Dir(s.Path))
+	env.File = s.Path
+	result := evaluator.Eval(s.Program, env)
+	if !isError(result) {
+		call := &ast.CallExpression{Function: &ast.Identifier{Value: test.Name}}
+		result = evaluator.Eval(call, env)
+	}
+	r.Duration = time.Since(started)
+
+	if pw != nil {
+		os.Stdout = stdout
+		pw.Close()
+	}
+	<-copied
+	r.Output = output.String()
+
+	if err, ok := result.(*object.Error); ok {
+		r.fail(err)
+		r.Line = loc.line
+	}
+	return r
+}
+
+// fail records the error a test ended with. An AssertionError is a
+// failure, and anything else an error.
+func (r *Result) fail(err *object.Error) {
+	r.Status = Error
+	r.Message = err.Message
+	exc := err.Exception
+	if exc == nil {
+		r.Kind = "RuntimeError"
+		return
+	}
+	r.Kind = exc.Kind
+	r.Message = exc.Message
+	if exc.Kind != "AssertionError" {
+		return
+	}
+	r.Status = Fail
+	expected, hasExpected := exc.Fields["expected"]
+	actual, hasActual := exc.Fields["actual"]
+	if hasExpected && hasActual {
+		r.Expected = object.Repr(expected)
+		r.Actual = object.Repr(actual)
+		// Multi-line strings are compared line by line
+		e, eok := expected.(*object.String)
+		a, aok := actual.(*object.String)
+		if eok && aok && (strings.Contains(e.Value, "\n") || strings.Contains(a.Value, "\n")) {
+			r.Expected, r.Actual = e.Value, a.Value
+		}
+	}
+}
+
+// Diff shows how a failed comparison's actual value differs from the
+// expected one, line by line, or is empty if the failure compared nothing
+func (r *Result) Diff() string {
+	if r.Expected == "" && r.Actual == "" {
+		return ""
+	}
+	if !strings.Contains(r.Expected, "\n") && !strings.Contains(r.Actual, "\n") {
+		return fmt.Sprintf("expected: %s\nactual:   %s\n", r.Expected, r.Actual)
+	}
+	return "--- expected\n+++ actual\n" + diffLines(strings.Split(r.Expected, "\n"), strings.Split(r.Actual, "\n"))
+}
+
+// diffLines marks the lines only in a with - and only in b with +, keeping
+// their longest common subsequence
+func diffLines(a, b []string) string {
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+// locator is an evaluator.Tracer that remembers the last line of the test
+// file to run, which is where a failing test stopped. It passes everything
+// on to the tracer installed before it, so tests can be covered.
+type locator struct {
+	file string
+	line int
+	next evaluator.Tracer
+}
+
+// Statement implements evaluator.Tracer
+func (l *locator) Statement(stmt ast.Statement, env *object.Env) {
+	if env.File == l.file {
+		if line := ast.StartToken(stmt).Line; line > 0 {
+			l.line = line
+		}
+	}
+	if l.next != nil {
+		l.next.Statement(stmt, env)
+	}
+}
+
+// Call implements evaluator.Tracer
+func (l *locator) Call(fn *object.Function, env *object.Env) {
+	if l.next != nil {
+		l.next.Call(fn, env)
+	}
+}
+
+// Return implements evaluator.Tracer
+func (l *locator) Return(fn *object.Function, result object.Object) {
+	if l.next != nil {
+		l.next.Return(fn, result)
+	}
+}
+
+// Load implements evaluator.Tracer
+func (l *locator) Load(path string, env *object.Env) {
+	if l.next != nil {
+		l.next.Load(path, env)
+	}
+}
+
+// Loaded implements evaluator.Tracer
+func (l *locator) Loaded(path string) {
+	if l.next != nil {
+		l.next.Loaded(path)
+	}
+}
+
+// CallBuiltin implements evaluator.Tracer
+func (l *locator) CallBuiltin(name string) {
+	if l.next != nil {
+		l.next.CallBuiltin(name)
+	}
+}
+
+// ReturnBuiltin implements evaluator.Tracer
+func (l *locator) ReturnBuiltin(name string) {
+	if l.next != nil {
+		l.next.ReturnBuiltin(name)
+	}
+}
+
+// Branch implements evaluator.Tracer
+func (l *locator) Branch(node ast.Node, taken int, env *object.Env) {
+	if l.next != nil {
+		l.next.Branch(node, taken, env)
+	}
+}
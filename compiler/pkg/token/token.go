@@ -1,14 +1,29 @@
 package token
 
+import "sort"
+
 // Token type identifier
 type TokenType string
 
-// Token with source position info
+// Token with source position info. Lines and columns count from 1; the
+// token ends just before EndLine and EndColumn.
 type Token struct {
-	Type    TokenType
-	Literal string
-	Line    int
-	Column  int
+	Type      TokenType
+	Literal   string
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+// Comment is a // or /* */ comment the lexer skipped, kept so tools such as
+// the formatter can put it back. Trailing comments follow code on the same
+// line; the others stand on their own lines.
+type Comment struct {
+	Text     string
+	Line     int
+	Column   int
+	Trailing bool
 }
 
 // Token type constants
@@ -40,7 +55,11 @@ const (
 	LTE = "<="
 	GTE = ">="
 
-	PIPE = "|>"  // Pipeline operator
+	PIPE  = "|>" // Pipeline operator
+	ARROW = "=>" // Lambda shorthand
+	TRY   = "?"  // Result propagation
+
+	THIN_ARROW = "->" // Return type annotation
 
 	AND = "and" // Logical AND
 	OR  = "or"  // Logical OR
@@ -64,56 +83,80 @@ const (
 	DOT = "."
 
 	// Keywords
-	LET      = "LET"
-	CONST    = "CONST"
-	TRUE     = "TRUE"
-	FALSE    = "FALSE"
-	IF       = "IF"
-	ELSE     = "ELSE"
-	WHILE    = "WHILE"
-	RETURN   = "RETURN"
-	FOR      = "FOR"
-	IN       = "IN"
-	CONTINUE = "CONTINUE"
-	BREAK    = "BREAK"
-	FUNCTION = "FUNCTION"
-	AT       = "@"
-	SWITCH   = "SWITCH"
-	CASE     = "CASE"
-	DEFAULT  = "DEFAULT"
-	ON       = "ON"
-	CATCH    = "CATCH"
-	ERROR    = "ERROR"
-	CLASS    = "CLASS"
-	SELF     = "SELF"
+	LET        = "LET"
+	CONST      = "CONST"
+	TRUE       = "TRUE"
+	FALSE      = "FALSE"
+	IF         = "IF"
+	ELSE       = "ELSE"
+	WHILE      = "WHILE"
+	RETURN     = "RETURN"
+	FOR        = "FOR"
+	IN         = "IN"
+	CONTINUE   = "CONTINUE"
+	BREAK      = "BREAK"
+	FUNCTION   = "FUNCTION"
+	AT         = "@"
+	SWITCH     = "SWITCH"
+	CASE       = "CASE"
+	DEFAULT    = "DEFAULT"
+	ON         = "ON"
+	CATCH      = "CATCH"
+	FINALLY    = "FINALLY"
+	DEFER      = "DEFER"
+	ERROR      = "ERROR"
+	CLASS      = "CLASS"
+	SELF       = "SELF"
+	SUPER      = "SUPER"
+	STATIC     = "STATIC"
+	INSTANCEOF = "INSTANCEOF"
+	TRAIT      = "TRAIT"
+	IMPL       = "IMPL"
 )
 
 // Keyword lookup table
 var keywords = map[string]TokenType{
-	"fn":       FUNCTION,
-	"let":      LET,
-	"const":    CONST,
-	"true":     TRUE,
-	"false":    FALSE,
-	"if":       IF,
-	"else":     ELSE,
-	"return":   RETURN,
-	"for":      FOR,
-	"while":    WHILE,
-	"in":       IN,
-	"continue": CONTINUE,
-	"break":    BREAK,
-	"and":      AND,
-	"or":       OR,
-	"switch":   SWITCH,
-	"case":     CASE,
-	"default":  DEFAULT,
-	"on":       ON,
-	"catch":    CATCH,
-	"error":    ERROR,
-	"null":     NULL,
-	"class":    CLASS,
-	"self":     SELF,
+	"fn":         FUNCTION,
+	"let":        LET,
+	"const":      CONST,
+	"true":       TRUE,
+	"false":      FALSE,
+	"if":         IF,
+	"else":       ELSE,
+	"return":     RETURN,
+	"for":        FOR,
+	"while":      WHILE,
+	"in":         IN,
+	"continue":   CONTINUE,
+	"break":      BREAK,
+	"and":        AND,
+	"or":         OR,
+	"switch":     SWITCH,
+	"case":       CASE,
+	"default":    DEFAULT,
+	"on":         ON,
+	"catch":      CATCH,
+	"finally":    FINALLY,
+	"defer":      DEFER,
+	"error":      ERROR,
+	"null":       NULL,
+	"class":      CLASS,
+	"self":       SELF,
+	"super":      SUPER,
+	"static":     STATIC,
+	"instanceof": INSTANCEOF,
+	"trait":      TRAIT,
+	"impl":       IMPL,
+}
+
+// Keywords returns the reserved words, sorted
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
 }
 
 func LookupIdent(ident string) TokenType {
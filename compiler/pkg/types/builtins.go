@@ -0,0 +1,49 @@
+package types
+
+func variadic(ret *Type, params ...*Type) *Type {
+	return &Type{Kind: Func, Params: params, Variadic: AnyType, Return: ret}
+}
+
+var sized = UnionOf(StrType, ArrayOf(AnyType), HashOf(AnyType, AnyType), &Type{Kind: Instance, Class: anyClass})
+
+// anyClass stands in for "an instance of some class" in builtin signatures
+var anyClass = &ClassInfo{Name: "instance"}
+
+// builtinSignatures types the builtins whose behaviour is fixed, following
+// the argument checks of their implementations in package evaluator.
+// Builtins missing here are treated as functions of unknown signature.
+var builtinSignatures = map[string]*Type{
+	"println":    variadic(NullType),
+	"len":        FuncOf([]*Type{sized}, IntType),
+	"range":      FuncOf([]*Type{IntType, IntType}, ArrayOf(IntType)),
+	"random":     FuncOf([]*Type{}, FloatType),
+	"int":        FuncOf([]*Type{AnyType}, IntType),
+	"float":      FuncOf([]*Type{AnyType}, FloatType),
+	"str":        FuncOf([]*Type{AnyType}, StrType),
+	"type":       FuncOf([]*Type{AnyType}, StrType),
+	"isinstance": FuncOf([]*Type{AnyType, AnyType}, BoolType),
+	"implements": FuncOf([]*Type{AnyType, AnyType}, BoolType),
+	"exception":  variadic(AnyType, StrType, StrType),
+	"Ok":         FuncOf([]*Type{AnyType}, &Type{Kind: Result}),
+	"Err":        FuncOf([]*Type{AnyType}, &Type{Kind: Result}),
+	"attempt":    variadic(&Type{Kind: Result}, AnyType),
+	"copy":       FuncOf([]*Type{ArrayOf(AnyType), ArrayOf(AnyType)}, IntType),
+	"help":       FuncOf([]*Type{AnyType}, NullType),
+	"sleep":      FuncOf([]*Type{IntType}, NullType),
+}
+
+// BuiltinSignature returns the type of a builtin whose behaviour is fixed,
+// or nil for builtins of unknown signature
+func BuiltinSignature(name string) *Type {
+	return builtinSignatures[name]
+}
+
+// BuiltinArity returns the number of arguments a builtin with a fixed
+// signature takes; ok is false for unknown and variadic builtins
+func BuiltinArity(name string) (n int, ok bool) {
+	sig := builtinSignatures[name]
+	if sig == nil || sig.Variadic != nil {
+		return 0, false
+	}
+	return len(sig.Params), true
+}
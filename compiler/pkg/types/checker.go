@@ -0,0 +1,1031 @@
+package types
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"lynx/pkg/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Error is a type error found at a source position
+type Error struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) String() string {
+	if e.File == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+// Checker verifies type annotations in a program and the modules it
+// imports. Unannotated code is inferred where possible and otherwise
+// treated as any, so only provable mistakes are reported.
+type Checker struct {
+	// Resolve returns the file path of module name imported from dir; when
+	// nil, imported modules are not checked and typed as any
+	Resolve func(name, dir string) (string, error)
+	// Read returns the source of a resolved module path
+	Read func(path string) ([]byte, error)
+	// Globals names runtime builtins; those without a known signature are
+	// typed as functions taking any arguments
+	Globals []string
+
+	errors  []Error
+	modules map[string]*Type
+	loading map[string]bool
+}
+
+func New() *Checker {
+	return &Checker{
+		Read:    os.ReadFile,
+		modules: make(map[string]*Type),
+		loading: make(map[string]bool),
+	}
+}
+
+// CheckFile parses and checks the program at path
+func (c *Checker) CheckFile(path string) []Error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return []Error{{File: path, Message: err.Error()}}
+	}
+
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		errs := []Error{}
+		for _, perr := range p.Errors() {
+			errs = append(errs, Error{File: path, Line: perr.Line, Column: perr.Column, Message: perr.Message})
+		}
+		return errs
+	}
+
+	return c.CheckProgram(program, path, filepath.Dir(path))
+}
+
+// CheckProgram checks an already parsed program; file names it in errors
+// and dir is used to resolve its imports
+func (c *Checker) CheckProgram(program *ast.Program, file, dir string) []Error {
+	f := &fileChecker{checker: c, file: file, dir: dir, resolved: make(map[*ast.TypeAnnotation]*Type)}
+	f.checkProgram(program)
+
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i], c.errors[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.errors
+}
+
+// loadModule checks an imported module once and returns its type
+func (c *Checker) loadModule(name, dir string) (*Type, error) {
+	if c.Resolve == nil {
+		return AnyType, nil
+	}
+	path, err := c.Resolve(name, dir)
+	if err != nil {
+		return nil, err
+	}
+	if mod, ok := c.modules[path]; ok {
+		return mod, nil
+	}
+	if c.loading[path] {
+		return AnyType, nil
+	}
+	c.loading[path] = true
+	defer delete(c.loading, path)
+
+	source, err := c.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	l := lexer.New(string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, fmt.Errorf("module %s has syntax errors: %s", name, p.Errors()[0])
+	}
+
+	f := &fileChecker{checker: c, file: path, dir: filepath.Dir(path), resolved: make(map[*ast.TypeAnnotation]*Type)}
+	s := f.checkProgram(program)
+
+	// Like the evaluator, prefer a module object bound to the file's name
+	// and fall back to the file's top-level bindings, limited to exports
+	base := strings.TrimSuffix(filepath.Base(path), ".lynx")
+	mod := &Type{Kind: Module, Name: name, Members: s.vars}
+	if t, ok := s.vars[base]; ok && t.Kind == Module {
+		mod = &Type{Kind: Module, Name: name, Members: t.Members}
+	} else if exports := exportedNames(program); exports != nil {
+		mod.Members = make(map[string]*Type)
+		for export := range exports {
+			mod.Members[export] = orAny(s.vars[export])
+		}
+	}
+	c.modules[path] = mod
+	return mod, nil
+}
+
+// exportedNames returns the names a program exports, or nil if it has no
+// export declarations
+func exportedNames(program *ast.Program) map[string]bool {
+	var names map[string]bool
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		if names == nil {
+			names = make(map[string]bool)
+		}
+		switch decl := export.Statement.(type) {
+		case *ast.VarStatement:
+			names[decl.Name.Value] = true
+		case *ast.Class:
+			names[decl.Name.Value] = true
+		case *ast.Trait:
+			names[decl.Name.Value] = true
+		}
+	}
+	return names
+}
+
+type fileChecker struct {
+	checker    *Checker
+	file       string
+	dir        string
+	resolved   map[*ast.TypeAnnotation]*Type
+	values     map[*ast.ExpressionStatement]*Type
+	reassigned map[string]bool
+	traits     map[string]*ClassInfo
+}
+
+// funcContext tracks the function whose body is being checked
+type funcContext struct {
+	declared *Type // annotated return type, nil when unannotated
+	returns  []*Type
+	class    *ClassInfo
+	hasSelf  bool
+}
+
+type scope struct {
+	vars     map[string]*Type
+	declared map[string]*Type
+	outer    *scope
+	fn       *funcContext
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{vars: make(map[string]*Type), declared: make(map[string]*Type), outer: outer}
+}
+
+func (s *scope) lookup(name string) (*Type, *scope) {
+	for sc := s; sc != nil; sc = sc.outer {
+		if t, ok := sc.vars[name]; ok {
+			return t, sc
+		}
+	}
+	return nil, nil
+}
+
+func (s *scope) function() *funcContext {
+	for sc := s; sc != nil; sc = sc.outer {
+		if sc.fn != nil {
+			return sc.fn
+		}
+	}
+	return nil
+}
+
+func (f *fileChecker) errorf(tok token.Token, format string, a ...any) {
+	f.checker.errors = append(f.checker.errors, Error{
+		File:    f.file,
+		Line:    tok.Line,
+		Column:  tok.Column,
+		Message: fmt.Sprintf(format, a...),
+	})
+}
+
+func (f *fileChecker) checkProgram(program *ast.Program) *scope {
+	f.values = make(map[*ast.ExpressionStatement]*Type)
+	f.reassigned = make(map[string]bool)
+	f.traits = make(map[string]*ClassInfo)
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Assignment:
+			if ident, ok := n.Name.(*ast.Identifier); ok {
+				f.reassigned[ident.Value] = true
+			}
+		case *ast.Trait:
+			info := &ClassInfo{Name: n.Name.Value, Members: make(map[string]*Type)}
+			for _, m := range n.Methods {
+				info.Members[m.Name.Value] = AnyType
+			}
+			f.traits[n.Name.Value] = info
+		}
+		return true
+	})
+
+	global := newScope(nil)
+	for _, name := range f.checker.Globals {
+		global.vars[name] = variadic(AnyType)
+	}
+	for name, sig := range builtinSignatures {
+		global.vars[name] = sig
+	}
+	global.vars["_"] = AnyType
+
+	s := newScope(global)
+	f.checkStatements(program.Statements, s)
+	return s
+}
+
+// checkStatements checks a function or program body. Blocks share their
+// enclosing scope at runtime, so every binding in nested blocks is hoisted
+// into s first, letting functions refer to names declared after them.
+func (f *fileChecker) checkStatements(stmts []ast.Statement, s *scope) {
+	f.hoist(stmts, s)
+	for _, stmt := range stmts {
+		f.statement(stmt, s)
+	}
+}
+
+func (f *fileChecker) hoist(stmts []ast.Statement, s *scope) {
+	classes := []*ast.Class{}
+	visitAll := func(fn func(ast.Node) bool) {
+		for _, stmt := range stmts {
+			ast.Inspect(stmt, fn)
+		}
+	}
+
+	visitAll(func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FunctionLiteral, *ast.Class, *ast.Trait:
+			if class, ok := n.(*ast.Class); ok {
+				info := &ClassInfo{
+					Name:    class.Name.Value,
+					Members: make(map[string]*Type),
+					Fields:  make(map[string]*Type),
+					Statics: make(map[string]*Type),
+				}
+				s.vars[class.Name.Value] = &Type{Kind: ClassRef, Class: info}
+				classes = append(classes, class)
+			}
+			if trait, ok := n.(*ast.Trait); ok {
+				s.vars[trait.Name.Value] = AnyType
+			}
+			return false
+		}
+		return true
+	})
+
+	for _, class := range classes {
+		f.declareClass(class, s)
+	}
+
+	visitAll(func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.FunctionLiteral, *ast.Class, *ast.Trait:
+			return false
+		case *ast.VarStatement:
+			f.hoistVar(n, s)
+		case *ast.ForRange:
+			s.vars[n.Variable.Value] = AnyType
+			if n.Index != nil {
+				s.vars[n.Index.Value] = AnyType
+			}
+		case *ast.ModuleLoad:
+			if n.Members == nil {
+				s.vars[n.Binding()] = AnyType
+			}
+			for _, m := range n.Members {
+				s.vars[m.Value] = AnyType
+			}
+		case *ast.CatchStatement:
+			for _, h := range n.Handlers {
+				s.vars[h.ErrorVar.Value] = AnyType
+			}
+		}
+		return true
+	})
+}
+
+func (f *fileChecker) hoistVar(n *ast.VarStatement, s *scope) {
+	name := n.Name.Value
+	if n.Type != nil {
+		declared := f.annotation(n.Type, s)
+		s.vars[name] = declared
+		s.declared[name] = declared
+		return
+	}
+	if _, ok := s.vars[name]; ok {
+		return
+	}
+	if lit, ok := n.Value.(*ast.FunctionLiteral); ok && !f.reassigned[name] {
+		s.vars[name] = f.signature(lit, s)
+		return
+	}
+	s.vars[name] = AnyType
+}
+
+// declareClass records the members of a class from its declarations alone,
+// so code anywhere in the scope can use them before the body is checked
+func (f *fileChecker) declareClass(node *ast.Class, s *scope) {
+	info := s.vars[node.Name.Value].Class
+	if node.SuperClass != nil {
+		if t, _ := s.lookup(node.SuperClass.Value); t != nil && t.Kind == ClassRef {
+			info.Super = t.Class
+		}
+	}
+	for _, trait := range node.Traits {
+		if t := f.traits[trait.Value]; t != nil {
+			info.Traits = append(info.Traits, t)
+			for method := range t.Members {
+				info.Members[method] = AnyType
+			}
+		}
+	}
+	if node.Body == nil {
+		return
+	}
+
+	var declare func(stmt ast.Statement, static bool)
+	declare = func(stmt ast.Statement, static bool) {
+		switch m := stmt.(type) {
+		case *ast.PrivateStatement:
+			declare(m.Statement, static)
+		case *ast.StaticStatement:
+			declare(m.Statement, true)
+		case *ast.VarStatement:
+			t := AnyType
+			if m.Type != nil {
+				t = f.annotation(m.Type, s)
+			} else if lit, ok := m.Value.(*ast.FunctionLiteral); ok {
+				t = f.signature(lit, s)
+			}
+			switch {
+			case static || m.IsConst:
+				info.Statics[m.Name.Value] = t
+			default:
+				info.Members[m.Name.Value] = t
+				if m.Type != nil {
+					info.Fields[m.Name.Value] = t
+				}
+			}
+		case *ast.Accessor:
+			lit := m.Value.(*ast.FunctionLiteral)
+			if m.Kind == "get" {
+				info.Members[m.Name.Value] = orAny(f.signature(lit, s).Return)
+			} else if _, ok := info.Members[m.Name.Value]; !ok {
+				info.Members[m.Name.Value] = AnyType
+			}
+		}
+	}
+	for _, stmt := range node.Body.Statements {
+		declare(stmt, false)
+	}
+
+	// Attributes created by assigning to self inside methods
+	ast.Inspect(node.Body, func(n ast.Node) bool {
+		if assign, ok := n.(*ast.Assignment); ok {
+			if prop, ok := assign.Name.(*ast.PropertyAccess); ok {
+				if _, isSelf := prop.Object.(*ast.Self); isSelf {
+					if _, known := info.Members[prop.Property.Value]; !known {
+						info.Members[prop.Property.Value] = AnyType
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+// annotation resolves a type annotation once, reporting unknown names
+func (f *fileChecker) annotation(ta *ast.TypeAnnotation, s *scope) *Type {
+	if t, ok := f.resolved[ta]; ok {
+		return t
+	}
+	t, problem := FromAnnotation(ta, func(name string) *ClassInfo {
+		if t, _ := s.lookup(name); t != nil && t.Kind == ClassRef {
+			return t.Class
+		}
+		return f.traits[name]
+	})
+	if problem != "" {
+		f.errorf(ta.Token, "%s", problem)
+		t = AnyType
+	}
+	f.resolved[ta] = t
+	return t
+}
+
+// signature builds a function type from a literal's annotations alone
+func (f *fileChecker) signature(lit *ast.FunctionLiteral, s *scope) *Type {
+	sig := &Type{Kind: Func, Params: []*Type{}, Return: AnyType}
+	for i, param := range lit.Parameters {
+		if param.Value == "..." {
+			sig.Variadic = AnyType
+			continue
+		}
+		t := AnyType
+		if i < len(lit.ParamTypes) && lit.ParamTypes[i] != nil {
+			t = f.annotation(lit.ParamTypes[i], s)
+		}
+		sig.Params = append(sig.Params, t)
+	}
+	if lit.ReturnType != nil {
+		sig.Return = f.annotation(lit.ReturnType, s)
+	}
+	return sig
+}
+
+func (f *fileChecker) statement(stmt ast.Statement, s *scope) {
+	switch n := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if n.Expression != nil {
+			f.values[n] = f.expr(n.Expression, s)
+		}
+	case *ast.VarStatement:
+		f.varStatement(n, s)
+	case *ast.Assignment:
+		f.assignment(n, s)
+	case *ast.ReturnStatement:
+		t := NullType
+		if n.Value != nil {
+			t = f.expr(n.Value, s)
+		}
+		ctx := s.function()
+		if ctx == nil {
+			return
+		}
+		if ctx.declared != nil && !Assignable(ctx.declared, t) {
+			f.errorf(n.Token, "cannot return %s from a function declared to return %s", t, ctx.declared)
+		}
+		ctx.returns = append(ctx.returns, t)
+	case *ast.ForRange:
+		f.forRange(n, s)
+	case *ast.While:
+		f.expr(n.Condition, s)
+		f.block(n.Body, s)
+	case *ast.SwitchStatement:
+		f.expr(n.Expression, s)
+		for _, c := range n.Cases {
+			if c.Value != nil {
+				f.expr(c.Value, s)
+			}
+			if c.Guard != nil {
+				f.expr(c.Guard, s)
+			}
+			f.block(c.Body, s)
+		}
+	case *ast.CatchStatement:
+		f.block(n.Body, s)
+		for _, h := range n.Handlers {
+			f.block(h.Body, s)
+		}
+		f.block(n.Finally, s)
+	case *ast.DeferStatement:
+		if n.Body != nil {
+			f.block(n.Body, s)
+		} else {
+			f.expr(n.Value, s)
+		}
+	case *ast.ErrorStatement:
+		f.expr(n.Value, s)
+	case *ast.ModuleLoad:
+		f.moduleLoad(n, s)
+	case *ast.ExportStatement:
+		f.statement(n.Statement, s)
+	case *ast.Class:
+		f.class(n, s)
+	case *ast.Trait:
+		for _, m := range n.Methods {
+			if m.Body == nil {
+				continue
+			}
+			ms := newScope(s)
+			ms.fn = &funcContext{}
+			ms.vars["self"] = AnyType
+			for _, p := range m.Parameters {
+				ms.vars[p.Value] = AnyType
+			}
+			f.checkStatements(m.Body.Statements, ms)
+		}
+	}
+}
+
+func (f *fileChecker) block(block *ast.BlockStatement, s *scope) {
+	if block == nil {
+		return
+	}
+	for _, stmt := range block.Statements {
+		f.statement(stmt, s)
+	}
+}
+
+func (f *fileChecker) varStatement(n *ast.VarStatement, s *scope) {
+	t := f.expr(n.Value, s)
+	name := n.Name.Value
+	if n.Type != nil {
+		declared := f.annotation(n.Type, s)
+		if !Assignable(declared, t) {
+			f.errorf(n.Name.Token, "cannot assign %s to %s of type %s", t, name, declared)
+		}
+		s.vars[name] = declared
+		s.declared[name] = declared
+		return
+	}
+	if _, isDeclared := s.declared[name]; isDeclared {
+		return
+	}
+	if f.reassigned[name] {
+		s.vars[name] = AnyType
+		return
+	}
+	s.vars[name] = t
+}
+
+func (f *fileChecker) assignment(n *ast.Assignment, s *scope) {
+	t := f.expr(n.Value, s)
+
+	switch target := n.Name.(type) {
+	case *ast.Identifier:
+		_, owner := s.lookup(target.Value)
+		if owner == nil {
+			f.errorf(target.Token, "undefined variable: %s", target.Value)
+			return
+		}
+		if declared, ok := owner.declared[target.Value]; ok && !Assignable(declared, t) {
+			f.errorf(target.Token, "cannot assign %s to %s of type %s", t, target.Value, declared)
+		}
+	case *ast.PropertyAccess:
+		obj := f.expr(target.Object, s)
+		switch obj.Kind {
+		case Instance:
+			if declared, ok := obj.Class.Field(target.Property.Value); ok && !Assignable(declared, t) {
+				f.errorf(target.Property.Token, "cannot assign %s to field %s.%s of type %s",
+					t, obj.Class.Name, target.Property.Value, declared)
+			}
+		case Module:
+			if _, ok := obj.Members[target.Property.Value]; !ok || obj.Open {
+				obj.Members[target.Property.Value] = t
+			}
+		}
+	case *ast.IndexExpression:
+		left := f.expr(target.Left, s)
+		f.expr(target.Index, s)
+		if ident, ok := target.Left.(*ast.Identifier); ok {
+			if _, owner := s.lookup(ident.Value); owner != nil {
+				if declared, ok := owner.declared[ident.Value]; ok && declared == left &&
+					(left.Kind == Array || left.Kind == Hash) && !Assignable(left.Elem, t) {
+					f.errorf(target.Token, "cannot store %s in %s of type %s", t, ident.Value, declared)
+				}
+			}
+		}
+	}
+}
+
+func (f *fileChecker) forRange(n *ast.ForRange, s *scope) {
+	coll := f.expr(n.Collection, s)
+	elem, index := AnyType, AnyType
+	switch coll.Kind {
+	case Array:
+		elem, index = orAny(coll.Elem), IntType
+	case Hash:
+		elem, index = orAny(coll.Elem), orAny(coll.Key)
+	case Str:
+		elem, index = StrType, IntType
+	case Any, Union, Instance:
+	default:
+		f.errorf(ast.StartToken(n.Collection), "cannot iterate over %s", coll)
+	}
+	if !f.reassigned[n.Variable.Value] {
+		s.vars[n.Variable.Value] = elem
+	}
+	if n.Index != nil && !f.reassigned[n.Index.Value] {
+		s.vars[n.Index.Value] = index
+	}
+	f.block(n.Body, s)
+}
+
+func (f *fileChecker) moduleLoad(n *ast.ModuleLoad, s *scope) *Type {
+	name := n.Path()
+	if name == "" || name == "module" {
+		return &Type{Kind: Module, Members: make(map[string]*Type), Open: true}
+	}
+
+	mod, err := f.checker.loadModule(name, f.dir)
+	if err != nil {
+		f.errorf(n.Token, "%s", err)
+		mod = AnyType
+	}
+	if n.Members == nil {
+		s.vars[n.Binding()] = mod
+		return mod
+	}
+	for _, m := range n.Members {
+		t := AnyType
+		if mod.Kind == Module {
+			member, ok := mod.Members[m.Value]
+			if !ok {
+				f.errorf(m.Token, "module %s has no member %s", name, m.Value)
+			} else {
+				t = member
+			}
+		}
+		s.vars[m.Value] = t
+	}
+	return mod
+}
+
+func (f *fileChecker) class(n *ast.Class, s *scope) {
+	ref, _ := s.lookup(n.Name.Value)
+	if ref == nil || ref.Kind != ClassRef || n.Body == nil {
+		return
+	}
+	info := ref.Class
+
+	var check func(stmt ast.Statement, static bool)
+	check = func(stmt ast.Statement, static bool) {
+		switch m := stmt.(type) {
+		case *ast.PrivateStatement:
+			check(m.Statement, static)
+		case *ast.StaticStatement:
+			check(m.Statement, true)
+		case *ast.Accessor:
+			f.function(m.Value.(*ast.FunctionLiteral), s, info, true)
+		case *ast.VarStatement:
+			instance := !static && !m.IsConst
+			var t *Type
+			if lit, ok := m.Value.(*ast.FunctionLiteral); ok {
+				t = f.function(lit, s, info, instance)
+			} else {
+				ms := newScope(s)
+				ms.fn = &funcContext{class: info, hasSelf: instance}
+				t = f.expr(m.Value, ms)
+			}
+			if m.Type != nil {
+				declared := f.annotation(m.Type, s)
+				if !Assignable(declared, t) {
+					f.errorf(m.Name.Token, "cannot assign %s to %s.%s of type %s", t, info.Name, m.Name.Value, declared)
+				}
+			}
+		}
+	}
+	for _, stmt := range n.Body.Statements {
+		check(stmt, false)
+	}
+}
+
+// function checks a function literal's body and returns its type, with the
+// return type inferred when it is not annotated
+func (f *fileChecker) function(lit *ast.FunctionLiteral, s *scope, class *ClassInfo, hasSelf bool) *Type {
+	sig := f.signature(lit, s)
+	fs := newScope(s)
+	fs.fn = &funcContext{class: class, hasSelf: hasSelf}
+	if lit.ReturnType != nil {
+		fs.fn.declared = sig.Return
+	}
+
+	i := 0
+	for _, param := range lit.Parameters {
+		if param.Value == "..." {
+			continue
+		}
+		fs.vars[param.Value] = sig.Params[i]
+		if i < len(lit.ParamTypes) && lit.ParamTypes[i] != nil {
+			fs.declared[param.Value] = sig.Params[i]
+		}
+		i++
+	}
+
+	if lit.Body == nil {
+		return sig
+	}
+	f.checkStatements(lit.Body.Statements, fs)
+
+	if lit.ReturnType == nil {
+		results := fs.fn.returns
+		stmts := lit.Body.Statements
+		if len(stmts) == 0 {
+			results = append(results, NullType)
+		} else if last, ok := stmts[len(stmts)-1].(*ast.ExpressionStatement); ok && f.values[last] != nil {
+			results = append(results, f.values[last])
+		} else if _, ok := stmts[len(stmts)-1].(*ast.ReturnStatement); !ok {
+			results = append(results, AnyType)
+		}
+		if len(results) > 0 {
+			sig.Return = UnionOf(results...)
+		}
+	}
+	return sig
+}
+
+func (f *fileChecker) expr(e ast.Expression, s *scope) *Type {
+	switch n := e.(type) {
+	case nil:
+		return AnyType
+	case *ast.IntegerLiteral:
+		return IntType
+	case *ast.FloatLiteral:
+		return FloatType
+	case *ast.StringLiteral:
+		return StrType
+	case *ast.Boolean:
+		return BoolType
+	case *ast.Null:
+		return NullType
+	case *ast.Identifier:
+		t, owner := s.lookup(n.Value)
+		if owner == nil {
+			f.errorf(n.Token, "undefined variable: %s", n.Value)
+			return AnyType
+		}
+		return t
+	case *ast.Self:
+		if ctx := s.function(); ctx != nil && ctx.class != nil && ctx.hasSelf {
+			return &Type{Kind: Instance, Class: ctx.class}
+		}
+		return AnyType
+	case *ast.ArrayLiteral:
+		if len(n.Elements) == 0 {
+			return ArrayOf(AnyType)
+		}
+		elems := []*Type{}
+		for _, el := range n.Elements {
+			elems = append(elems, f.expr(el, s))
+		}
+		return ArrayOf(UnionOf(elems...))
+	case *ast.HashLiteral:
+		if len(n.Pairs) == 0 {
+			return HashOf(AnyType, AnyType)
+		}
+		keys, values := []*Type{}, []*Type{}
+		for k, v := range n.Pairs {
+			keys = append(keys, f.expr(k, s))
+			values = append(values, f.expr(v, s))
+		}
+		return HashOf(UnionOf(keys...), UnionOf(values...))
+	case *ast.PrefixExpression:
+		return f.prefix(n, f.expr(n.Right, s))
+	case *ast.InfixExpression:
+		return f.infix(n, f.expr(n.Left, s), f.expr(n.Right, s))
+	case *ast.IfExpression:
+		f.expr(n.Condition, s)
+		f.block(n.Consequence, s)
+		f.block(n.Alternative, s)
+		return AnyType
+	case *ast.FunctionLiteral:
+		return f.function(n, s, nil, false)
+	case *ast.CallExpression:
+		callee := f.expr(n.Function, s)
+		args := f.exprs(n.Arguments, s)
+		return f.call(n.Token, n.Function.String(), callee, args, n.Arguments)
+	case *ast.MethodCall:
+		obj := f.expr(n.Object, s)
+		args := f.exprs(n.Arguments, s)
+		member := f.member(obj, n.Method, true)
+		if member == nil {
+			return AnyType
+		}
+		return f.call(n.Method.Token, n.Object.String()+"."+n.Method.Value, member, args, n.Arguments)
+	case *ast.PropertyAccess:
+		obj := f.expr(n.Object, s)
+		if member := f.member(obj, n.Property, false); member != nil {
+			return member
+		}
+		return AnyType
+	case *ast.IndexExpression:
+		return f.index(n, f.expr(n.Left, s), f.expr(n.Index, s))
+	case *ast.PipeExpression:
+		f.expr(n.Left, s)
+		switch right := n.Right.(type) {
+		case *ast.CallExpression:
+			f.expr(right.Function, s)
+			f.exprs(right.Arguments, s)
+		case *ast.MethodCall:
+			f.expr(right.Object, s)
+			f.exprs(right.Arguments, s)
+		default:
+			f.expr(right, s)
+		}
+		return AnyType
+	case *ast.TryExpression:
+		f.expr(n.Value, s)
+		return AnyType
+	case *ast.Tuple:
+		f.exprs(n.Elements, s)
+		return AnyType
+	case *ast.ErrorStatement:
+		f.expr(n.Value, s)
+		return AnyType
+	case *ast.ModuleLoad:
+		return f.moduleLoad(n, s)
+	default:
+		return AnyType
+	}
+}
+
+func (f *fileChecker) exprs(exprs []ast.Expression, s *scope) []*Type {
+	types := []*Type{}
+	for _, e := range exprs {
+		types = append(types, f.expr(e, s))
+	}
+	return types
+}
+
+func (f *fileChecker) call(at token.Token, name string, callee *Type, args []*Type, argNodes []ast.Expression) *Type {
+	switch callee.Kind {
+	case Func:
+		f.checkArgs(at, name, callee, args, argNodes)
+		return orAny(callee.Return)
+	case ClassRef:
+		if init, ok := callee.Class.Member("init"); ok && init.Kind == Func {
+			f.checkArgs(at, name, init, args, argNodes)
+		}
+		return &Type{Kind: Instance, Class: callee.Class}
+	case Any, Union, Instance:
+		return AnyType
+	default:
+		f.errorf(at, "%s is not callable, it is %s", name, callee)
+		return AnyType
+	}
+}
+
+func (f *fileChecker) checkArgs(at token.Token, name string, sig *Type, args []*Type, argNodes []ast.Expression) {
+	if sig.Variadic == nil && len(args) != len(sig.Params) {
+		f.errorf(at, "%s expects %s, got %d", name, arguments(len(sig.Params)), len(args))
+		return
+	}
+	if len(args) < len(sig.Params) {
+		f.errorf(at, "%s expects at least %s, got %d", name, arguments(len(sig.Params)), len(args))
+		return
+	}
+	for i, param := range sig.Params {
+		if !Assignable(param, args[i]) {
+			f.errorf(ast.StartToken(argNodes[i]), "argument %d to %s: expected %s, got %s", i+1, name, param, args[i])
+		}
+	}
+}
+
+func arguments(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// member types obj.name, or returns nil when nothing is known about it
+func (f *fileChecker) member(obj *Type, name *ast.Identifier, call bool) *Type {
+	switch obj.Kind {
+	case Module:
+		if t, ok := obj.Members[name.Value]; ok {
+			return t
+		}
+		if !obj.Open {
+			f.errorf(name.Token, "module %s has no member %s", obj.Name, name.Value)
+		}
+	case Instance:
+		if t, ok := obj.Class.Member(name.Value); ok {
+			return t
+		}
+		f.errorf(name.Token, "%s has no member %s", obj.Class.Name, name.Value)
+	case ClassRef:
+		for cls := obj.Class; cls != nil; cls = cls.Super {
+			if t, ok := cls.Statics[name.Value]; ok {
+				return t
+			}
+		}
+		if t, ok := obj.Class.Member(name.Value); ok {
+			return t
+		}
+		f.errorf(name.Token, "class %s has no member %s", obj.Class.Name, name.Value)
+	case Result:
+		switch name.Value {
+		case "isOk", "isErr":
+			return FuncOf([]*Type{}, BoolType)
+		case "unwrap":
+			return FuncOf([]*Type{}, AnyType)
+		case "unwrapOr", "map", "mapErr":
+			return FuncOf([]*Type{AnyType}, AnyType)
+		}
+		f.errorf(name.Token, "result has no method %s", name.Value)
+	case Hash:
+		if !call {
+			return orAny(obj.Elem)
+		}
+	case Int, Float, Bool, Null, Func:
+		if call {
+			f.errorf(name.Token, "method calls not supported on %s", obj)
+		} else {
+			f.errorf(name.Token, "property access not supported on %s", obj)
+		}
+	}
+	return nil
+}
+
+func (f *fileChecker) index(n *ast.IndexExpression, left, index *Type) *Type {
+	switch left.Kind {
+	case Array:
+		if index.Kind != Any && index.Kind != Int && index.Kind != Union {
+			f.errorf(ast.StartToken(n.Index), "array index must be int, got %s", index)
+		}
+		return orAny(left.Elem)
+	case Hash:
+		return orAny(left.Elem)
+	case Str:
+		return StrType
+	case Int, Float, Bool, Null, Func:
+		f.errorf(n.Token, "cannot index %s", left)
+	}
+	return AnyType
+}
+
+func (f *fileChecker) prefix(n *ast.PrefixExpression, right *Type) *Type {
+	switch n.Operator {
+	case "!":
+		return BoolType
+	case "-":
+		if right.IsNumeric() {
+			return right
+		}
+		if concrete(right) {
+			f.errorf(n.Token, "operator - not defined for %s", right)
+		}
+	}
+	return AnyType
+}
+
+// concrete reports whether t is a single builtin type whose operators are
+// fixed; instances may overload operators
+func concrete(t *Type) bool {
+	switch t.Kind {
+	case Any, Union, Instance:
+		return false
+	}
+	return true
+}
+
+func (f *fileChecker) infix(n *ast.InfixExpression, left, right *Type) *Type {
+	op := n.Operator
+	switch op {
+	case "==", "!=", "in", "instanceof":
+		return BoolType
+	case "and", "or":
+		return AnyType
+	case "++":
+		if left.Kind == Str && right.Kind == Str {
+			return StrType
+		}
+		return AnyType
+	}
+
+	comparison := op == "<" || op == ">" || op == "<=" || op == ">="
+	if !concrete(left) || !concrete(right) {
+		if comparison {
+			return BoolType
+		}
+		return AnyType
+	}
+
+	switch {
+	case left.IsNumeric() && right.IsNumeric():
+		if comparison {
+			return BoolType
+		}
+		if left.Kind == Int && right.Kind == Int {
+			return IntType
+		}
+		return FloatType
+	case comparison && left.Kind == Str && right.Kind == Str:
+		return BoolType
+	case comparison && (left.Kind == Bool || right.Kind == Bool) &&
+		(left.Kind == Int || right.Kind == Int):
+		return BoolType
+	case op == "+" && left.Kind == Str && right.Kind == Str:
+		return StrType
+	case op == "+" && left.Kind == Array && right.Kind == Array:
+		return ArrayOf(AnyType)
+	}
+
+	f.errorf(n.Token, "operator %s not defined for %s and %s", op, left, right)
+	if comparison {
+		return BoolType
+	}
+	return AnyType
+}
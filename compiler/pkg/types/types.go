@@ -0,0 +1,310 @@
+package types
+
+import (
+	"lynx/pkg/ast"
+	"strings"
+)
+
+// Kind is the shape of a static type
+type Kind int
+
+const (
+	Any Kind = iota
+	Int
+	Float
+	Str
+	Bool
+	Null
+	Array
+	Hash
+	Func
+	ClassRef
+	Instance
+	Module
+	Result
+	Union
+)
+
+// Type is a statically known type. Any is used whenever the checker cannot
+// be sure, and is compatible with every other type.
+type Type struct {
+	Kind     Kind
+	Elem     *Type            // array element or hash value
+	Key      *Type            // hash key
+	Params   []*Type          // function parameters
+	Variadic *Type            // type of extra arguments, nil for fixed arity
+	Return   *Type            // function result
+	Class    *ClassInfo       // class for ClassRef and Instance
+	Name     string           // module name
+	Members  map[string]*Type // module members
+	Alts     []*Type          // union alternatives
+	Open     bool             // module still gaining members by assignment
+}
+
+// ClassInfo describes a class declaration, or a trait by its method names
+type ClassInfo struct {
+	Name    string
+	Super   *ClassInfo
+	Members map[string]*Type // fields, methods and accessors seen on instances
+	Fields  map[string]*Type // annotated fields, enforced on assignment
+	Statics map[string]*Type
+	Traits  []*ClassInfo // traits the class implements
+}
+
+var (
+	AnyType   = &Type{Kind: Any}
+	IntType   = &Type{Kind: Int}
+	FloatType = &Type{Kind: Float}
+	StrType   = &Type{Kind: Str}
+	BoolType  = &Type{Kind: Bool}
+	NullType  = &Type{Kind: Null}
+)
+
+func ArrayOf(elem *Type) *Type { return &Type{Kind: Array, Elem: elem} }
+
+func HashOf(key, value *Type) *Type { return &Type{Kind: Hash, Key: key, Elem: value} }
+
+func FuncOf(params []*Type, ret *Type) *Type {
+	return &Type{Kind: Func, Params: params, Return: ret}
+}
+
+func UnionOf(alts ...*Type) *Type {
+	flat := []*Type{}
+	for _, alt := range alts {
+		if alt.Kind == Any {
+			return AnyType
+		}
+		if alt.Kind == Union {
+			flat = append(flat, alt.Alts...)
+			continue
+		}
+		flat = append(flat, alt)
+	}
+	unique := []*Type{}
+	for _, alt := range flat {
+		seen := false
+		for _, u := range unique {
+			if u.String() == alt.String() {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			unique = append(unique, alt)
+		}
+	}
+	if len(unique) == 1 {
+		return unique[0]
+	}
+	return &Type{Kind: Union, Alts: unique}
+}
+
+func (t *Type) String() string {
+	switch t.Kind {
+	case Int:
+		return "int"
+	case Float:
+		return "float"
+	case Str:
+		return "str"
+	case Bool:
+		return "bool"
+	case Null:
+		return "null"
+	case Array:
+		if t.Elem == nil || t.Elem.Kind == Any {
+			return "array"
+		}
+		return "array[" + t.Elem.String() + "]"
+	case Hash:
+		if (t.Key == nil || t.Key.Kind == Any) && (t.Elem == nil || t.Elem.Kind == Any) {
+			return "hash"
+		}
+		return "hash[" + orAny(t.Key).String() + ", " + orAny(t.Elem).String() + "]"
+	case Func:
+		params := []string{}
+		for _, p := range t.Params {
+			params = append(params, p.String())
+		}
+		if t.Variadic != nil {
+			params = append(params, "..."+t.Variadic.String())
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + orAny(t.Return).String()
+	case ClassRef:
+		return "class " + t.Class.Name
+	case Instance:
+		return t.Class.Name
+	case Module:
+		return "module " + t.Name
+	case Result:
+		return "result"
+	case Union:
+		alts := []string{}
+		for _, alt := range t.Alts {
+			alts = append(alts, alt.String())
+		}
+		return strings.Join(alts, " | ")
+	default:
+		return "any"
+	}
+}
+
+func orAny(t *Type) *Type {
+	if t == nil {
+		return AnyType
+	}
+	return t
+}
+
+// IsNumeric reports whether t is int or float
+func (t *Type) IsNumeric() bool {
+	return t.Kind == Int || t.Kind == Float
+}
+
+// IsSubclassOf reports whether c is other, inherits from it or implements
+// it as a trait
+func (c *ClassInfo) IsSubclassOf(other *ClassInfo) bool {
+	for cls := c; cls != nil; cls = cls.Super {
+		if cls == other {
+			return true
+		}
+		for _, trait := range cls.Traits {
+			if trait == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Member looks up an instance member, searching superclasses
+func (c *ClassInfo) Member(name string) (*Type, bool) {
+	for cls := c; cls != nil; cls = cls.Super {
+		if t, ok := cls.Members[name]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Field returns the declared type of an annotated field
+func (c *ClassInfo) Field(name string) (*Type, bool) {
+	for cls := c; cls != nil; cls = cls.Super {
+		if t, ok := cls.Fields[name]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Assignable reports whether a value of type src may be stored where dst
+// is expected. Ints are accepted where floats are expected.
+func Assignable(dst, src *Type) bool {
+	if dst == nil || src == nil || dst.Kind == Any || src.Kind == Any {
+		return true
+	}
+	if src.Kind == Union {
+		for _, alt := range src.Alts {
+			if !Assignable(dst, alt) {
+				return false
+			}
+		}
+		return true
+	}
+	if dst.Kind == Union {
+		for _, alt := range dst.Alts {
+			if Assignable(alt, src) {
+				return true
+			}
+		}
+		return false
+	}
+	if dst.Kind == Float && src.Kind == Int {
+		return true
+	}
+	if dst.Kind != src.Kind {
+		return false
+	}
+	switch dst.Kind {
+	case Array:
+		return Assignable(dst.Elem, src.Elem)
+	case Hash:
+		return Assignable(dst.Key, src.Key) && Assignable(dst.Elem, src.Elem)
+	case Instance, ClassRef:
+		return dst.Class == anyClass || src.Class.IsSubclassOf(dst.Class)
+	}
+	return true
+}
+
+// FromAnnotation converts a parsed annotation, resolving class names with
+// lookup. A non-empty string result explains why the annotation is invalid.
+func FromAnnotation(ta *ast.TypeAnnotation, lookup func(name string) *ClassInfo) (*Type, string) {
+	if len(ta.Union) > 0 {
+		alts := []*Type{}
+		for _, alt := range ta.Union {
+			t, bad := FromAnnotation(alt, lookup)
+			if bad != "" {
+				return nil, bad
+			}
+			alts = append(alts, t)
+		}
+		return UnionOf(alts...), ""
+	}
+
+	params := []*Type{}
+	for _, p := range ta.Params {
+		t, bad := FromAnnotation(p, lookup)
+		if bad != "" {
+			return nil, bad
+		}
+		params = append(params, t)
+	}
+
+	var t *Type
+	switch ta.Name {
+	case "any", "exception":
+		t = AnyType
+	case "int":
+		t = IntType
+	case "float":
+		t = FloatType
+	case "str":
+		t = StrType
+	case "bool":
+		t = BoolType
+	case "null":
+		t = NullType
+	case "fn":
+		t = &Type{Kind: Func, Variadic: AnyType, Return: AnyType}
+	case "result":
+		t = &Type{Kind: Result}
+	case "array":
+		t = ArrayOf(AnyType)
+		if len(params) == 1 {
+			t = ArrayOf(params[0])
+		} else if len(params) > 1 {
+			return nil, "array takes one element type"
+		}
+	case "hash":
+		t = HashOf(AnyType, AnyType)
+		if len(params) == 2 {
+			t = HashOf(params[0], params[1])
+		} else if len(params) != 0 {
+			return nil, "hash takes a key and a value type"
+		}
+	default:
+		class := lookup(ta.Name)
+		if class == nil {
+			return nil, "unknown type " + ta.Name
+		}
+		t = &Type{Kind: Instance, Class: class}
+	}
+
+	if len(params) > 0 && t.Kind != Array && t.Kind != Hash {
+		return nil, ta.Name + " does not take type parameters"
+	}
+	if ta.Nullable {
+		t = UnionOf(t, NullType)
+	}
+	return t, ""
+}
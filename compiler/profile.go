@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"lynx/pkg/profile"
+	"os"
+)
+
+// profiling holds the run command's profile options
+var profiling struct {
+	report bool
+	folded string
+	pprof  string
+}
+
+func profileRequested() bool {
+	return profiling.report || profiling.folded != "" || profiling.pprof != ""
+}
+
+// startProfile profiles the program in file, returning the function that
+// writes the profile when it ends
+func startProfile(file string) func() {
+	p := profile.New()
+	p.Start(file)
+	return func() {
+		p.Stop()
+		if profiling.report {
+			p.WriteReport(os.Stderr)
+		}
+		writeOutput(profiling.folded, p.WriteFolded)
+		writeOutput(profiling.pprof, p.WritePprof)
+	}
+}
+
+// writeOutput writes a report to file, if one was named
+func writeOutput(file string, write func(f io.Writer) error) {
+	if file == "" {
+		return
+	}
+	f, err := os.Create(file)
+	if err == nil {
+		err = write(f)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", file, err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"lynx/pkg/project"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	register(&Command{
+		Name:    "init",
+		Args:    "[name]",
+		Summary: "create lynx.toml in the current directory",
+		Run:     func(args []string) { manageProject("init", args) },
+	})
+	register(&Command{
+		Name:    "add",
+		Args:    "[name] <path>",
+		Summary: "add a local dependency to the project",
+		Run:     func(args []string) { manageProject("add", args) },
+	})
+	register(&Command{
+		Name:    "remove",
+		Args:    "<name>",
+		Summary: "remove a dependency from the project",
+		Run:     func(args []string) { manageProject("remove", args) },
+	})
+	register(&Command{
+		Name:    "vendor",
+		Summary: "copy dependencies into vendor/",
+		Run:     func(args []string) { manageProject("vendor", args) },
+	})
+}
+
+// manageProject implements the init, add, remove and vendor commands
+func manageProject(command string, args []string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if command == "init" {
+		if _, err := os.Stat(project.ManifestFile); err == nil {
+			fmt.Printf("Error: %s already exists\n", project.ManifestFile)
+			os.Exit(1)
+		}
+		m := project.New(cwd)
+		if len(args) > 0 {
+			m.Name = args[0]
+		}
+		if err := m.Save(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if _, err := os.Stat(m.EntryPath()); os.IsNotExist(err) {
+			entry := fmt.Sprintf("println(\"Hello from %s\")\n", m.Name)
+			if err := os.WriteFile(m.EntryPath(), []byte(entry), 0o644); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		fmt.Printf("Created %s for %s\n", project.ManifestFile, m.Name)
+		return
+	}
+
+	m, err := project.Find(cwd)
+	if err == nil && m == nil {
+		err = fmt.Errorf("no %s found; run lynx init first", project.ManifestFile)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch command {
+	case "add":
+		var name, path string
+		switch len(args) {
+		case 1:
+			path = args[0]
+			name = filepath.Base(filepath.Clean(path))
+		case 2:
+			name, path = args[0], args[1]
+		default:
+			usage("add")
+		}
+		// Paths on the command line are relative to where lynx runs
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cwd, path)
+		}
+		err = m.Add(name, path)
+	case "remove":
+		if len(args) != 1 {
+			usage("remove")
+		}
+		err = m.Remove(args[0])
+	case "vendor":
+		err = m.Vendor()
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
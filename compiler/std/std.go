@@ -0,0 +1,25 @@
+// Package std embeds the Lynx standard library so the interpreter can load
+// it without the sources being installed next to the binary
+package std
+
+import (
+	"embed"
+	"sort"
+	"strings"
+)
+
+//go:embed *.lynx
+var Files embed.FS
+
+// Modules lists the names of the embedded standard library modules
+func Modules() []string {
+	entries, _ := Files.ReadDir(".")
+	names := []string{}
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), ".lynx"); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
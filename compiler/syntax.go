@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lynx/pkg/dump"
+	"os"
+)
+
+func init() {
+	register(&Command{
+		Name:    "tokens",
+		Args:    "[--format text|json] [-e code | <file> | -]",
+		Summary: "print the tokens the lexer reads from a program",
+		Run: func(args []string) {
+			source, name, json := syntaxArgs("tokens", args)
+			tokens, errs := dump.Tokens(source)
+			if json {
+				writeSyntax(func(w io.Writer) error { return dump.WriteTokensJSON(w, tokens, errs) })
+			} else {
+				writeSyntax(func(w io.Writer) error { return dump.WriteTokens(w, tokens) })
+			}
+			syntaxErrors(name, errs, json)
+		},
+	})
+	register(&Command{
+		Name:    "ast",
+		Args:    "[--format text|json] [-e code | <file> | -]",
+		Summary: "print the syntax tree the parser builds from a program",
+		Run: func(args []string) {
+			source, name, json := syntaxArgs("ast", args)
+			tree, errs := dump.Parse(source)
+			if json {
+				writeSyntax(func(w io.Writer) error { return dump.WriteTreeJSON(w, tree, errs) })
+			} else {
+				writeSyntax(func(w io.Writer) error { return dump.WriteTree(w, tree) })
+			}
+			syntaxErrors(name, errs, json)
+		},
+	})
+}
+
+// syntaxArgs reads the program lynx tokens or lynx ast inspects, returning
+// it with the name errors are reported against and whether JSON was asked for
+func syntaxArgs(name string, args []string) (string, string, bool) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	format := flags.String("format", "text", "write text or json")
+	code := flags.String("e", "", "inspect `code` instead of a file")
+	flags.Usage = func() { usage(name) }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Printf("Error: unknown format %q, expected text or json\n", *format)
+		os.Exit(1)
+	}
+	json := *format == "json"
+
+	switch {
+	case *code != "" && flags.NArg() == 0:
+		return *code, "-e", json
+	case flags.NArg() != 1:
+		usage(name)
+	case flags.Arg(0) == "-":
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Printf("Error reading stdin: %v\n", err)
+			os.Exit(1)
+		}
+		return string(input), "-", json
+	}
+	input, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Printf("Error reading file: %v\n", err)
+		os.Exit(1)
+	}
+	return string(input), flags.Arg(0), json
+}
+
+func writeSyntax(write func(io.Writer) error) {
+	if err := write(os.Stdout); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// syntaxErrors reports the errors found reading a program, which JSON output
+// already includes, and exits with status 1 if there were any
+func syntaxErrors(name string, errs []dump.Error, json bool) {
+	if len(errs) == 0 {
+		return
+	}
+	if !json {
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, e)
+		}
+	}
+	os.Exit(1)
+}
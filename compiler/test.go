@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"lynx/pkg/tester"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func init() {
+	register(&Command{
+		Name:    "test",
+		Args:    "[-run regexp] [--format text|tap|junit] [--cover] [<file|dir>...]",
+		Summary: "run the test_ functions of *_test.lynx files",
+		Run:     testCommand,
+	})
+}
+
+// testCommand runs the tests found under the given paths, by default the
+// current directory, and exits with status 1 if any fails
+func testCommand(args []string) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	run := flags.String("run", "", "run only the tests whose names match `regexp`")
+	format := flags.String("format", "text", "report as text, tap or junit")
+	flags.BoolVar(&covering.report, "cover", false, "print the statement and branch coverage to stderr")
+	flags.StringVar(&covering.html, "cover-html", "", "write the coverage as an annotated HTML page to `file`")
+	flags.StringVar(&covering.lcov, "cover-lcov", "", "write the coverage in LCOV format to `file`")
+	flags.Usage = func() { usage("test") }
+	if err := flags.Parse(args); err != nil {
+		os.Exit(1)
+	}
+
+	var write func(w io.Writer, results []*tester.Result, display func(string) string) error
+	switch *format {
+	case "text":
+		write = func(w io.Writer, results []*tester.Result, display func(string) string) error {
+			tester.WriteText(w, results, display)
+			return nil
+		}
+	case "tap":
+		write = tester.WriteTAP
+	case "junit":
+		write = tester.WriteJUnit
+	default:
+		fmt.Printf("Error: unknown format %q, expected text, tap or junit\n", *format)
+		os.Exit(1)
+	}
+
+	var filter *regexp.Regexp
+	if *run != "" {
+		var err error
+		if filter, err = regexp.Compile(*run); err != nil {
+			fmt.Printf("Error: invalid -run pattern: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	dir := paths[0]
+	if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	loadProject(dir)
+
+	files, err := tester.Discover(paths)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(files) == 0 {
+		fmt.Println("No test files found")
+		return
+	}
+
+	var finish func()
+	if coverageRequested() {
+		finish = startCoverage()
+	}
+	failed := false
+	results := []*tester.Result{}
+	for _, file := range files {
+		suite, err := tester.Load(file)
+		if err != nil {
+			for _, line := range strings.Split(err.Error(), "\n") {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", relativePath(file), line)
+			}
+			failed = true
+			continue
+		}
+		results = append(results, suite.Run(filter)...)
+	}
+	if finish != nil {
+		finish()
+	}
+
+	if err := write(os.Stdout, results, relativePath); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if failed || !tester.Count(results).OK() {
+		os.Exit(1)
+	}
+}
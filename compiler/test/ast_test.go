@@ -0,0 +1,81 @@
+package test
+
+import (
+	"fmt"
+	"lynx/pkg/ast"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"slices"
+	"testing"
+)
+
+func parseProgram(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	return program
+}
+
+// TestStartToken tests that nodes beginning with an operand start there
+func TestStartToken(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1", "1:1 let"},
+		{"  a.b[0] = 2", "1:3 a"},
+		{"(x + 1) * 2", "1:2 x"},
+		{"f(1).g(2)", "1:1 f"},
+		{"xs |> map(fn(v) { v })", "1:1 xs"},
+		{"v => v + 1", "1:1 v"},
+		{"if x { 1 }", "1:1 if"},
+	}
+
+	for _, tt := range tests {
+		program := parseProgram(t, tt.input)
+		tok := ast.StartToken(program.Statements[0])
+		if got := fmt.Sprintf("%d:%d %s", tok.Line, tok.Column, tok.Literal); got != tt.expected {
+			t.Errorf("For %q expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+}
+
+// TestHoist tests which statements bind names in the enclosing scope
+func TestHoist(t *testing.T) {
+	program := parseProgram(t, `
+let a = 1
+export fn b() { let hidden = 1 }
+if a { let c = 2 } else { let d = 3 }
+while false { let e = 4 }
+switch a {
+    case 1: { let f = 5 }
+    case v: { let g = 6 }
+}
+catch { let h = 7 } on err { let skipped = 8 } finally { let i = 9 }
+m = 10
+class K { n = fn() { 1 } }
+`)
+	names := func(stmts []ast.Statement, inClass bool) []string {
+		out := []string{}
+		ast.Hoist(stmts, inClass, func(stmt ast.Statement) {
+			switch n := stmt.(type) {
+			case *ast.VarStatement:
+				out = append(out, n.Name.Value)
+			case *ast.Assignment:
+				out = append(out, n.Name.String())
+			case *ast.Class:
+				out = append(out, n.Name.Value)
+			}
+		})
+		return out
+	}
+
+	expected := []string{"a", "b", "c", "d", "e", "f", "h", "i", "K"}
+	if got := names(program.Statements, false); !slices.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	class := program.Statements[len(program.Statements)-1].(*ast.Class)
+	if got := names(class.Body.Statements, true); !slices.Equal(got, []string{"n"}) {
+		t.Errorf("Expected class body assignments to declare, got %v", got)
+	}
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"lynx/pkg/cover"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const coveredProgram = `@"./util"
+
+fn classify(n) {
+    if n < 0 {
+        return "negative"
+    }
+    switch n % 3 {
+        case 0:
+            return "fizz"
+        case 1:
+            return "one"
+    }
+    return "other"
+}
+
+classify(3)
+classify(4)
+util.double(2)
+`
+
+const coveredModule = `class Box {
+    let init = fn(v) {
+        self.v = v
+    }
+}
+
+let double = fn(x) {
+    if x > 100 {
+        error "too big"
+    } else {
+        x * 2
+    }
+}
+`
+
+func coverProgram(t *testing.T) *cover.Coverage {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	writeFiles(t, dir, map[string]string{"main.lynx": coveredProgram, "util.lynx": coveredModule})
+
+	p := parser.New(lexer.New(coveredProgram))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	evaluator.RegisterBuiltins()
+	env := object.New(dir)
+	env.File = filepath.Join(dir, "main.lynx")
+
+	c := cover.New()
+	c.Start()
+	testIntegerObject(t, evaluator.Eval(program, env), 4)
+	c.Stop()
+	return c
+}
+
+func TestCoverage(t *testing.T) {
+	files := coverProgram(t).Files()
+	if len(files) != 2 {
+		t.Fatalf("expected coverage of 2 files, got %d", len(files))
+	}
+
+	tests := []struct {
+		name     string
+		lines    map[int]int
+		branches [][]int
+		missed   string
+	}{
+		{
+			"main.lynx",
+			map[int]int{1: 1, 3: 1, 4: 2, 5: 0, 7: 2, 9: 1, 11: 1, 13: 0, 16: 1, 17: 1, 18: 1},
+			[][]int{{0, 2}, {1, 1, 0}},
+			"5, 13",
+		},
+		{
+			"util.lynx",
+			// A class body's declarations are not statements that run
+			map[int]int{1: 1, 3: 0, 7: 1, 8: 1, 9: 0, 11: 1},
+			[][]int{{0, 1}},
+			"3, 9",
+		},
+	}
+
+	for i, tt := range tests {
+		f := files[i]
+		if filepath.Base(f.Path) != tt.name {
+			t.Errorf("expected file %s, got %s", tt.name, f.Path)
+			continue
+		}
+		if len(f.Lines) != len(tt.lines) {
+			t.Errorf("%s: expected %d statement lines, got %v", tt.name, len(tt.lines), f.Lines)
+		}
+		for line, count := range tt.lines {
+			if got, ok := f.Lines[line]; !ok || got != count {
+				t.Errorf("%s:%d: expected %d executions, got %d (statement line %v)", tt.name, line, count, got, ok)
+			}
+		}
+		if len(f.Branches) != len(tt.branches) {
+			t.Fatalf("%s: expected %d branches, got %d", tt.name, len(tt.branches), len(f.Branches))
+		}
+		for j, b := range f.Branches {
+			if compact(b.Taken) != compact(tt.branches[j]) {
+				t.Errorf("%s: branch %d at line %d taken %v, want %v", tt.name, j, b.Line, b.Taken, tt.branches[j])
+			}
+		}
+		if missed := strings.Join(f.Missed(), ", "); missed != tt.missed {
+			t.Errorf("%s: expected missed lines %s, got %s", tt.name, tt.missed, missed)
+		}
+	}
+}
+
+func TestCoverageReports(t *testing.T) {
+	c := coverProgram(t)
+	base := func(path string) string { return filepath.Base(path) }
+
+	var text strings.Builder
+	c.WriteText(&text, base)
+	for _, want := range []string{
+		"main.lynx                                lines  81.8% (9/11)  branches  60.0% (3/5)\n    not run: 5, 13\n",
+		"total                                    lines  76.5% (13/17)  branches  57.1% (4/7)\n",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("expected text report to contain %q, got\n%s", want, text.String())
+		}
+	}
+
+	var lcov strings.Builder
+	if err := c.WriteLCOV(&lcov); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"TN:\nSF:", "main.lynx\nBRDA:4,0,0,0\nBRDA:4,0,1,2\nBRDA:7,1,0,1\nBRDA:7,1,1,1\nBRDA:7,1,2,0\nBRF:5\nBRH:3\nDA:1,1\nDA:3,1\n",
+		"DA:13,0\nDA:16,1\nDA:17,1\nDA:18,1\nLF:11\nLH:9\nend_of_record\n",
+	} {
+		if !strings.Contains(lcov.String(), want) {
+			t.Errorf("expected LCOV to contain %q, got\n%s", want, lcov.String())
+		}
+	}
+
+	var html strings.Builder
+	if err := c.WriteHTML(&html, base); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<a href="#file0">main.lynx</a>`,
+		`<div class="line missed"><span class="number">5</span><span class="count">0</span><pre>        return &#34;negative&#34;</pre></div>`,
+		`<div class="line partial" title="if branches taken 0/2 times"><span class="number">4</span>`,
+		`<div class="line "><span class="number">2</span><span class="count"></span><pre></pre></div>`,
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("expected HTML to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lynx/pkg/debug"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedFrontend records where the program stops and resumes it with
+// the next of its actions
+type scriptedFrontend struct {
+	actions []debug.Action
+	stops   []string
+	inspect func(d *debug.Debugger)
+}
+
+func (f *scriptedFrontend) Stopped(d *debug.Debugger, reason string) debug.Action {
+	frame := d.Frames()[0]
+	f.stops = append(f.stops, fmt.Sprintf("%s %s:%d %s", reason, filepath.Base(frame.File), frame.Line, frame.Name))
+	if f.inspect != nil {
+		f.inspect(d)
+	}
+	if len(f.actions) == 0 {
+		return debug.Continue
+	}
+	action := f.actions[0]
+	f.actions = f.actions[1:]
+	return action
+}
+
+const debugProgram = `@"./lib"
+
+fn add(a, b) {
+    let sum = a + b
+    return sum
+}
+
+let total = 0
+for i in [1, 2, 3] {
+    total = add(total, i)
+}
+lib.twice(total)
+`
+
+func debugRun(t *testing.T, frontend debug.Frontend, setup func(d *debug.Debugger, main string), stopOnEntry bool) object.Object {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.lynx": debugProgram,
+		"lib.lynx":  "let twice = fn(x) {\n    return x * 2\n}\n",
+	})
+	main, _ := filepath.EvalSymlinks(filepath.Join(dir, "main.lynx"))
+
+	p := parser.New(lexer.New(debugProgram))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	env := object.New(filepath.Dir(main))
+	env.File = main
+
+	d := debug.New(frontend)
+	if setup != nil {
+		setup(d, main)
+	}
+	return d.Run(program, env, stopOnEntry)
+}
+
+func TestDebuggerStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(d *debug.Debugger, main string)
+		entry    bool
+		actions  []debug.Action
+		expected []string
+	}{
+		{
+			"step over from entry",
+			nil, true,
+			[]debug.Action{debug.StepOver, debug.StepOver, debug.StepOver, debug.StepOver, debug.StepOver},
+			[]string{"entry main.lynx:1 main", "step main.lynx:3 main", "step main.lynx:8 main",
+				"step main.lynx:9 main", "step main.lynx:10 main", "step main.lynx:10 main"},
+		},
+		{
+			"step in and out",
+			func(d *debug.Debugger, main string) { d.SetBreakpoint(main, 10, "i == 1") },
+			false,
+			[]debug.Action{debug.StepIn, debug.StepIn, debug.StepOut, debug.Stop},
+			[]string{"breakpoint main.lynx:10 main", "step main.lynx:4 add", "step main.lynx:5 add", "step main.lynx:10 main"},
+		},
+		{
+			"conditional breakpoint",
+			func(d *debug.Debugger, main string) { d.SetBreakpoint(main, 5, "a == 3") },
+			false, nil,
+			[]string{"breakpoint main.lynx:5 add"},
+		},
+		{
+			"breakpoint in an imported file",
+			func(d *debug.Debugger, main string) { d.SetBreakpoint("lib.lynx", 2, "") },
+			false,
+			[]debug.Action{debug.StepIn},
+			[]string{"breakpoint lib.lynx:2 twice"},
+		},
+	}
+
+	for _, tt := range tests {
+		frontend := &scriptedFrontend{actions: tt.actions}
+		debugRun(t, frontend, tt.setup, tt.entry)
+		if strings.Join(frontend.stops, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%s: got stops\n%s\nwant\n%s", tt.name, strings.Join(frontend.stops, "\n"), strings.Join(tt.expected, "\n"))
+		}
+	}
+}
+
+func TestDebuggerInspection(t *testing.T) {
+	var frames []string
+	var scopes []string
+	var value string
+	frontend := &scriptedFrontend{
+		actions: []debug.Action{debug.Stop},
+		inspect: func(d *debug.Debugger) {
+			for _, f := range d.Frames() {
+				frames = append(frames, fmt.Sprintf("%s:%d", f.Name, f.Line))
+			}
+			for _, s := range debug.Scopes(d.Frames()[0]) {
+				scopes = append(scopes, s.Name+" "+strings.Join(s.Env.Names(), ","))
+			}
+			val, err := d.Evaluate("[a, b, sum * 10]", d.Frames()[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			value = object.Repr(val)
+		},
+	}
+	result := debugRun(t, frontend, func(d *debug.Debugger, main string) { d.SetBreakpoint(main, 5, "b == 2") }, false)
+
+	if got := strings.Join(frames, " "); got != "add:5 main:10" {
+		t.Errorf("frames: got %s", got)
+	}
+	if got := strings.Join(scopes, "; "); got != "Locals a,b,sum; Globals add,lib,total" {
+		t.Errorf("scopes: got %s", got)
+	}
+	if value != "[1, 2, 30]" {
+		t.Errorf("evaluate: got %s", value)
+	}
+	if result != nil {
+		t.Errorf("expected a stopped program to have no result, got %v", result)
+	}
+}
+
+func TestDebuggerConsole(t *testing.T) {
+	var out strings.Builder
+	commands := "b 5 if b == 3\nb\nc\nbt\nvars\np sum + 1\nup\nlist\nbogus\nclear 5\nc\n"
+	result := debugRun(t, debug.NewConsole(strings.NewReader(commands), &out), nil, true)
+
+	for _, want := range []string{
+		"Stopped (entry) at ",
+		"main.lynx:1 in main\n>    1  @\"./lib\"",
+		"Breakpoint at ",
+		"Stopped (breakpoint) at ",
+		"* #0 add at ",
+		"  #1 main at ",
+		"Locals:\n  a = 3\n  b = 3\n  sum = 6\nGlobals:\n  add = fn add(a, b)\n",
+		"(debug) 7\n",
+		"#1 main at ",
+		"    9  for i in [1, 2, 3] {\n>   10      total = add(total, i)\n",
+		"Unknown command \"bogus\"",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected console output to contain %q, got\n%s", want, out.String())
+		}
+	}
+	if testIntegerObject(t, result, 12) == false {
+		t.Errorf("expected the program to finish")
+	}
+}
+
+// dapClient drives a debug adapter session over pipes. Messages are read
+// as they arrive, since the adapter writes events while handling requests.
+type dapClient struct {
+	t        *testing.T
+	in       io.WriteCloser
+	messages chan map[string]any
+	seq      int
+}
+
+func newDAPClient(t *testing.T, in io.WriteCloser, out io.Reader) *dapClient {
+	c := &dapClient{t: t, in: in, messages: make(chan map[string]any, 64)}
+	go func() {
+		r := bufio.NewReader(out)
+		for {
+			length := 0
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					close(c.messages)
+					return
+				}
+				line = strings.TrimSpace(line)
+				if line == "" {
+					break
+				}
+				if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+					length, _ = strconv.Atoi(value)
+				}
+			}
+			body := make([]byte, length)
+			io.ReadFull(r, body)
+			var message map[string]any
+			json.Unmarshal(body, &message)
+			c.messages <- message
+		}
+	}()
+	return c
+}
+
+func (c *dapClient) send(command string, args any) {
+	c.seq++
+	body, _ := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (c *dapClient) receive() map[string]any {
+	select {
+	case m, ok := <-c.messages:
+		if !ok {
+			c.t.Fatal("the adapter closed its output")
+		}
+		return m
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the adapter")
+	}
+	return nil
+}
+
+// await skips messages until one for which match is true
+func (c *dapClient) await(match func(m map[string]any) bool) map[string]any {
+	for {
+		if m := c.receive(); match(m) {
+			return m
+		}
+	}
+}
+
+func (c *dapClient) request(command string, args any) map[string]any {
+	c.send(command, args)
+	return c.await(func(m map[string]any) bool { return m["type"] == "response" && m["command"] == command })
+}
+
+func isEvent(name string) func(map[string]any) bool {
+	return func(m map[string]any) bool { return m["type"] == "event" && m["event"] == name }
+}
+
+func TestDebuggerDAP(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.lynx": "let greet = fn(name) {\n    let message = \"hi \" ++ name\n    println(message)\n}\ngreet(\"ada\")\n",
+	})
+	main := filepath.Join(dir, "main.lynx")
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	go debug.NewDAP(serverIn, serverOut).Run()
+	c := newDAPClient(t, clientOut, clientIn)
+
+	c.request("initialize", map[string]any{"adapterID": "lynx"})
+	c.request("launch", map[string]any{"program": main})
+	bps := c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": main},
+		"breakpoints": []any{map[string]any{"line": 3}, map[string]any{"line": 4, "condition": "(("}},
+	})
+	if got := compact(bps["body"]); !strings.Contains(got, `{"line":3,"verified":true}`) || !strings.Contains(got, `"verified":false`) {
+		t.Errorf("setBreakpoints: got %s", got)
+	}
+	c.request("configurationDone", nil)
+
+	stopped := c.await(isEvent("stopped"))
+	if reason := stopped["body"].(map[string]any)["reason"]; reason != "breakpoint" {
+		t.Errorf("expected to stop at the breakpoint, got %v", reason)
+	}
+	trace := c.request("stackTrace", map[string]any{"threadId": 1})
+	frames := trace["body"].(map[string]any)["stackFrames"].([]any)
+	top := frames[0].(map[string]any)
+	if len(frames) != 2 || top["name"] != "greet" || top["line"] != 3.0 {
+		t.Errorf("stackTrace: got %s", compact(frames))
+	}
+
+	scopes := c.request("scopes", map[string]any{"frameId": 0})["body"].(map[string]any)["scopes"].([]any)
+	locals := scopes[0].(map[string]any)["variablesReference"]
+	variables := c.request("variables", map[string]any{"variablesReference": locals})
+	if got := compact(variables["body"]); !strings.Contains(got, `{"name":"message","type":"string","value":"\"hi ada\"","variablesReference":0}`) {
+		t.Errorf("variables: got %s", got)
+	}
+	evaluated := c.request("evaluate", map[string]any{"expression": "name.upper()", "frameId": 0})
+	if got := evaluated["body"].(map[string]any)["result"]; got != `"ADA"` {
+		t.Errorf("evaluate: got %v", got)
+	}
+
+	c.request("continue", map[string]any{"threadId": 1})
+	output := c.await(isEvent("output"))
+	if got := output["body"].(map[string]any)["output"]; got != "hi ada\n" {
+		t.Errorf("expected the program's output as an event, got %v", got)
+	}
+	exited := c.await(isEvent("exited"))
+	if code := exited["body"].(map[string]any)["exitCode"]; code != 0.0 {
+		t.Errorf("exit code: got %v", code)
+	}
+	c.await(isEvent("terminated"))
+	c.request("disconnect", nil)
+}
@@ -0,0 +1,197 @@
+package test
+
+import (
+	"encoding/json"
+	"io"
+	"lynx/pkg/ast"
+	"lynx/pkg/doc"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"os"
+	"strings"
+	"testing"
+)
+
+const documentedModule = `/// Shapes and their areas.
+
+/// A square.
+/// Its sides are equal.
+export class Square {
+    /// Makes a square with sides of length side.
+    let init = fn(side) {
+        self.side = side
+    }
+    /// The square's area.
+    get area = fn() { self.side * self.side }
+    let _scale = fn(by) { self.side = self.side * by }
+}
+
+// Not a doc comment
+export let unit = 1
+
+/// Detached by the blank line
+
+/// Doubles x.
+export fn double(x: int) -> int {
+    x * 2 /// trailing, not a doc comment
+}
+
+let hidden = fn() { 0 }
+`
+
+func TestDocComments(t *testing.T) {
+	p := parser.New(lexer.New(documentedModule))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	class := program.Statements[0].(*ast.ExportStatement).Statement.(*ast.Class)
+	if class.Doc != "A square.\nIts sides are equal." {
+		t.Errorf("class doc: got %q", class.Doc)
+	}
+	init := class.Body.Statements[0].(*ast.VarStatement)
+	if init.Doc != "Makes a square with sides of length side." || init.Value.(*ast.FunctionLiteral).Doc != init.Doc {
+		t.Errorf("method doc: got %q", init.Doc)
+	}
+	if area := class.Body.Statements[1].(*ast.Accessor); area.Doc != "The square's area." {
+		t.Errorf("getter doc: got %q", area.Doc)
+	}
+	if unit := program.Statements[1].(*ast.ExportStatement).Statement.(*ast.VarStatement); unit.Doc != "" {
+		t.Errorf("expected a // comment not to be a doc, got %q", unit.Doc)
+	}
+	double := program.Statements[2].(*ast.ExportStatement).Statement.(*ast.VarStatement)
+	if double.Doc != "Doubles x." {
+		t.Errorf("function doc: got %q", double.Doc)
+	}
+	if hidden := program.Statements[3].(*ast.VarStatement); hidden.Doc != "" {
+		t.Errorf("expected no doc after a trailing comment, got %q", hidden.Doc)
+	}
+
+	members := "/// Documented.\nlet m = @module()\n/// Triples x.\nm.triple = fn(x) { x * 3 }\n"
+	p = parser.New(lexer.New(members))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if a := program.Statements[1].(*ast.Assignment); a.Doc != "Triples x." || a.Value.(*ast.FunctionLiteral).Doc != a.Doc {
+		t.Errorf("module member doc: got %q", a.Doc)
+	}
+}
+
+// captureStdout returns what f prints
+func captureStdout(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	f()
+	os.Stdout = stdout
+	w.Close()
+	return <-out
+}
+
+func TestHelp(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shapes.lynx": documentedModule,
+		"geo.lynx":    "/// Geometry.\nlet geo = @module()\n/// Circle area, after\n/// J. Smith. Uses 3 for pi.\ngeo.area = fn(r) { 3 * r * r }\ngeo._secret = 1\n/// A full turn.\ngeo.tau = 6\ngeo.half = 3\n",
+		"units.lynx":  "/// Seconds in a minute.\nlet minute = 60\nlet hour = 3600\n",
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`@"./shapes"
+help(shapes.double)`, "double(x: int) -> int\n\nDoubles x.\n"},
+		{`@"./shapes"
+help(shapes.Square)`, "class Square\n\nA square.\nIts sides are equal.\n\nMethods:\n    init(side)  Makes a square with sides of length side.\n"},
+		{`@"./geo"
+help(geo)`, "module geo\n\nGeometry.\n\nMembers:\n    area(r)  Circle area, after J. Smith.\n    half\n    tau      A full turn.\n"},
+		{`@"./units"
+help(units)`, "module units\n\nMembers:\n    hour\n    minute  Seconds in a minute.\n"},
+		{`@"./geo"
+help(geo.area)`, "geo.area(r)\n\nCircle area, after\nJ. Smith. Uses 3 for pi.\n"},
+		{`help(fn(a, b) { a })`, "fn(a, b)\n"},
+		{`help(len)`, "len(...)\n\nBuilt-in function.\n"},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		evaluator.RegisterBuiltins()
+		evaluator.ResetModules()
+		var result object.Object
+		out := captureStdout(t, func() { result = evaluator.Eval(program, object.New(dir)) })
+		testNullObject(t, result)
+		if out != tt.expected {
+			t.Errorf("%s: expected\n%q, got\n%q", tt.input, tt.expected, out)
+		}
+	}
+
+	if err, ok := testEval("help(1)").(*object.Error); !ok || err.Message != "help: no documentation for int" {
+		t.Errorf("expected an error for help on an integer, got %v", err)
+	}
+}
+
+func TestDocExtract(t *testing.T) {
+	shapes, errs := doc.Source(documentedModule, "lib/shapes.lynx")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected parse errors %v", errs)
+	}
+	geo, _ := doc.Source("let unrelated = 1\n/// Geometry.\nlet geo = @module()\n/// Circle area.\ngeo.area = fn(r) { 3 * r * r }\ngeo._secret = 1\n", "geo.lynx")
+	modules := []*doc.Module{shapes, geo}
+
+	var markdown strings.Builder
+	if err := doc.WriteMarkdown(&markdown, modules); err != nil {
+		t.Fatal(err)
+	}
+	expected := "# shapes\n\nShapes and their areas.\n\n" +
+		"## `class shapes.Square`\n\nA square.\nIts sides are equal.\n\n" +
+		"### `Square.init(side)`\n\nMakes a square with sides of length side.\n\n" +
+		"### `get Square.area`\n\nThe square's area.\n\n" +
+		"## `shapes.unit`\n\n" +
+		"## `shapes.double(x: int) -> int`\n\nDoubles x.\n\n" +
+		"# geo\n\nGeometry.\n\n## `geo.area(r)`\n\nCircle area.\n"
+	if markdown.String() != expected {
+		t.Errorf("expected markdown\n%s\ngot\n%s", expected, markdown.String())
+	}
+
+	var out strings.Builder
+	if err := doc.WriteJSON(&out, modules); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []doc.Module
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	double := decoded[0].Members[2]
+	if double.Kind != doc.Function || double.Line != 21 || len(double.Params) != 1 ||
+		double.Params[0] != (doc.Param{Name: "x", Type: "int"}) || double.Returns != "int" {
+		t.Errorf("unexpected JSON for double: %+v", double)
+	}
+	if square := decoded[0].Members[0]; square.Kind != doc.Class || len(square.Members) != 2 || square.Members[1].Kind != doc.Getter {
+		t.Errorf("unexpected JSON for Square: %+v", square)
+	}
+
+	var html strings.Builder
+	if err := doc.WriteHTML(&html, modules); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<nav><a href="#shapes">shapes</a><a href="#geo">geo</a></nav>`,
+		`<div class="member" id="shapes.double">` + "\n" + `<h2><code>shapes.double(x: int) -&gt; int</code></h2>` + "\n<p>Doubles x.</p>",
+		"<p>A square.\nIts sides are equal.</p>",
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("expected HTML to contain %q, got\n%s", want, html.String())
+		}
+	}
+}
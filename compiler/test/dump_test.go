@@ -0,0 +1,136 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"lynx/pkg/dump"
+	"lynx/pkg/lexer"
+	"lynx/pkg/parser"
+	"strings"
+	"testing"
+)
+
+func TestDumpTokens(t *testing.T) {
+	tokens, errs := dump.Tokens("let s = \"a\nb\"\nx <= 1.5")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	var out strings.Builder
+	if err := dump.WriteTokens(&out, tokens); err != nil {
+		t.Fatal(err)
+	}
+	expected := `1:1-1:4      LET        "let"
+1:5-1:6      IDENT      "s"
+1:7-1:8      =          "="
+1:9-2:3      STRING     "a\nb"
+3:1-3:2      IDENT      "x"
+3:3-3:5      <=         "<="
+3:6-3:9      FLOAT      "1.5"
+3:9-3:9      EOF        ""
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	_, errs = dump.Tokens("x # y")
+	if err := dump.WriteTokensJSON(&out, tokens[:1], errs); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Version int
+		Tokens  []map[string]any
+		Errors  []dump.Error
+	}
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if decoded.Version != dump.Version || len(decoded.Tokens) != 1 || decoded.Tokens[0]["type"] != "LET" {
+		t.Errorf("unexpected tokens JSON %s", out.String())
+	}
+	if len(decoded.Errors) != 1 || decoded.Errors[0].Type != "LexicalError" || decoded.Errors[0].Column != 3 {
+		t.Errorf("expected a lexical error at column 3, got %+v", decoded.Errors)
+	}
+}
+
+func TestDumpTree(t *testing.T) {
+	tree, errs := dump.Parse("let ok = a and b == c\nf(x)[0].y({\"k\": -1}, (2))\nconst no = false\n")
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors %v", errs)
+	}
+
+	var out strings.Builder
+	if err := dump.WriteTree(&out, tree); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Program 1:1-3:17
+  statements[0]: VarStatement 1:1-1:22
+    name: Identifier 1:5-1:7 value="ok"
+    value: InfixExpression 1:10-1:22 operator="=="
+      left: InfixExpression 1:10-1:17 operator="and"
+        left: Identifier 1:10-1:11 value="a"
+        right: Identifier 1:16-1:17 value="b"
+      right: Identifier 1:21-1:22 value="c"
+  statements[1]: ExpressionStatement 2:1-2:26
+    expression: MethodCall 2:1-2:26
+      object: IndexExpression 2:1-2:8
+        left: CallExpression 2:1-2:5
+          function: Identifier 2:1-2:2 value="f"
+          arguments[0]: Identifier 2:3-2:4 value="x"
+        index: IntegerLiteral 2:6-2:7 value=0
+      method: Identifier 2:9-2:10 value="y"
+      arguments[0]: HashLiteral 2:11-2:20
+        pairs[0].key: StringLiteral 2:12-2:15 value="k"
+        pairs[0].value: PrefixExpression 2:17-2:19 operator="-"
+          right: IntegerLiteral 2:18-2:19 value=1
+      arguments[1]: IntegerLiteral 2:23-2:24 value=2
+  statements[2]: VarStatement 3:1-3:17 isConst
+    name: Identifier 3:7-3:9 value="no"
+    value: Boolean 3:12-3:17 value=false
+`
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+
+	tree, errs = dump.Parse("let n: int? = 1\nlet = 2")
+	if len(errs) == 0 {
+		t.Fatal("expected a parse error")
+	}
+	out.Reset()
+	if err := dump.WriteTreeJSON(&out, tree, errs); err != nil {
+		t.Fatal(err)
+	}
+	// Fields keep the order of the ast struct, after the node's type and span
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, []byte(out.String())); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	want := `{"node":"VarStatement","start":{"line":1,"column":1},"end":{"line":1,"column":16},"name":{"node":"Identifier",`
+	if !strings.Contains(compact.String(), want) {
+		t.Errorf("expected JSON to contain\n%s\ngot\n%s", want, compact.String())
+	}
+	var decoded struct {
+		Version int
+		Program map[string]any
+		Errors  []dump.Error
+	}
+	if err := json.Unmarshal([]byte(out.String()), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	stmt := decoded.Program["statements"].([]any)[0].(map[string]any)
+	annotation := stmt["type"].(map[string]any)
+	if annotation["node"] != "TypeAnnotation" || annotation["name"] != "int" || annotation["nullable"] != true || stmt["isConst"] != false {
+		t.Errorf("unexpected JSON for the declaration: %v", stmt)
+	}
+	if decoded.Version != dump.Version || len(decoded.Errors) == 0 || decoded.Errors[0].Type != "SyntaxError" || decoded.Errors[0].Line != 2 {
+		t.Errorf("expected a syntax error on line 2, got %+v", decoded.Errors)
+	}
+
+	// Ends are recorded only when asked for
+	p := parser.New(lexer.New("x + 1"))
+	program := p.ParseProgram()
+	if end, ok := p.End(program.Statements[0]); ok {
+		t.Errorf("expected no recorded end without RecordEnds, got %v", end)
+	}
+}
@@ -2,7 +2,16 @@ package test
 
 
 import (
+	"lynx/pkg/ast"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
 	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"lynx/std"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -127,3 +136,707 @@ func TestEvaluatorIfElseExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestEvaluatorArrowFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let double = x => x * 2\ndouble(4)", 8},
+		{"let add = (a, b) => a + b\nadd(2, 3)", 5},
+		{"let answer = () => 42\nanswer()", 42},
+		{"let inc = x => { let y = x + 1\nreturn y }\ninc(1)", 2},
+		{"let apply = fn(f, v) { return f(v) }\napply(x => x * x, 7)", 49},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, tt.input)
+		if !testIntegerObject(t, evaluated, tt.expected) {
+			t.Errorf("Failed for input: %s", tt.input)
+		}
+	}
+}
+
+func TestEvaluatorPipePlaceholder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let sub = (a, b) => a - b\n10 |> sub(3)", 7},
+		{"let sub = (a, b) => a - b\n10 |> sub(3, _)", -7},
+		{"let sub = (a, b) => a - b\n10 |> sub(_, _)", 0},
+		{`"a,b,c" |> _.split(",") |> _.len()`, 3},
+		{"let m = @module()\nm.sub = (a, b) => a - b\n10 |> m.sub(1, _)", -9},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, tt.input)
+		if !testIntegerObject(t, evaluated, tt.expected) {
+			t.Errorf("Failed for input: %s", tt.input)
+		}
+	}
+}
+
+func TestEvaluatorOperatorOverloading(t *testing.T) {
+	evaluator.RegisterBuiltins()
+
+	class := `
+class Money {
+	let init = fn(cents) { self.cents = cents }
+	let add = fn(other) { return Money(self.cents + other.cents) }
+	let eq = fn(other) { return self.cents == other.cents }
+	let lt = fn(other) { return self.cents < other.cents }
+	let index = fn(i) { return self.cents * i }
+	let len = fn() { return self.cents }
+	let contains = fn(v) { return v == self.cents }
+	let call = fn(n) { return self.cents + n }
+	let hash = fn() { return self.cents }
+	let str = fn() { return "$" ++ str(self.cents) }
+}
+class Point {
+	let init = fn(x, y) {
+		self.x = x
+		self.y = y
+	}
+	let hash = fn() { return self.x }
+	let eq = fn(other) { return (self.x == other.x) and (self.y == other.y) }
+}
+class Cents {
+	let init = fn(cents) { self.cents = cents }
+	let hash = fn() { return self.cents }
+	let eq = fn(other) { return self.cents == other.cents }
+}
+`
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"(Money(1) + Money(2)).cents", 3},
+		{"Money(5) == Money(5)", true},
+		{"Money(5) != Money(6)", true},
+		{"Money(1) < Money(2)", true},
+		{"Money(2) > Money(1)", true},
+		{"Money(4)[2]", 8},
+		{"len(Money(7))", 7},
+		{"3 in Money(3)", true},
+		{"Money(3)(4)", 7},
+		{"{Money(9): 1}[Money(9)]", 1},
+		{"let h = {Point(1, 2): 1, Point(1, 3): 2}\nh[Point(1, 3)] * 10 + h[Point(1, 2)]", 21},
+		{"let h = {Point(1, 2): 1}\nh[Point(1, 2)] = 5\nh[Point(1, 2)]", 5},
+		{"Point(1, 99) in {Point(1, 2): 1}", false},
+		{"Cents(1) in {Money(1): 1}", false},
+		{`str(Money(12)) == "$12"`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, class+tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	errors := map[string]string{
+		"{Point(1, 2): 1}[Point(1, 99)]": "key not found: INSTANCE",
+		"class H {\n\tlet hash = fn() { return 1 }\n}\n{H(): 1}": "unusable as hash key: H defines hash but not eq",
+	}
+	for input, expected := range errors {
+		err, ok := testEvalDebug(t, class+input).(*object.Error)
+		if !ok || err.Message != expected {
+			t.Errorf("%s: expected error %q, got %v", input, expected, err)
+		}
+	}
+}
+
+func TestEvaluatorClassModel(t *testing.T) {
+	classes := `
+class Shape {
+	let sides = 0
+	static let created = 0
+	const UNIT = "cm"
+	let init = fn(name) {
+		self.name = name
+		Shape.created = Shape.created + 1
+	}
+	let describe = fn() { return self.name }
+	static let named = fn(name) { return Shape(name) }
+}
+class Square(Shape) {
+	let sides = 4
+	let init = fn(size) {
+		super.init("square")
+		self.size = size
+	}
+	let describe = fn() { return super.describe() ++ "!" }
+}
+`
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"Square(2).size", 2},
+		{"Square(2).sides", 4},
+		{"Shape(\"x\").sides", 0},
+		{"Square(2).describe() == \"square!\"", true},
+		{"Shape.UNIT == \"cm\"", true},
+		{"Square(1)\nSquare(1)\nShape.created", 2},
+		{"Shape.named(\"circle\").name == \"circle\"", true},
+		{"Square(1) instanceof Shape", true},
+		{"Shape(\"x\") instanceof Square", false},
+		{"1 instanceof Shape", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, classes+tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	errors := []string{
+		"Shape.UNIT = \"m\"",
+		"super.describe()",
+		"Shape.missing",
+	}
+
+	for _, input := range errors {
+		evaluated := testEval(classes + input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestEvaluatorClassAccessors(t *testing.T) {
+	classes := `
+class Temp {
+	let _celsius = 0
+	private let log = []
+	get celsius = fn() { return self._celsius }
+	set celsius = fn(value) {
+		self.log = self.log.push(value)
+		self._celsius = value
+	}
+	get fahrenheit = fn() { return self._celsius * 9 / 5 + 32 }
+	let changes = fn() { return len(self.log) }
+	private let reset = fn() { self._celsius = 0 }
+	let clear = fn() { self.reset() }
+}
+class Oven(Temp) {
+	let raw = fn() { return self._celsius }
+}
+let t = Temp()
+`
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"t.celsius", 0},
+		{"t.celsius = 100\nt.fahrenheit", 212},
+		{"t.celsius = 5\nt.celsius = 10\nt.changes()", 2},
+		{"t.celsius = 5\nt.clear()\nt.celsius", 0},
+		{"let o = Oven()\no.celsius = 40\no.raw()", 40},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, classes+tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	errors := []string{
+		"t.fahrenheit = 1",
+		"t._celsius",
+		"t._celsius = 1",
+		"t.log",
+		"t.reset()",
+		"t |> _.reset()",
+	}
+
+	for _, input := range errors {
+		evaluated := testEval(classes + input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestEvaluatorTraits(t *testing.T) {
+	evaluator.RegisterBuiltins()
+
+	traits := `
+trait Comparable {
+	compare(other)
+	lt(other) { return self.compare(other) < 0 }
+}
+trait Named { name() }
+class Money impl Comparable, Named {
+	let init = fn(cents) { self.cents = cents }
+	let compare = fn(other) { return self.cents - other.cents }
+	let name = fn() { return "money" }
+}
+class Cents(Money) {}
+`
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"Money(1) < Money(2)", true},
+		{"Money(3).lt(Money(2))", false},
+		{"implements(Money(1), Comparable)", true},
+		{"implements(Cents(1), Named)", true},
+		{"Money(1) instanceof Named", true},
+		{"implements(1, Named)", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, traits+tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	errors := []string{
+		"class Broken impl Comparable {}",
+		"class Arity impl Named { let name = fn(x) { x } }",
+		"class Unknown impl Missing {}",
+	}
+
+	for _, input := range errors {
+		evaluated := testEval(traits + input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+
+	// Sorting is stable, so Cents(1) stays ahead of the equal Money(1)
+	sorted := testEvalInDir(t, t.TempDir(), traits+`@array
+let sorted = array.sortComparable([Money(3), Cents(1), Money(2), Money(1)])
+str(sorted[0] instanceof Cents) ++ " " ++ array.join(array.map(sorted, fn(m) { m.cents }), ",")`)
+	if sorted.Inspect() != "true 1,1,2,3" {
+		t.Errorf("Expected instances sorted by compare, got %s", sorted.Inspect())
+	}
+}
+
+func TestEvaluatorExceptions(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	prelude := `
+class AppError {
+	let init = fn(message) { self.message = message }
+}
+class ValueError(AppError) {
+	let init = fn(message, value) {
+		super.init(message)
+		self.value = value
+	}
+}
+let got = 0
+`
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"catch { error ValueError(\"bad\", 7) } on e: AppError { got = e.value }", 7},
+		{"catch { error ValueError(\"bad\", 7) } on e: TypeError { got = 1 } on e { got = 2 }", 2},
+		{"catch { error \"boom\" } on e { got = (e.message == \"boom\") and (e.type == \"Error\") }", true},
+		{"catch { 1 / 0 } on e: RuntimeError { got = e.type == \"RuntimeError\" }", true},
+		{"catch { error exception(\"IOError\", \"disk\", {\"code\": 5}) } on e: IOError { got = e.code }", 5},
+		{"catch { catch { error \"x\" } on e { error exception(\"Wrap\", \"y\", null, e) } } on e { got = e.cause.message == \"x\" }", true},
+		{"catch { catch { error \"x\" } on e: Exception { error e } } on e { got = e.type == \"Error\" }", true},
+		{"catch { 1 } on e { got = 0 } finally { got = 1 }", 1},
+		{"catch { catch { error \"x\" } finally { got = 2 } } on e { got = got + 1 }", 3},
+		{"let f = fn() { catch { return 1 } finally { got = 3 } }\ngot = f() + got", 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, prelude+tt.input+"\ngot")
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+
+	unhandled := testEval(prelude + "catch { error ValueError(\"bad\", 1) } on e: TypeError { 1 }")
+	errObj, ok := unhandled.(*object.Error)
+	if !ok || errObj.Exception == nil || errObj.Exception.Kind != "ValueError" {
+		t.Errorf("Expected unhandled ValueError, got=%T (%+v)", unhandled, unhandled)
+	}
+}
+
+// bogusStatement is a node the evaluator does not know, standing in for an
+// interpreter bug
+type bogusStatement struct{ ast.ExpressionStatement }
+
+func TestEvaluatorInternalErrorsNotCaught(t *testing.T) {
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.CatchStatement{
+			Body: &ast.BlockStatement{Statements: []ast.Statement{&bogusStatement{}}},
+			Handlers: []*ast.CatchHandler{{
+				ErrorVar: &ast.Identifier{Value: "e"},
+				Body:     &ast.BlockStatement{},
+			}},
+		},
+	}}
+
+	result := evaluator.Eval(program, object.New("."))
+	errObj, ok := result.(*object.Error)
+	if !ok || !errObj.Internal {
+		t.Fatalf("Expected internal error to escape catch, got=%T (%+v)", result, result)
+	}
+}
+
+func TestEvaluatorDefer(t *testing.T) {
+	evaluator.RegisterBuiltins()
+
+	prelude := `
+let trace = []
+let record = fn(x) { trace = trace.push(x) }
+let work = fn(fail) {
+	defer record(1)
+	if fail {
+		defer { record(2) }
+		error "failed"
+	}
+	record(0)
+	return len(trace)
+}
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"work(false)\ntrace", "[0, 1]"},
+		{"catch { work(true) } on e { record(e.message) }\ntrace", "[2, 1, failed]"},
+		{"let r = work(false)\nrecord(r)\ntrace", "[0, 1, 1]"},
+		{"let f = fn() { defer error \"cleanup failed\"\n return 1 }\ncatch { f() } on e { record(e.message) }\ntrace", "[cleanup failed]"},
+		{"let f = fn() { for i in range(0, 3) { defer record(i) } }\nf()\ntrace", "[2, 1, 0]"},
+		{"let f = fn() { for i in range(0, 3) { defer { record(i * 10) } } }\nf()\ntrace", "[20, 10, 0]"},
+		{"let f = fn() { let x = 1\n defer record(x)\n defer { record(x) }\n x = 2 }\nf()\ntrace", "[2, 1]"},
+		{"let f = fn() { defer record(missing) }\ncatch { f() } on e { record(e.message) }\ntrace", "[\"missing\" is not defined]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, prelude+tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("For %q expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	program := prelude + "defer record(\"last\")\nrecord(\"first\")\nnull"
+	env := object.New(".")
+	l := lexer.New(program)
+	p := parser.New(l)
+	evaluator.Eval(p.ParseProgram(), env)
+	trace, _ := env.Get("trace")
+	if trace.Inspect() != "[first, last]" {
+		t.Errorf("Expected top-level defer to run at program exit, got %s", trace.Inspect())
+	}
+}
+
+func TestEvaluatorResults(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	prelude := `
+let half = fn(n) {
+	if n % 2 != 0 { return Err("odd") }
+	return Ok(n / 2)
+}
+let quarter = fn(n) {
+	let h = half(n)?
+	return half(h)
+}
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quarter(8)", "Ok(2)"},
+		{"quarter(6)", "Err(odd)"},
+		{"quarter(5)", "Err(odd)"},
+		{"quarter(6).unwrapOr(0)", "0"},
+		{"half(4).map(fn(x) { x * 10 })", "Ok(20)"},
+		{"half(3).map(fn(x) { x * 10 })", "Err(odd)"},
+		{"half(3).mapErr(fn(e) { e ++ \"!\" })", "Err(odd!)"},
+		{"half(4).isOk()", "true"},
+		{"half(3).isErr()", "true"},
+		{"half(4).unwrap()", "2"},
+		{"Ok(1) == Ok(1)", "true"},
+		{"Ok(1) != Err(1)", "true"},
+		{"attempt(fn(x) { 10 / x }, 2)", "Ok(5)"},
+		{"attempt(fn(x) { 10 / x }, 0)", "Err(RuntimeError: division by zero)"},
+		{"attempt(fn() { error \"bad\" }).isErr()", "true"},
+		{"let f = fn() { catch { Err(1)? } on e { 0 } }\nf()", "Err(1)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, prelude+tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("For %q expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []string{
+		"half(3).unwrap()",
+		"5?",
+		"Err(1)?",
+	}
+
+	for _, input := range errors {
+		evaluated := testEval(prelude + input)
+		if _, ok := evaluated.(*object.Error); !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", input, evaluated, evaluated)
+		}
+	}
+}
+
+func TestEvaluatorStrictTypes(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	evaluator.StrictTypes = true
+	defer func() { evaluator.StrictTypes = false }()
+
+	prelude := `
+class Shape { }
+class Circle(Shape) { }
+trait Named { name() }
+class Tag impl Named { let name = fn() { return "tag" } }
+fn area(r: float) -> float { return 3.0 * r * r }
+fn label(s: Shape, tag: str?) -> str { return "shape" }
+fn total(xs: array[int], h: hash[str, int]) -> int { return len(xs) + len(h) }
+fn pick(v: int | str) { v }
+fn broken() -> int { return "x" }
+fn greet(n: Named) -> str { return n.name() }
+fn kind(e: exception) -> str { return e.type }
+fn mystery(x: Missing) { x }
+`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"area(2)", "12.000000"},
+		{"label(Circle(), null)", "shape"},
+		{"label(Shape(), \"t\")", "shape"},
+		{"total([1, 2], {\"a\": 1})", "3"},
+		{"pick(\"a\")", "a"},
+		{"let f = fn(x) { x }\nf(1)", "1"},
+		{"greet(Tag())", "tag"},
+		{"let empty = fn() -> str? { }\nstr(empty() == null)", "true"},
+		{"let nothing = fn() -> null { }\nstr(nothing() == null)", "true"},
+		{"kind(exception(\"IOError\", \"disk\"))", "IOError"},
+		{"let got = null\ncatch { mystery(1) } on e: TypeError { got = e.message }\ngot", "unknown type Missing"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalDebug(t, prelude+tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("For %q expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"area(\"2\")", "TypeError: area: parameter r expects float, got str"},
+		{"label(5, null)", "TypeError: label: parameter s expects Shape, got int"},
+		{"label(Circle(), 1)", "TypeError: label: parameter tag expects str?, got int"},
+		{"total([1, \"x\"], {})", "TypeError: total: parameter xs expects array[int], got array[int | str]"},
+		{"total([], {\"a\": \"b\"})", "TypeError: total: parameter h expects hash[str, int], got hash"},
+		{"pick(true)", "TypeError: pick: parameter v expects int | str, got bool"},
+		{"broken()", "TypeError: broken: return value expects int, got str"},
+		{"greet(Shape())", "TypeError: greet: parameter n expects Named, got Shape"},
+		{"kind(\"IOError\")", "TypeError: kind: parameter e expects exception, got str"},
+		{"mystery(1)", "TypeError: unknown type Missing"},
+		{"let blank = fn() -> int { }\nblank()", "TypeError: blank: return value expects int, got null"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEval(prelude + tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestEvaluatorModules(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	dir := t.TempDir()
+	libDir := t.TempDir()
+	t.Setenv("LYNX_PATH", libDir)
+
+	writeFiles(t, dir, map[string]string{
+		"lib/util.lynx": `@"./helpers" as h
+export fn double(n) { h.twice(n) }
+export class Point { let init = fn(x) { self.x = x } }
+export const VERSION = "1.0"
+let secret = 42
+`,
+		"lib/helpers.lynx": "fn twice(n) { n * 2 }\n",
+		"strings.lynx":     "fn shout(s) { s + \"!\" }\n",
+	})
+	writeFiles(t, libDir, map[string]string{
+		"http/client.lynx": "export fn get(url) { \"GET \" + url }\n",
+		"strings.lynx":     "fn shout(s) { \"shadowed\" }\n",
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"@\"./lib/util\"\nutil.double(4)", "8"},
+		{"@\"./lib/util.lynx\"\nutil.Point(3).x", "3"},
+		{"@\"./lib/util\" as u\nu.VERSION", "1.0"},
+		{"@\"./lib/util\"(double)\ndouble(5)", "10"},
+		{"@http.client\nclient.get(\"x\")", "GET x"},
+		{"@http.client as http\nhttp.get(\"y\")", "GET y"},
+		{"@strings\nstrings.shout(\"hi\")", "hi!"},
+		{"@\"./lib/helpers\"\nhelpers.twice(2)", "4"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalInDir(t, dir, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("For %q expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"@\"./lib/util\"\nutil.secret", "module has no member: secret"},
+		{"@\"./lib/util\"(secret)", "module util does not have member: secret"},
+		{"@missing", "could not find module \"missing\""},
+		{"@\"./lib/nope\"", "could not find module \"./lib/nope\""},
+		{"let f = fn() { export let x = 1 }\nf()", "'export' can only be used at the top level of a module"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEvalInDir(t, dir, tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if !strings.HasPrefix(err.Message, tt.expected) {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.expected, err.Message)
+		}
+	}
+}
+
+func TestEvaluatorModuleCache(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a/util.lynx":  "fn name() { \"a\" }\n",
+		"b/util.lynx":  "fn name() { \"b\" }\n",
+		"counter.lynx": "let hits = 0\nexport fn hit() { hits = hits + 1\nhits }\n",
+		"self.lynx":    "@\"./self\"\n",
+		"ping.lynx":    "@\"./pong\"\n",
+		"pong.lynx":    "@\"./ping\"\n",
+		"broken.lynx":  "let x = 1 / 0\n",
+	})
+	if err := os.Symlink(filepath.Join(dir, "counter.lynx"), filepath.Join(dir, "alias.lynx")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"@\"./a/util\" as ua\n@\"./b/util\" as ub\nua.name() + ub.name()", "ab"},
+		{"@\"./counter\"\ncounter.hit()\n@\"./alias\"\nalias.hit()", "2"},
+		{"@\"./counter\" as c1\n@counter as c2\nc1 == c2", "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalInDir(t, dir, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("For %q expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	path := func(name string) string {
+		resolved, err := filepath.EvalSymlinks(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resolved
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"@\"./self\"", "import cycle: " + path("self.lynx") + " -> " + path("self.lynx")},
+		{"@\"./ping\"", "import cycle: " + path("ping.lynx") + " -> " + path("pong.lynx") + " -> " + path("ping.lynx")},
+		{"@\"./broken\"", "in module " + path("broken.lynx") + ": division by zero"},
+	}
+
+	for _, tt := range errors {
+		evaluated := testEvalInDir(t, dir, tt.input)
+		err, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("Expected error for %q, got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if !strings.HasSuffix(err.Message, tt.expected) {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.expected, err.Message)
+		}
+	}
+
+	evaluated := testEvalInDir(t, dir, "let got = \"\"\ncatch { @\"./broken\" } on e { got = e.message }\ngot")
+	if !strings.Contains(evaluated.Inspect(), "division by zero") {
+		t.Errorf("Expected module init error to be catchable, got %s", evaluated.Inspect())
+	}
+}
+
+func TestEvaluatorEmbeddedStd(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	t.Setenv("LYNX_PATH", t.TempDir())
+	dir := t.TempDir()
+	overrideDir := t.TempDir()
+	writeFiles(t, overrideDir, map[string]string{
+		"math.lynx": "fn sqrt(n) { \"local sqrt\" }\n",
+	})
+
+	evaluated := testEvalInDir(t, dir, "@math\nmath.sqrt(16)")
+	testFloatObject(t, evaluated, 4)
+
+	evaluated = testEvalInDir(t, overrideDir, "@math\nmath.sqrt(16)")
+	if evaluated.Inspect() != "local sqrt" {
+		t.Errorf("Expected local module to override std, got %s", evaluated.Inspect())
+	}
+
+	path, err := evaluator.FindModule("json", dir)
+	if err != nil || path != evaluator.StdRoot+"/json.lynx" {
+		t.Errorf("Expected json to resolve to the embedded std, got %q (%v)", path, err)
+	}
+
+	modules := std.Modules()
+	if !slices.Contains(modules, "json") || !slices.Contains(modules, "math") {
+		t.Errorf("Expected embedded std modules to include json and math, got %v", modules)
+	}
+}
+
+func TestEvaluatorScriptArgs(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	evaluator.ScriptArgs = []string{"one", "two words"}
+	defer func() { evaluator.ScriptArgs = nil }()
+
+	evaluated := testEvalInDir(t, t.TempDir(), "@os\nos.args()")
+	arr, ok := evaluated.(*object.Array)
+	if !ok || len(arr.Elements) != 2 {
+		t.Fatalf("Expected 2 script arguments, got %s", evaluated.Inspect())
+	}
+	if arr.Elements[1].Inspect() != "two words" {
+		t.Errorf("Expected the second argument to be \"two words\", got %s", arr.Elements[1].Inspect())
+	}
+}
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"lynx/pkg/format"
+	"os"
+	"testing"
+)
+
+func TestFormatCanonical(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let   x=1+2*3", "let x = 1 + 2 * 3\n"},
+		{"let x = (1 + 2) * 3", "let x = (1 + 2) * 3\n"},
+		{"let x = ((1 + 2) + 3)", "let x = 1 + 2 + 3\n"},
+		{"let x = 1 - (2 - 3)", "let x = 1 - (2 - 3)\n"},
+		{"let x = -(a + b)", "let x = -(a + b)\n"},
+		{"fn add(a,b){\nreturn a+b}", "fn add(a, b) {\n    return a + b\n}\n"},
+		{"fn add(a: int, b: int) -> int { a + b }", "fn add(a: int, b: int) -> int { a + b }\n"},
+		{"let f = x=>x*2", "let f = x => x * 2\n"},
+		{"let f = (a, b) => a + b", "let f = (a, b) => a + b\n"},
+		{"let h = {\"b\":1,\"a\":2}", "let h = {\"b\": 1, \"a\": 2}\n"},
+		{"let s = \"say \\\"hi\\\"\\n\"", "let s = \"say \\\"hi\\\"\\n\"\n"},
+		{"let r = \"\\d+\"", "let r = \"\\d+\"\n"},
+		{"if x {\na\n}\nelse {\nb\n}", "if x {\n    a\n} else {\n    b\n}\n"},
+		{"for v, i in xs { println(v) }", "for v, i in xs { println(v) }\n"},
+		{"let y = xs |> map(f) |> len", "let y = xs |> map(f) |> len\n"},
+		{"let x = 1\n\n\n\nlet y = 2", "let x = 1\n\nlet y = 2\n"},
+		{"@\"./util\" as u\n@math(sqrt, pow)", "@\"./util\" as u\n@math(sqrt, pow)\n"},
+		{
+			"class Point(Base) impl Show {\ninit = fn(x) { self.x = x }\n}",
+			"class Point(Base) impl Show {\n    init = fn(x) { self.x = x }\n}\n",
+		},
+		{
+			"trait Show {\nshow()\nlet name = fn() { \"thing\" }\n}",
+			"trait Show {\n    show()\n    let name = fn() { \"thing\" }\n}\n",
+		},
+		{
+			"switch x {\ncase 1: \"one\"\ncase 2 if y: {\n\"two\"\n}\ndefault: \"other\"\n}",
+			"switch x {\n    case 1: \"one\"\n    case 2 if y: {\n        \"two\"\n    }\n    default: \"other\"\n}\n",
+		},
+		{
+			"catch {\nerror \"bad\"\n} on e: ValueError {\nprintln(e)\n} finally {\ndone()\n}",
+			"catch {\n    error \"bad\"\n} on e: ValueError {\n    println(e)\n} finally {\n    done()\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			output, err := format.Source(tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output != tt.expected {
+				t.Errorf("Expected:\n%s\nGot:\n%s", tt.expected, output)
+			}
+		})
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `#!/usr/bin/env lynx
+// Header
+
+/* block
+   comment */
+fn f() {
+    // inside
+    return 1   // trailing
+}
+let xs = [
+    1,
+    2, // two
+    3
+]
+let h = {
+    // first
+    "a": 1
+}
+fn g() {
+    // nothing yet
+}
+// the end`
+
+	expected := `#!/usr/bin/env lynx
+// Header
+
+/* block
+   comment */
+fn f() {
+    // inside
+    return 1 // trailing
+}
+let xs = [
+    1,
+    2, // two
+    3
+]
+let h = {
+    // first
+    "a": 1
+}
+fn g() {
+    // nothing yet
+}
+// the end
+`
+	output, err := format.Source(input)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, output)
+	}
+}
+
+func TestFormatParseError(t *testing.T) {
+	if _, err := format.Source("let x = )"); err == nil {
+		t.Error("Expected an error for source that does not parse")
+	}
+}
+
+// Formatting is idempotent and does not change what a program does
+func TestFormatExamples(t *testing.T) {
+	source, err := os.ReadFile("../../examples/complete.lynx")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	once, err := format.Source(string(source))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	twice, err := format.Source(once)
+	if err != nil {
+		t.Fatalf("Unexpected error formatting the output: %v", err)
+	}
+	if once != twice {
+		t.Errorf("Formatting is not idempotent:\n%s\n---\n%s", once, twice)
+	}
+
+	programs := []string{
+		"fn fib(n) { if n < 2 { n } else { fib(n - 1) + fib(n - 2) } }\nfib(10)",
+		"let xs = [3, 1, 2]\nlet h = {\"a\": xs[0] * (2 + 1)}\nh[\"a\"] - (1 - 2)",
+		"let inc = x => x + 1\nlet xs = [1, 2]\nxs |> len |> inc",
+	}
+	for _, input := range programs {
+		formatted, err := format.Source(input)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		before, after := testEval(input), testEval(formatted)
+		if before.Inspect() != after.Inspect() {
+			t.Errorf("Formatting changed the result of %q: %s became %s", input, before.Inspect(), after.Inspect())
+		}
+	}
+}
@@ -201,6 +201,18 @@ func TestLexerTwoCharTokens(t *testing.T) {
 				{Type: token.PIPE, Literal: "|>", Line: 1, Column: 1},
 			},
 		},
+		{
+			input: "=>",
+			expected: []token.Token{
+				{Type: token.ARROW, Literal: "=>", Line: 1, Column: 1},
+			},
+		},
+		{
+			input: "?",
+			expected: []token.Token{
+				{Type: token.TRY, Literal: "?", Line: 1, Column: 1},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -252,6 +264,8 @@ func TestLexerIdentifiers(t *testing.T) {
 		{"default", token.DEFAULT},
 		{"on", token.ON},
 		{"catch", token.CATCH},
+		{"finally", token.FINALLY},
+		{"defer", token.DEFER},
 		{"error", token.ERROR},
 		{"true", token.TRUE},
 		{"false", token.FALSE},
@@ -351,6 +365,7 @@ func TestLexerComments(t *testing.T) {
 		{"let x = 1 /* comment */", 4},
 		{"/* multi\nline\ncomment */", 0},
 		{"let x = 1 /* comment */\nlet y = 2", 8},
+		{"/* a * b */ let x = 1", 4},
 	}
 
 	for _, tt := range tests {
@@ -365,6 +380,50 @@ func TestLexerComments(t *testing.T) {
 	}
 }
 
+func TestLexerTrivia(t *testing.T) {
+	input := `// header
+
+let x = 1 // one
+/* two */ let y = 2 /* done */
+
+
+z`
+	l := lexer.New(input)
+	collectTokens(l)
+
+	expected := []token.Comment{
+		{Text: "// header", Line: 1, Column: 1},
+		{Text: "// one", Line: 3, Column: 11, Trailing: true},
+		{Text: "/* two */", Line: 4, Column: 1},
+		{Text: "/* done */", Line: 4, Column: 21, Trailing: true},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expected) {
+		t.Fatalf("Expected %d comments, got %d: %+v", len(expected), len(comments), comments)
+	}
+	for i, c := range comments {
+		if c != expected[i] {
+			t.Errorf("Comment %d: expected %+v, got %+v", i, expected[i], c)
+		}
+	}
+
+	blank := l.BlankLines()
+	if len(blank) != 3 || blank[0] != 2 || blank[1] != 5 || blank[2] != 6 {
+		t.Errorf("Expected blank lines [2 5 6], got %v", blank)
+	}
+}
+
+func TestLexerShebang(t *testing.T) {
+	l := lexer.New("#!/usr/bin/env lynx\nlet x = 1")
+	tok := l.NextToken()
+	if tok.Type != token.LET {
+		t.Fatalf("Expected the shebang line to be skipped, got %s", tok.Type)
+	}
+	if tok.Line != 2 {
+		t.Errorf("Expected line 2 after the shebang, got %d", tok.Line)
+	}
+}
+
 // Testē pilnu programmas fragmentu
 func TestLexerFullExpression(t *testing.T) {
 
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"lynx/pkg/lint"
+	"testing"
+)
+
+func TestLintRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"fn f(a) {\n    let unused = 1\n    let _skip = 2\n    return a\n}",
+			[]string{"2:9: warning: unused is declared but never used (unused-variable)"},
+		},
+		{
+			"for i in [1, 2] { println(1) }",
+			[]string{"1:5: warning: i is declared but never used (unused-variable)"},
+		},
+		{
+			"fn f(x) {\n    let g = fn(x) { x }\n    return g(x)\n}",
+			[]string{"2:16: warning: x shadows the declaration on line 1 (shadowed-name)"},
+		},
+		{"let x = 1\nfn f(x) { x }", nil},
+		{
+			"fn f() { total = 1 }",
+			[]string{"1:10: error: assignment to undeclared variable total; declare it with let (undefined-assignment)"},
+		},
+		{"fn f() { total = 1 }\nlet total = 0", nil},
+		{
+			"fn f() {\n    return 1\n    println(2)\n}",
+			[]string{"3:5: warning: unreachable code after return (unreachable-code)"},
+		},
+		{
+			"while true {\n    break\n    let x = 1\n}",
+			[]string{"3:5: warning: unreachable code after break (unreachable-code)"},
+		},
+		{
+			"fn f() { self.x }",
+			[]string{"1:10: error: self used outside a class or trait method (self-outside-method)"},
+		},
+		{"class P {\n    init = fn(x) { self.x = x }\n    get double = fn() { self.x * 2 }\n}", nil},
+		{
+			"let h = {\"a\": 1, 1: 2, \"1\": 3, \"a\": 4}",
+			[]string{"1:32: warning: duplicate key \"a\" in hash literal (duplicate-key)"},
+		},
+		{
+			"switch x {\n    case 1: \"a\"\n    case 1 if y: \"b\"\n    case 1: \"c\"\n    case n: n\n}",
+			[]string{"4:5: warning: duplicate case 1 (duplicate-case)"},
+		},
+		{
+			"fn add(a, b) { a + b }\nadd(1)\nlen(\"a\", \"b\")\nprintln(1, 2, 3)",
+			[]string{
+				"2:1: error: add takes 2 arguments, got 1 (wrong-arity)",
+				"3:1: error: len takes 1 argument, got 2 (wrong-arity)",
+			},
+		},
+		{"let f = fn(a) { a }\nf = fn(a, b) { a }\nf(1, 2)", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			diags, err := lint.Source(tt.input, "", nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(diags) != len(tt.expected) {
+				t.Fatalf("Expected %d diagnostics, got %d: %v", len(tt.expected), len(diags), diags)
+			}
+			for i, d := range diags {
+				if d.String() != tt.expected[i] {
+					t.Errorf("Expected %q, got %q", tt.expected[i], d.String())
+				}
+			}
+		})
+	}
+}
+
+func TestLintSuppression(t *testing.T) {
+	input := `fn f() {
+    let a = 1 // lint:ignore unused-variable
+    // lint:ignore
+    let b = 2
+    let c = 3 // lint:ignore shadowed-name
+}`
+	diags, err := lint.Source(input, "", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(diags) != 1 || diags[0].Line != 5 {
+		t.Errorf("Expected only the diagnostic for c on line 5, got %v", diags)
+	}
+}
+
+func TestLintConfig(t *testing.T) {
+	config, err := lint.ParseConfig(map[string]string{"unused-variable": "off", "duplicate-key": "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := lint.Source("fn f() { let a = {\"k\": 1, \"k\": 2} }", "main.lynx", config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "main.lynx:1:27: error: duplicate key \"k\" in hash literal (duplicate-key)"
+	if len(diags) != 1 || diags[0].String() != expected {
+		t.Errorf("Expected %q, got %v", expected, diags)
+	}
+
+	if _, err := lint.ParseConfig(map[string]string{"no-such-rule": "off"}); err == nil {
+		t.Error("Expected an error for an unknown rule")
+	}
+	if _, err := lint.ParseConfig(map[string]string{"unused-variable": "loud"}); err == nil {
+		t.Error("Expected an error for an unknown severity")
+	}
+}
@@ -0,0 +1,279 @@
+package test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"lynx/pkg/lsp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// lspClient talks to a server over pipes the way an editor would
+type lspClient struct {
+	t      *testing.T
+	in     io.WriteCloser
+	out    *bufio.Reader
+	nextID int
+	notes  []map[string]any
+}
+
+func newLSPClient(t *testing.T) *lspClient {
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+	server := lsp.New(serverIn, serverOut)
+	go func() {
+		server.Run()
+		serverOut.Close()
+	}()
+	c := &lspClient{t: t, in: clientOut, out: bufio.NewReader(clientIn)}
+	t.Cleanup(func() {
+		c.send(map[string]any{"jsonrpc": "2.0", "method": "exit"})
+		clientOut.Close()
+	})
+	c.request("initialize", map[string]any{})
+	return c
+}
+
+func (c *lspClient) send(message any) {
+	body, _ := json.Marshal(message)
+	fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+func (c *lspClient) receive() map[string]any {
+	length := 0
+	for {
+		line, err := c.out.ReadString('\n')
+		if err != nil {
+			c.t.Fatalf("reading from server: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "Content-Length: "); ok {
+			length, _ = strconv.Atoi(value)
+		}
+	}
+	body := make([]byte, length)
+	io.ReadFull(c.out, body)
+	var message map[string]any
+	if err := json.Unmarshal(body, &message); err != nil {
+		c.t.Fatalf("invalid message %s: %v", body, err)
+	}
+	return message
+}
+
+// request returns the response to a request, keeping notifications that
+// arrive first
+func (c *lspClient) request(method string, params any) map[string]any {
+	c.nextID++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	for {
+		message := c.receive()
+		if _, isResponse := message["id"]; isResponse {
+			return message
+		}
+		c.notes = append(c.notes, message)
+	}
+}
+
+// open sends a document and returns the diagnostics published for it
+func (c *lspClient) open(uri, text string) []any {
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/didOpen", "params": map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "lynx", "version": 1, "text": text},
+	}})
+	message := c.receive()
+	if message["method"] != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected diagnostics, got %v", message)
+	}
+	return message["params"].(map[string]any)["diagnostics"].([]any)
+}
+
+func at(uri string, line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+// compact renders a result without its noise, for comparisons
+func compact(v any) string {
+	body, _ := json.Marshal(v)
+	return string(body)
+}
+
+func TestLSPDiagnostics(t *testing.T) {
+	c := newLSPClient(t)
+
+	diags := c.open("file:///tmp/broken.lynx", "let x = 1\nlet = 2\n")
+	if len(diags) == 0 {
+		t.Fatal("expected a diagnostic for the parse error")
+	}
+	first := diags[0].(map[string]any)
+	start := first["range"].(map[string]any)["start"].(map[string]any)
+	if start["line"] != 1.0 || first["severity"] != 1.0 {
+		t.Errorf("expected an error on line 1, got %s", compact(first))
+	}
+
+	diags = c.open("file:///tmp/lint.lynx", "fn f() {\n    let unused = 1\n}\n")
+	if len(diags) != 1 || diags[0].(map[string]any)["code"] != "unused-variable" {
+		t.Errorf("expected an unused-variable warning, got %s", compact(diags))
+	}
+
+	diags = c.open("file:///tmp/clean.lynx", "let s = \"é\"\nprintln(s)\n")
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %s", compact(diags))
+	}
+}
+
+func TestLSPNavigation(t *testing.T) {
+	c := newLSPClient(t)
+	uri := "file:///tmp/nav.lynx"
+	source := `fn add(a: int, b: int) -> int {
+    return a + b
+}
+
+class Counter {
+    let count = 0
+    inc = fn() { self.count = add(self.count, 1) }
+}
+
+let total = add(1, 2)
+println(total)
+`
+	if diags := c.open(uri, source); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", compact(diags))
+	}
+
+	hover := c.request("textDocument/hover", at(uri, 9, 13))["result"].(map[string]any)
+	value := hover["contents"].(map[string]any)["value"].(string)
+	if !strings.Contains(value, "fn add(a: int, b: int) -> int") {
+		t.Errorf("hover on add: got %q", value)
+	}
+	hover = c.request("textDocument/hover", at(uri, 10, 2))["result"].(map[string]any)
+	if value := hover["contents"].(map[string]any)["value"].(string); !strings.Contains(value, "println") {
+		t.Errorf("hover on println: got %q", value)
+	}
+
+	documented := "file:///tmp/documented.lynx"
+	c.open(documented, "/// Doubles x.\nfn double(x) { x * 2 }\ndouble(1)\n")
+	hover = c.request("textDocument/hover", at(documented, 2, 1))["result"].(map[string]any)
+	if value := hover["contents"].(map[string]any)["value"].(string); value != "```lynx\nfn double(x)\n```\n\nDoubles x." {
+		t.Errorf("hover on a documented function: got %q", value)
+	}
+
+	definition := c.request("textDocument/definition", at(uri, 9, 13))["result"]
+	if got := compact(definition); got != `{"range":{"end":{"character":6,"line":0},"start":{"character":3,"line":0}},"uri":"file:///tmp/nav.lynx"}` {
+		t.Errorf("definition of add: got %s", got)
+	}
+	definition = c.request("textDocument/definition", at(uri, 6, 24))["result"]
+	if got := compact(definition); !strings.Contains(got, `"start":{"character":8,"line":5}`) {
+		t.Errorf("definition of self.count: got %s", got)
+	}
+
+	references := c.request("textDocument/references", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 4},
+		"context":      map[string]any{"includeDeclaration": true},
+	})["result"].([]any)
+	if len(references) != 3 {
+		t.Errorf("expected 3 references to add, got %s", compact(references))
+	}
+
+	rename := c.request("textDocument/rename", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 10, "character": 10},
+		"newName":      "sum",
+	})["result"].(map[string]any)
+	edits := rename["changes"].(map[string]any)[uri].([]any)
+	if len(edits) != 2 {
+		t.Errorf("expected 2 edits renaming total, got %s", compact(edits))
+	}
+	for _, newName := range []string{"let", "2x"} {
+		response := c.request("textDocument/rename", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": 10, "character": 10},
+			"newName":      newName,
+		})
+		if response["error"] == nil {
+			t.Errorf("expected renaming to %q to fail", newName)
+		}
+	}
+
+	symbols := c.request("textDocument/documentSymbol", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+	})["result"].([]any)
+	names := []string{}
+	for _, s := range symbols {
+		symbol := s.(map[string]any)
+		name := symbol["name"].(string)
+		if children, ok := symbol["children"].([]any); ok {
+			for _, child := range children {
+				name += " " + child.(map[string]any)["name"].(string)
+			}
+		}
+		names = append(names, name)
+	}
+	if got := strings.Join(names, ", "); got != "add, Counter count inc, total" {
+		t.Errorf("symbols: got %s", got)
+	}
+
+	if response := c.request("workspace/symbol", map[string]any{}); response["error"] == nil {
+		t.Error("expected unsupported requests to fail")
+	}
+}
+
+func TestLSPCompletion(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "util.lynx"), []byte("export fn shout(s) { s.upper() }\nlet hidden = 1\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newLSPClient(t)
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "main.lynx"))
+	source := "@\"./util\" as util\nlet name = \"lynx\"\n\nutil.shout(name)\n"
+	c.open(uri, source)
+
+	labels := func(line, character int) string {
+		items := c.request("textDocument/completion", at(uri, line, character))["result"].([]any)
+		names := []string{}
+		for _, item := range items {
+			names = append(names, item.(map[string]any)["label"].(string))
+		}
+		return strings.Join(names, " ")
+	}
+
+	// Typing makes the document unparseable; completion keeps working from
+	// the last version that parsed
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "textDocument/didChange", "params": map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"text": "@\"./util\" as util\nlet name = \"lynx\"\nutil.\nutil.shout(name)\nname.\n[1].\n"}},
+	}})
+	c.receive()
+
+	if got := labels(2, 5); got != "shout" {
+		t.Errorf("module members: got %q", got)
+	}
+	if got := labels(4, 5); !strings.Contains(got, "upper") || strings.Contains(got, "push") {
+		t.Errorf("string methods: got %q", got)
+	}
+	if got := labels(5, 4); !strings.Contains(got, "push") || strings.Contains(got, "upper") {
+		t.Errorf("array methods: got %q", got)
+	}
+	got := labels(2, 0)
+	for _, want := range []string{"name", "util", "println", "while"} {
+		if !strings.Contains(" "+got+" ", " "+want+" ") {
+			t.Errorf("expected %s among completions, got %q", want, got)
+		}
+	}
+
+	definition := compact(c.request("textDocument/definition", at(uri, 3, 7))["result"])
+	if !strings.Contains(definition, "util.lynx") || !strings.Contains(definition, `"start":{"character":10,"line":0}`) {
+		t.Errorf("definition of util.shout: got %s", definition)
+	}
+}
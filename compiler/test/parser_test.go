@@ -159,3 +159,349 @@ func TestParserWhileStatement(t *testing.T) {
 		t.Fatal("While body is nil")
 	}
 }
+
+func TestParserArrowFunction(t *testing.T) {
+	tests := []struct {
+		input  string
+		params []string
+	}{
+		{"x => x * 2", []string{"x"}},
+		{"(a, b) => a + b", []string{"a", "b"}},
+		{"() => 42", []string{}},
+		{"x => { return x }", []string{"x"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected 1 statement, got %d", len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Statement is not ExpressionStatement. got=%T", program.Statements[0])
+		}
+
+		fn, ok := stmt.Expression.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("Expression is not FunctionLiteral. got=%T", stmt.Expression)
+		}
+
+		if len(fn.Parameters) != len(tt.params) {
+			t.Fatalf("Wrong number of parameters. expected=%d, got=%d", len(tt.params), len(fn.Parameters))
+		}
+
+		for i, name := range tt.params {
+			if fn.Parameters[i].Value != name {
+				t.Errorf("Parameter %d wrong. expected=%s, got=%s", i, name, fn.Parameters[i].Value)
+			}
+		}
+
+		if len(fn.Body.Statements) != 1 {
+			t.Errorf("Expected 1 body statement, got %d", len(fn.Body.Statements))
+		}
+	}
+}
+
+func TestParserTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"load()?", "load()?"},
+		{"a.b()? + 1", "(a.b()? + 1)"},
+		{"-parse(x)?", "(-parse(x)?)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if got := stmt.Expression.String(); got != tt.expected {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestParserTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 5", "int"},
+		{"let m: hash[str, array[int]]? = null", "hash[str, array[int]]?"},
+		{"let u: int | str = 1", "int | str"},
+		{"let f: fn = print", "fn"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.VarStatement)
+		if stmt.Type == nil {
+			t.Errorf("For %q expected a type annotation", tt.input)
+			continue
+		}
+		if got := stmt.Type.String(); got != tt.expected {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.expected, got)
+		}
+	}
+
+	l := lexer.New("fn add(a: int, b) -> float { a + b }")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.VarStatement)
+	if stmt.Name.Value != "add" {
+		t.Fatalf("Expected declaration of add, got %s", stmt.Name.Value)
+	}
+	fn := stmt.Value.(*ast.FunctionLiteral)
+	if len(fn.ParamTypes) != 2 || fn.ParamTypes[0].String() != "int" || fn.ParamTypes[1] != nil {
+		t.Errorf("Unexpected parameter types %v", fn.ParamTypes)
+	}
+	if fn.ReturnType == nil || fn.ReturnType.String() != "float" {
+		t.Errorf("Expected return type float, got %v", fn.ReturnType)
+	}
+}
+
+func TestParserModuleLoads(t *testing.T) {
+	tests := []struct {
+		input   string
+		path    string
+		binding string
+		output  string
+	}{
+		{"@json", "json", "json", "@json"},
+		{"@json as j", "json", "j", "@json as j"},
+		{"@http.client", "http.client", "client", "@http.client"},
+		{"@\"./lib/util\"", "./lib/util", "util", "@\"./lib/util\""},
+		{"@\"../shared/db.lynx\" as store", "../shared/db.lynx", "store", "@\"../shared/db.lynx\" as store"},
+		{"@math(sqrt, pi)", "math", "math", "@math(sqrt, pi)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+
+		checkParserErrors(t, p)
+
+		load, ok := program.Statements[0].(*ast.ModuleLoad)
+		if !ok {
+			t.Fatalf("For %q expected *ast.ModuleLoad, got %T", tt.input, program.Statements[0])
+		}
+		if load.Path() != tt.path {
+			t.Errorf("For %q expected path %q, got %q", tt.input, tt.path, load.Path())
+		}
+		if load.Binding() != tt.binding {
+			t.Errorf("For %q expected binding %q, got %q", tt.input, tt.binding, load.Binding())
+		}
+		if load.String() != tt.output {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.output, load.String())
+		}
+	}
+}
+
+func TestParserExportStatement(t *testing.T) {
+	input := `
+export let a = 1
+export const B = 2
+export fn c() { 3 }
+export class D { }
+export trait E { f() }
+let export = 4
+`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 6 {
+		t.Fatalf("Expected 6 statements, got %d", len(program.Statements))
+	}
+	for i, stmt := range program.Statements[:5] {
+		if _, ok := stmt.(*ast.ExportStatement); !ok {
+			t.Errorf("Statement %d: expected *ast.ExportStatement, got %T", i, stmt)
+		}
+	}
+	if _, ok := program.Statements[5].(*ast.VarStatement); !ok {
+		t.Errorf("Expected export to remain usable as a name, got %T", program.Statements[5])
+	}
+}
+
+func TestParserClassAccessors(t *testing.T) {
+	input := `
+class Box {
+	get size = fn() { return self._size }
+	set size = fn(value) { self._size = value }
+	private let secret = 1
+	private static let count = 0
+}
+let get = 1
+`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(program.Statements))
+	}
+
+	class, ok := program.Statements[0].(*ast.Class)
+	if !ok {
+		t.Fatalf("Statement is not Class. got=%T", program.Statements[0])
+	}
+
+	body := class.Body.Statements
+	if len(body) != 4 {
+		t.Fatalf("Expected 4 class members, got %d", len(body))
+	}
+
+	for i, kind := range []string{"get", "set"} {
+		accessor, ok := body[i].(*ast.Accessor)
+		if !ok {
+			t.Fatalf("Member %d is not Accessor. got=%T", i, body[i])
+		}
+		if accessor.Kind != kind || accessor.Name.Value != "size" {
+			t.Errorf("Expected %s size, got %s %s", kind, accessor.Kind, accessor.Name.Value)
+		}
+	}
+
+	for _, member := range body[2:] {
+		if _, ok := member.(*ast.PrivateStatement); !ok {
+			t.Errorf("Member is not PrivateStatement. got=%T", member)
+		}
+	}
+}
+
+func TestParserCatchHandlers(t *testing.T) {
+	input := `
+catch {
+	load()
+} on e: ValueError, TypeError {
+	println(e)
+} on e {
+	error e
+} finally {
+	close()
+}
+`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.CatchStatement)
+	if !ok {
+		t.Fatalf("Statement is not CatchStatement. got=%T", program.Statements[0])
+	}
+
+	if len(stmt.Handlers) != 2 {
+		t.Fatalf("Expected 2 handlers, got %d", len(stmt.Handlers))
+	}
+
+	if len(stmt.Handlers[0].Types) != 2 || stmt.Handlers[0].Types[1].Value != "TypeError" {
+		t.Errorf("Expected first handler to filter ValueError, TypeError, got %v", stmt.Handlers[0].Types)
+	}
+
+	if len(stmt.Handlers[1].Types) != 0 {
+		t.Errorf("Expected catch-all second handler, got %v", stmt.Handlers[1].Types)
+	}
+
+	if stmt.Finally == nil {
+		t.Errorf("Expected finally block")
+	}
+}
+
+func TestParserTraitStatement(t *testing.T) {
+	input := `
+trait Comparable {
+	compare(other)
+	lt(other) { return self.compare(other) < 0 }
+	let gt = fn(other) { return self.compare(other) > 0 }
+}
+class Money(Base) impl Comparable, Hashable {}
+`
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected 2 statements, got %d", len(program.Statements))
+	}
+
+	trait, ok := program.Statements[0].(*ast.Trait)
+	if !ok {
+		t.Fatalf("Statement is not Trait. got=%T", program.Statements[0])
+	}
+
+	if len(trait.Methods) != 3 {
+		t.Fatalf("Expected 3 trait methods, got %d", len(trait.Methods))
+	}
+
+	if trait.Methods[0].Body != nil {
+		t.Errorf("Expected compare to be required (no body)")
+	}
+
+	for _, m := range trait.Methods[1:] {
+		if m.Body == nil {
+			t.Errorf("Expected %s to have a default body", m.Name.Value)
+		}
+	}
+
+	class, ok := program.Statements[1].(*ast.Class)
+	if !ok {
+		t.Fatalf("Statement is not Class. got=%T", program.Statements[1])
+	}
+
+	if class.SuperClass == nil || class.SuperClass.Value != "Base" {
+		t.Errorf("Superclass wrong. got=%v", class.SuperClass)
+	}
+
+	if len(class.Traits) != 2 || class.Traits[0].Value != "Comparable" || class.Traits[1].Value != "Hashable" {
+		t.Errorf("Traits wrong. got=%v", class.Traits)
+	}
+}
+
+func TestParserIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"fn f() {", true},
+		{"let x = 1 +", true},
+		{"[1, 2", true},
+		{"\"unterminated", true},
+		{"/* open comment", true},
+		{"let x = )", false},
+		{"fn f() { 1 }", false},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		p.ParseProgram()
+		if p.Incomplete() != tt.incomplete {
+			t.Errorf("Incomplete(%q) = %v, expected %v", tt.input, p.Incomplete(), tt.incomplete)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"lynx/pkg/profile"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const profiledProgram = `@"./shapes"
+
+fn fib(n) {
+    if n < 2 {
+        return n
+    }
+    return fib(n - 1) + fib(n - 2)
+}
+
+let total = 0
+for i in [1, 2, 3] {
+    total = total + len(str(fib(10)))
+}
+let sq = shapes.Square(2)
+sq.area() + "ab".upper().len()
+`
+
+func profileProgram(t *testing.T) (*profile.Profiler, object.Object) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shapes.lynx": "class Square {\n    let init = fn(side) {\n        self.side = side\n    }\n    let area = fn() {\n        self.side * self.side\n    }\n}\n",
+	})
+	main := filepath.Join(dir, "main.lynx")
+
+	p := parser.New(lexer.New(profiledProgram))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	evaluator.RegisterBuiltins()
+	env := object.New(dir)
+	env.File = main
+
+	prof := profile.New()
+	prof.Start(main)
+	result := evaluator.Eval(program, env)
+	prof.Stop()
+	return prof, result
+}
+
+func TestProfilerCounts(t *testing.T) {
+	prof, result := profileProgram(t)
+	testIntegerObject(t, result, 6)
+
+	stats := map[string]*profile.Stats{}
+	for _, s := range prof.Functions() {
+		stats[s.Site.String()] = s
+	}
+
+	tests := []struct {
+		site    string
+		calls   int
+		builtin bool
+	}{
+		{"main (main.lynx:1)", 1, false},
+		{"fib (main.lynx:3)", 3 * 177, false},
+		{"len", 3, true},
+		{"str", 3, true},
+		{"str.upper", 1, true},
+		{"str.len", 1, true},
+		{"<module shapes> (shapes.lynx:1)", 1, false},
+		{"Square.init (shapes.lynx:2)", 1, false},
+		{"Square.area (shapes.lynx:5)", 1, false},
+	}
+	for _, tt := range tests {
+		s, ok := stats[tt.site]
+		if !ok {
+			t.Errorf("no profile for %s, got %v", tt.site, keys(stats))
+			continue
+		}
+		if s.Calls != tt.calls || s.Builtin != tt.builtin {
+			t.Errorf("%s: got %d calls (builtin %v), want %d (builtin %v)", tt.site, s.Calls, s.Builtin, tt.calls, tt.builtin)
+		}
+		if s.Self > s.Cumulative {
+			t.Errorf("%s: self time %s exceeds cumulative %s", tt.site, s.Self, s.Cumulative)
+		}
+	}
+
+	// Recursive calls are counted once towards cumulative time, so no
+	// function can take longer than the whole program
+	main := stats["main (main.lynx:1)"]
+	if fib := stats["fib (main.lynx:3)"]; fib.Cumulative > main.Cumulative {
+		t.Errorf("fib's cumulative time %s exceeds the program's %s", fib.Cumulative, main.Cumulative)
+	}
+	if main.Allocs == 0 || main.SelfAllocs > main.Allocs {
+		t.Errorf("expected the program to allocate, got %d (self %d)", main.Allocs, main.SelfAllocs)
+	}
+	if main.Cumulative > prof.Duration {
+		t.Errorf("the program's cumulative time %s exceeds the profile's %s", main.Cumulative, prof.Duration)
+	}
+	if evaluator.Trace != nil {
+		t.Errorf("expected Stop to remove the profiler")
+	}
+}
+
+func keys(m map[string]*profile.Stats) []string {
+	names := []string{}
+	for name := range m {
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestProfilerOutput(t *testing.T) {
+	prof, _ := profileProgram(t)
+
+	var report strings.Builder
+	prof.WriteReport(&report)
+	lines := strings.Split(report.String(), "\n")
+	if !strings.HasPrefix(lines[0], "Profile: ") || !strings.Contains(lines[2], "cumulative") {
+		t.Errorf("unexpected report header:\n%s", report.String())
+	}
+	if !strings.Contains(report.String(), "fib (main.lynx:3)") {
+		t.Errorf("expected fib in the report:\n%s", report.String())
+	}
+
+	var folded strings.Builder
+	if err := prof.WriteFolded(&folded); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(folded.String()), "\n") {
+		i := strings.LastIndex(line, " ")
+		if i < 0 || !strings.HasPrefix(line, "main (main.lynx:1)") || strings.Trim(line[i+1:], "0123456789") != "" {
+			t.Errorf("malformed folded stack line %q", line)
+		}
+	}
+	if !strings.Contains(folded.String(), "main (main.lynx:1);fib (main.lynx:3);fib (main.lynx:3)") {
+		t.Errorf("expected recursive fib stacks in:\n%s", folded.String())
+	}
+
+	var pprof bytes.Buffer
+	if err := prof.WritePprof(&pprof); err != nil {
+		t.Fatal(err)
+	}
+	gz, err := gzip.NewReader(&pprof)
+	if err != nil {
+		t.Fatalf("expected gzipped pprof output: %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, defaultType := pprofStrings(t, data)
+	for _, s := range []string{"nanoseconds", "alloc_objects", "fib", "Square.area", "str.upper"} {
+		if !slices.Contains(table, s) {
+			t.Errorf("expected %q in the pprof string table", s)
+		}
+	}
+	if defaultType >= len(table) || table[defaultType] != "time" {
+		t.Errorf("expected time as the default sample type, got index %d of %v", defaultType, table)
+	}
+}
+
+// pprofStrings decodes the string table and default sample type of a pprof
+// profile, skipping its other fields
+func pprofStrings(t *testing.T, data []byte) ([]string, int) {
+	varint := func() uint64 {
+		var v uint64
+		for shift := 0; len(data) > 0; shift += 7 {
+			b := data[0]
+			data = data[1:]
+			v |= uint64(b&0x7f) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		return v
+	}
+	table, defaultType := []string{}, 0
+	for len(data) > 0 {
+		key := varint()
+		switch field, wireType := key>>3, key&7; {
+		case wireType == 0 && field == 14:
+			defaultType = int(varint())
+		case wireType == 0:
+			varint()
+		case wireType == 2:
+			n := varint()
+			if n > uint64(len(data)) {
+				t.Fatalf("truncated pprof field %d", field)
+			}
+			if field == 6 {
+				table = append(table, string(data[:n]))
+			}
+			data = data[n:]
+		default:
+			t.Fatalf("unexpected wire type %d in pprof output", wireType)
+		}
+	}
+	return table, defaultType
+}
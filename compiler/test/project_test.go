@@ -0,0 +1,228 @@
+package test
+
+import (
+	"lynx/pkg/evaluator"
+	"lynx/pkg/project"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProjectManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app/lynx.toml": `# a comment
+[project]
+name = "demo"   # trailing comment
+entry = "src/main.lynx"
+roots = ["src", "lib"]
+
+[dependencies]
+strutil = "../shared/strutil"
+"odd name" = "../shared/odd"
+
+[lint]
+unused-variable = "off"
+`,
+	})
+
+	m, err := project.Find(filepath.Join(dir, "app", "src", "nested"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil {
+		t.Fatal("Expected to find the manifest in a parent directory")
+	}
+	if m.Name != "demo" || m.Entry != "src/main.lynx" {
+		t.Errorf("Unexpected project fields: name=%q entry=%q", m.Name, m.Entry)
+	}
+	if !slices.Equal(m.Roots, []string{"src", "lib"}) {
+		t.Errorf("Unexpected roots %v", m.Roots)
+	}
+	if m.Dependencies["strutil"] != "../shared/strutil" || m.Dependencies["odd name"] != "../shared/odd" {
+		t.Errorf("Unexpected dependencies %v", m.Dependencies)
+	}
+
+	expected := []string{
+		filepath.Join(dir, "app", "src"),
+		filepath.Join(dir, "app", "lib"),
+		filepath.Join(dir, "shared", "odd"),
+		filepath.Join(dir, "shared", "strutil"),
+	}
+	if roots := m.ModuleRoots(); !slices.Equal(roots, expected) {
+		t.Errorf("Expected roots %v, got %v", expected, roots)
+	}
+
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := project.Load(m.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.Name != m.Name || !slices.Equal(reloaded.Roots, m.Roots) || len(reloaded.Dependencies) != 2 ||
+		reloaded.Lint["unused-variable"] != "off" {
+		t.Errorf("Manifest did not survive a save: %+v", reloaded)
+	}
+
+	none, err := project.Find(t.TempDir())
+	if err != nil || none != nil {
+		t.Errorf("Expected no manifest, got %v (%v)", none, err)
+	}
+
+	writeFiles(t, dir, map[string]string{"bad/lynx.toml": "[project]\nname = demo\n"})
+	if _, err := project.Load(filepath.Join(dir, "bad")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected a parse error on line 2, got %v", err)
+	}
+}
+
+func TestProjectManifestComments(t *testing.T) {
+	dir := t.TempDir()
+	manifest := `# demo project
+[project]
+name = "demo"   # shown in lynx init
+entry = "main.lynx"
+roots = []
+
+[dependencies]
+# shared helpers
+strutil = "../shared/strutil"
+old = "../shared/old"
+
+# lint tuning for the team
+[lint]
+unused-variable = "off"
+`
+	writeFiles(t, dir, map[string]string{
+		"app/lynx.toml":               manifest,
+		"shared/strutil/strutil.lynx": "export let x = 1\n",
+		"shared/old/old.lynx":         "export let y = 2\n",
+		"shared/extra/extra.lynx":     "export let z = 3\n",
+	})
+	app := filepath.Join(dir, "app")
+
+	m, err := project.Load(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Name = "renamed"
+	if err := m.Add("extra", filepath.Join(dir, "shared", "extra")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("old"); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(filepath.Join(app, project.ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.NewReplacer(
+		`name = "demo"`, `name = "renamed"`,
+		"old = \"../shared/old\"\n", "extra = \"../shared/extra\"\n",
+	).Replace(manifest)
+	if string(saved) != expected {
+		t.Errorf("expected the manifest to keep its comments:\n%s\ngot\n%s", expected, saved)
+	}
+}
+
+func TestProjectDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"shared/strutil/strutil.lynx": "export fn shout(s) { s.upper() + \"!\" }\n",
+		"shared/strutil/notes.txt":    "not part of the package\n",
+		"shared/other/other.lynx":     "export let x = 1\n",
+	})
+	app := filepath.Join(dir, "app")
+	if err := os.MkdirAll(app, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	m := project.New(app)
+	if err := m.Add("strutil", filepath.Join(dir, "shared", "strutil")); err != nil {
+		t.Fatal(err)
+	}
+	if m.Dependencies["strutil"] != "../shared/strutil" {
+		t.Errorf("Expected the dependency path relative to the project, got %q", m.Dependencies["strutil"])
+	}
+	if err := m.Add("missing", "../nowhere"); err == nil {
+		t.Error("Expected adding a missing directory to fail")
+	}
+	if err := m.Add("../escape", filepath.Join(dir, "shared", "strutil")); err == nil {
+		t.Error("Expected a dependency name with a path separator to fail")
+	}
+
+	lock, err := m.ReadLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := project.HashDir(filepath.Join(dir, "shared", "strutil"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock["strutil"].Hash != hash || !strings.HasPrefix(hash, "sha256:") {
+		t.Errorf("Expected lock hash %s, got %+v", hash, lock["strutil"])
+	}
+
+	evaluator.RegisterBuiltins()
+	evaluator.ModuleRoots = m.ModuleRoots()
+	defer func() { evaluator.ModuleRoots = nil }()
+	evaluated := testEvalInDir(t, app, "@strutil\nstrutil.shout(\"hi\")")
+	if evaluated.Inspect() != "HI!" {
+		t.Errorf("Expected the dependency to be importable, got %s", evaluated.Inspect())
+	}
+
+	writeFiles(t, dir, map[string]string{"shared/strutil/strutil.lynx": "export fn shout(s) { s }\n"})
+	stale, err := m.StaleDependencies()
+	if err != nil || !slices.Equal(stale, []string{"strutil"}) {
+		t.Errorf("Expected strutil to be stale, got %v (%v)", stale, err)
+	}
+
+	if err := m.Add("other", filepath.Join(dir, "shared", "other")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Vendor(); err != nil {
+		t.Fatal(err)
+	}
+	vendored := filepath.Join(app, project.VendorDir, "strutil")
+	if m.DependencyDir("strutil") != vendored {
+		t.Errorf("Expected the vendored copy to be used, got %s", m.DependencyDir("strutil"))
+	}
+	if _, err := os.Stat(filepath.Join(vendored, "notes.txt")); !os.IsNotExist(err) {
+		t.Error("Expected only .lynx files to be vendored")
+	}
+	if stale, _ := m.StaleDependencies(); len(stale) != 0 {
+		t.Errorf("Expected vendoring to relock, got stale %v", stale)
+	}
+
+	if err := m.Remove("strutil"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(vendored); !os.IsNotExist(err) {
+		t.Error("Expected remove to delete the vendored copy")
+	}
+	if _, err := os.Stat(filepath.Join(app, project.VendorDir, "other")); err != nil {
+		t.Errorf("Expected other vendored copies to be kept, got %v", err)
+	}
+	reloaded, err := project.Load(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reloaded.Dependencies) != 1 || reloaded.Dependencies["other"] == "" {
+		t.Errorf("Expected only other after remove, got %v", reloaded.Dependencies)
+	}
+	if lock, _ := reloaded.ReadLock(); len(lock) != 1 {
+		t.Errorf("Expected remove to relock, got %v", lock)
+	}
+	if err := m.Remove("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(app, project.VendorDir)); !os.IsNotExist(err) {
+		t.Error("Expected remove to delete the emptied vendor directory")
+	}
+	if err := m.Remove("strutil"); err == nil {
+		t.Error("Expected removing an unknown dependency to fail")
+	}
+}
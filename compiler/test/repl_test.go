@@ -0,0 +1,48 @@
+package test
+
+import (
+	"bytes"
+	"lynx/pkg/repl"
+	"strings"
+	"testing"
+)
+
+func TestREPLSession(t *testing.T) {
+	input := `let x = 5
+x + 1
+fn add(a, b) {
+  a + b
+}
+add(x, 2)
+["a", 1]
+:type add(1, 2)
+:ast !true
+:env
+:reset
+:env
+y
+let z = )
+`
+	var out bytes.Buffer
+	if err := repl.New(strings.NewReader(input), &out, t.TempDir()).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `6
+7
+["a", 1]
+int
+Program 1:1-1:6
+  statements[0]: ExpressionStatement 1:1-1:6
+    expression: PrefixExpression 1:1-1:6 operator="!"
+      right: Boolean 1:2-1:6 value=true
+add: fn
+x = 5
+Session reset
+Error: "y" is not defined
+Parser error: SyntaxError at line 1, column 9: Unexpected token )
+`
+	if out.String() != expected {
+		t.Errorf("Unexpected session output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"encoding/xml"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/tester"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const mathTests = `@"assert"
+
+let calls = 0
+
+fn add(a, b) {
+    calls = calls + 1
+    a + b
+}
+
+fn test_add() {
+    assert.equal(add(1, 2), 3)
+    assert.equal(calls, 1)
+}
+
+fn test_wrong() {
+    println("adding")
+    let sum = add(2, 2)
+    assert.equal(sum, 5)
+}
+
+let test_lines = fn() {
+    assert.equal("a\nb\nc", "a\nB\nc")
+}
+
+fn test_collections() {
+    assert.deepEqual({"a": [1, 2]}, {"a": [1, 2]})
+    assert.notEqual({"a": 1}, {"a": 1})
+    assert.approx(0.1 + 0.2, 0.3, 0.0001)
+    let err = assert.raises(fn() { error "boom" })
+    assert.equal(err.message, "boom")
+}
+
+fn test_broken() {
+    missing()
+}
+
+fn helper_not_a_test() {
+    assert.fail("never called")
+}
+`
+
+func runTests(t *testing.T, filter *regexp.Regexp) []*tester.Result {
+	dir, _ := filepath.EvalSymlinks(t.TempDir())
+	writeFiles(t, dir, map[string]string{
+		"math_test.lynx":        mathTests,
+		"math.lynx":             "let x = 1\n",
+		"vendor/dep_test.lynx":  "fn test_vendored() {}\n",
+		".hidden/dep_test.lynx": "fn test_hidden() {}\n",
+	})
+
+	files, err := tester.Discover([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "math_test.lynx" {
+		t.Fatalf("expected to find only math_test.lynx, got %v", files)
+	}
+	suite, err := tester.Load(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := suite.Run(filter)
+	if evaluator.Trace != nil {
+		t.Errorf("expected the runner to remove its tracer")
+	}
+	return results
+}
+
+func TestTestRunner(t *testing.T) {
+	results := runTests(t, nil)
+
+	tests := []struct {
+		name    string
+		status  tester.Status
+		line    int
+		message string
+		diff    string
+		output  string
+	}{
+		{"test_add", tester.Pass, 0, "", "", ""},
+		{"test_wrong", tester.Fail, 18, "expected 5, got 4", "expected: 5\nactual:   4\n", "adding\n"},
+		{"test_lines", tester.Fail, 22, `expected "a\nB\nc", got "a\nb\nc"`, "--- expected\n+++ actual\n  a\n- B\n+ b\n  c\n", ""},
+		{"test_collections", tester.Pass, 0, "", "", ""},
+		{"test_broken", tester.Error, 34, `"missing" is not defined`, "", ""},
+	}
+	if len(results) != len(tests) {
+		t.Fatalf("expected %d results, got %d", len(tests), len(results))
+	}
+	for i, tt := range tests {
+		r := results[i]
+		if r.Test.Name != tt.name {
+			t.Errorf("result %d: expected %s, got %s", i, tt.name, r.Test.Name)
+			continue
+		}
+		if r.Status != tt.status || r.Line != tt.line || r.Message != tt.message {
+			t.Errorf("%s: got %s at line %d: %q, want %s at line %d: %q", tt.name, r.Status, r.Line, r.Message, tt.status, tt.line, tt.message)
+		}
+		if diff := r.Diff(); diff != tt.diff {
+			t.Errorf("%s: expected diff %q, got %q", tt.name, tt.diff, diff)
+		}
+		if r.Output != tt.output {
+			t.Errorf("%s: expected output %q, got %q", tt.name, tt.output, r.Output)
+		}
+	}
+	if c := tester.Count(results); c.Passed != 2 || c.Failed != 2 || c.Errors != 1 || c.OK() {
+		t.Errorf("unexpected counts %+v", c)
+	}
+
+	filtered := runTests(t, regexp.MustCompile("^test_(add|lines)$"))
+	if len(filtered) != 2 || filtered[0].Test.Name != "test_add" || filtered[1].Test.Name != "test_lines" {
+		t.Errorf("expected the filter to select test_add and test_lines, got %d results", len(filtered))
+	}
+}
+
+func TestTestReports(t *testing.T) {
+	results := runTests(t, nil)
+	base := func(path string) string { return filepath.Base(path) }
+
+	var text strings.Builder
+	tester.WriteText(&text, results, base)
+	for _, want := range []string{
+		"math_test.lynx\n  PASS  test_add (",
+		"  FAIL  test_wrong (",
+		"      math_test.lynx:18: expected 5, got 4\n        expected: 5\n        actual:   4\n      output:\n        adding\n",
+		"      math_test.lynx:34: RuntimeError: \"missing\" is not defined\n",
+		"\n2 passed, 2 failed, 1 errors (",
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("expected text report to contain %q, got\n%s", want, text.String())
+		}
+	}
+
+	var tap strings.Builder
+	if err := tester.WriteTAP(&tap, results, base); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"TAP version 13\n1..5\nok 1 - math_test.lynx test_add\nnot ok 2 - math_test.lynx test_wrong\n",
+		"  ---\n  message: \"expected 5, got 4\"\n  severity: fail\n  type: \"AssertionError\"\n  at: \"math_test.lynx:18\"\n  expected: \"5\"\n  actual: \"4\"\n  output: \"adding\\n\"\n  ...\n",
+		"not ok 5 - math_test.lynx test_broken\n  ---\n  message: \"\\\"missing\\\" is not defined\"\n  severity: error\n",
+	} {
+		if !strings.Contains(tap.String(), want) {
+			t.Errorf("expected TAP to contain %q, got\n%s", want, tap.String())
+		}
+	}
+
+	var junit strings.Builder
+	if err := tester.WriteJUnit(&junit, results, base); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Cases []struct {
+				Name    string `xml:"name,attr"`
+				Line    int    `xml:"line,attr"`
+				Failure *struct {
+					Type string `xml:"type,attr"`
+				} `xml:"failure"`
+				Error *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"error"`
+				SystemOut string `xml:"system-out"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal([]byte(junit.String()), &doc); err != nil {
+		t.Fatalf("invalid JUnit XML: %v\n%s", err, junit.String())
+	}
+	if doc.Tests != 5 || doc.Failures != 2 || doc.Errors != 1 || len(doc.Suites) != 1 || len(doc.Suites[0].Cases) != 5 {
+		t.Fatalf("unexpected JUnit totals:\n%s", junit.String())
+	}
+	wrong := doc.Suites[0].Cases[1]
+	if wrong.Name != "test_wrong" || wrong.Line != 15 || wrong.Failure == nil || wrong.Failure.Type != "AssertionError" || wrong.SystemOut != "adding\n" {
+		t.Errorf("unexpected JUnit test case for test_wrong:\n%s", junit.String())
+	}
+	if broken := doc.Suites[0].Cases[4]; broken.Error == nil || broken.Error.Message != `"missing" is not defined` {
+		t.Errorf("expected test_broken to be an error:\n%s", junit.String())
+	}
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"fmt"
+	"lynx/pkg/evaluator"
+	"lynx/pkg/lexer"
+	"lynx/pkg/object"
+	"lynx/pkg/parser"
+	"lynx/pkg/types"
+	"strings"
+	"testing"
+)
+
+func checkTypes(t *testing.T, input string) []types.Error {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	return types.New().CheckProgram(program, "", ".")
+}
+
+// TestTypeCheckerErrors tests that provable type mistakes are reported
+func TestTypeCheckerErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x: int = "hello"`, "1:5: cannot assign str to x of type int"},
+		{"let s: str = \"a\"\ns = 5", "2:1: cannot assign int to s of type str"},
+		{"fn add(a: int, b: int) -> int { return a + b }\nadd(1, \"two\")", "2:8: argument 2 to add: expected int, got str"},
+		{"fn add(a: int, b: int) -> int { return a + b }\nadd(1)", "add expects 2 arguments, got 1"},
+		{"fn name() -> str { return 42 }", "cannot return int from a function declared to return str"},
+		{"let n = 5\nn.foo()", "method calls not supported on int"},
+		{`let z = 1 + "a"`, "operator + not defined for int and str"},
+		{"let w: Widget = null", "unknown type Widget"},
+		{"println(missing)", "undefined variable: missing"},
+		{"let a: array[int] = [1, \"x\"]", "cannot assign array[int | str] to a of type array[int]"},
+		{"for v in 5 { println(v) }", "cannot iterate over int"},
+		{"class P { let x: int = 0 }\nlet p = P()\np.x = \"s\"", "cannot assign str to field P.x of type int"},
+		{"class P { let x = 0 }\nP().nope()", "P has no member nope"},
+		{"str(1, 2)", "str expects 1 argument, got 2"},
+		{"sleep(1.5)", "argument 1 to sleep: expected int, got float"},
+		{"trait Shape { area() }\nclass Box { }\nfn size(s: Shape) { s.area() }\nsize(Box())", "argument 1 to size: expected Shape, got Box"},
+	}
+
+	for _, tt := range tests {
+		errs := checkTypes(t, tt.input)
+		if len(errs) != 1 {
+			t.Errorf("For %q expected 1 error, got %v", tt.input, errs)
+			continue
+		}
+		if !strings.HasSuffix(errs[0].String(), tt.expected) {
+			t.Errorf("For %q expected %q, got %q", tt.input, tt.expected, errs[0].String())
+		}
+	}
+}
+
+// TestTypeCheckerAccepts tests that valid and unannotated code passes
+func TestTypeCheckerAccepts(t *testing.T) {
+	tests := []string{
+		"let f: float = 3",
+		"let maybe: str? = null",
+		"let u: int | str = \"a\"\nu = 1",
+		"let h: hash[str, int] = {\"a\": 1}",
+		"fn twice(n) { n * 2 }\ntwice(\"ab\")",
+		"let x = 1\nx = \"now a string\"\nx.upper()",
+		"fn later() { return helper() }\nfn helper() -> int { 1 }",
+		"class A { let init = fn(x) { self.x = x } }\nclass B(A) { let show = fn() { self.x } }\nB(1).show()",
+		"let m = @module()\nm.run = fn() { 1 }\nm.run()",
+		"let r = attempt(fn() { 1 })\nr.unwrapOr(0)",
+		"let dst = [0, 0]\nlet n: int = copy(dst, [1, 2])",
+		"trait Shape { area() }\nclass Sq impl Shape { let area = fn() { 4 } }\nclass Big(Sq) { }\nfn size(s: Shape) { s.area() }\nsize(Sq())\nsize(Big())",
+		"fn describe(e: exception) { e.message }\ncatch { error \"x\" } on e { describe(e) }",
+	}
+
+	for _, input := range tests {
+		if errs := checkTypes(t, input); len(errs) != 0 {
+			t.Errorf("For %q expected no errors, got %v", input, errs)
+		}
+	}
+}
+
+// TestBuiltinSignatures tests that the checker's builtin signatures take as
+// many arguments as the builtins themselves
+func TestBuiltinSignatures(t *testing.T) {
+	evaluator.RegisterBuiltins()
+	args := func(n int) string {
+		return strings.TrimSuffix(strings.Repeat("null, ", n), ", ")
+	}
+	arityError := func(call string) bool {
+		err, ok := testEval(call).(*object.Error)
+		return ok && strings.HasPrefix(err.Message, "wrong number of arguments")
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		sig := types.BuiltinSignature(name)
+		if sig == nil {
+			continue
+		}
+		n := len(sig.Params)
+		if call := fmt.Sprintf("%s(%s)", name, args(n)); arityError(call) {
+			t.Errorf("the signature of %s has arity %d, but %s fails", name, n, call)
+		}
+		if n > 0 {
+			if call := fmt.Sprintf("%s(%s)", name, args(n-1)); !arityError(call) {
+				t.Errorf("expected %s to fail with too few arguments", call)
+			}
+		}
+		if call := fmt.Sprintf("%s(%s)", name, args(n+1)); sig.Variadic == nil && !arityError(call) {
+			t.Errorf("expected %s to fail with too many arguments", call)
+		}
+	}
+}
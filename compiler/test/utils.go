@@ -5,6 +5,8 @@ import (
 	"lynx/pkg/lexer"
 	"lynx/pkg/object"
 	"lynx/pkg/parser"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -80,6 +82,30 @@ func testEvalDebug(t *testing.T, input string) object.Object {
 	return result
 }
 
+// testEvalInDir evaluates input as if it were a file in dir, so imports
+// resolve relative to dir
+func testEvalInDir(t *testing.T, dir string, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	return evaluator.Eval(program, object.New(dir))
+}
+
+// writeFiles creates files under dir from a map of relative path to source
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *parser.Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {